@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/flashbots/node-monitor/config"
+	"github.com/flashbots/node-monitor/server"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	categoryCheck = "CHECK:"
+)
+
+var (
+	ErrCheckFailed              = errors.New("some endpoints failed the check")
+	ErrCheckNoEndpoints         = errors.New("no execution endpoints to check")
+	ErrUnexpectedCheckBlocks    = errors.New("unexpected check blocks count (must be positive)")
+	ErrUnexpectedCheckTimeout   = errors.New("unexpected check timeout (must be a positive duration)")
+	ErrUnexpectedCheckStaleness = errors.New("unexpected check max staleness (must be a positive duration)")
+)
+
+func CommandCheck(cfg *config.Config) *cli.Command {
+	executionEndpoints := &cli.StringSlice{}
+	externalExecutionEndpoints := &cli.StringSlice{}
+	executionEndpointWeights := &cli.StringSlice{}
+	referenceEndpoints := &cli.StringSlice{}
+
+	opts := server.CheckOptions{}
+
+	ethFlags := []cli.Flag{
+		&cli.StringSliceFlag{
+			Category:    categoryEth,
+			Destination: executionEndpoints,
+			EnvVars:     []string{"NODE_MONITOR_ETH_EL_ENDPOINTS"},
+			Name:        "eth-el-endpoint",
+			Usage:       "eth execution endpoints (websocket) in the format of `[namespace:]id=hostname:port`",
+		},
+
+		&cli.StringSliceFlag{
+			Category:    categoryEth,
+			Destination: externalExecutionEndpoints,
+			EnvVars:     []string{"NODE_MONITOR_ETH_EXT_EL_ENDPOINTS"},
+			Name:        "eth-ext-el-endpoint",
+			Usage:       "external eth execution endpoints (websocket) in the format of `[namespace:]id=hostname:port`",
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryEth,
+			Destination: &cfg.Eth.ResubscribeInterval,
+			EnvVars:     []string{"NODE_MONITOR_RESUBSCRIBE_INTERVAL"},
+			Name:        "resubscribe-interval",
+			Usage:       "an `interval` at which the check will try to (re-)subscribe to node events",
+			Value:       time.Second,
+		},
+	}
+
+	checkFlags := []cli.Flag{
+		&cli.Uint64Flag{
+			Category:    categoryCheck,
+			Destination: &opts.Blocks,
+			EnvVars:     []string{"NODE_MONITOR_CHECK_BLOCKS"},
+			Name:        "blocks",
+			Usage:       "`count` of blocks to observe (in each group) before reporting",
+			Value:       3,
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryCheck,
+			Destination: &opts.Timeout,
+			EnvVars:     []string{"NODE_MONITOR_CHECK_TIMEOUT"},
+			Name:        "timeout",
+			Usage:       "max `duration` to wait for the blocks before reporting",
+			Value:       time.Minute,
+		},
+
+		&cli.Uint64Flag{
+			Category:    categoryCheck,
+			Destination: &opts.MaxLag,
+			EnvVars:     []string{"NODE_MONITOR_CHECK_MAX_LAG"},
+			Name:        "max-lag",
+			Usage:       "max `count` of blocks the endpoint may be behind its group's head",
+			Value:       1,
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryCheck,
+			Destination: &opts.MaxStaleness,
+			EnvVars:     []string{"NODE_MONITOR_CHECK_MAX_STALENESS"},
+			Name:        "max-staleness",
+			Usage:       "max `duration` since the endpoint's latest block",
+			Value:       30 * time.Second,
+		},
+	}
+
+	flags := slices.Concat(
+		checkFlags,
+		ethFlags,
+		headFlags(cfg, executionEndpointWeights, referenceEndpoints),
+		leaderboardFlags(cfg),
+	)
+
+	return &cli.Command{
+		Name:  "check",
+		Usage: "observe the endpoints for a few blocks and report whether they are healthy",
+		Flags: flags,
+
+		Before: func(_ *cli.Context) error {
+			executionEndpoints, err := normaliseEndpoints(
+				executionEndpoints.Value(), "ws", ErrUnexpectedExecutionEndpoint,
+			)
+			if err != nil {
+				return err
+			}
+			cfg.Eth.ExecutionEndpoints = executionEndpoints
+
+			externalExecutionEndpoints, err := normaliseEndpoints(
+				externalExecutionEndpoints.Value(), "ws", ErrUnexpectedExecutionEndpoint,
+			)
+			if err != nil {
+				return err
+			}
+			cfg.Eth.ExternalExecutionEndpoints = externalExecutionEndpoints
+
+			if len(executionEndpoints)+len(externalExecutionEndpoints) == 0 {
+				return ErrCheckNoEndpoints
+			}
+
+			if err := parseHeadFlags(cfg, executionEndpointWeights, referenceEndpoints); err != nil {
+				return err
+			}
+			if err := parseLeaderboardFlags(cfg); err != nil {
+				return err
+			}
+
+			if opts.Blocks == 0 {
+				return fmt.Errorf("%w: %d", ErrUnexpectedCheckBlocks, opts.Blocks)
+			}
+			if opts.Timeout <= 0 {
+				return fmt.Errorf("%w: %s", ErrUnexpectedCheckTimeout, opts.Timeout)
+			}
+			if opts.MaxStaleness <= 0 {
+				return fmt.Errorf("%w: %s", ErrUnexpectedCheckStaleness, opts.MaxStaleness)
+			}
+			cfg.Server.Name = "node-monitor"
+
+			return nil
+		},
+
+		Action: func(clictx *cli.Context) error {
+			c, err := server.NewCheck(cfg, opts)
+			if err != nil {
+				return err
+			}
+			results, err := c.Run(clictx.Context)
+			if err != nil {
+				return err
+			}
+
+			if err := writeCheckResults(clictx.App.Writer, results); err != nil {
+				return err
+			}
+
+			failed := 0
+			for _, r := range results {
+				if !r.OK() {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%w: %d of %d",
+					ErrCheckFailed, failed, len(results),
+				)
+			}
+			return nil
+		},
+	}
+}
+
+func writeCheckResults(w io.Writer, results []*server.CheckResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "ENDPOINT\tKIND\tCHAIN\tHEAD\tLAG\tLAST BLOCK\tLATENCY (P50)\tSTATUS")
+	for _, r := range results {
+		chainID, head, lag, staleness, latency := "-", "-", "-", "-", "-"
+		if r.ChainID != nil {
+			chainID = r.ChainID.String()
+		}
+		if r.Head != 0 {
+			head = strconv.FormatUint(r.Head, 10)
+			lag = strconv.FormatUint(r.Lag, 10)
+			staleness = r.Staleness.Round(time.Millisecond).String() + " ago"
+		}
+		if r.Blocks > 0 {
+			latency = r.Latency.Round(time.Millisecond).String()
+		}
+		status := "ok"
+		if !r.OK() {
+			problems := make([]string, 0, len(r.Problems))
+			for _, p := range r.Problems {
+				problems = append(problems, string(p))
+			}
+			status = strings.Join(problems, ", ")
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.ID, r.Kind, chainID, head, lag, staleness, latency, status,
+		)
+	}
+
+	return tw.Flush()
+}
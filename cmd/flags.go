@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flashbots/node-monitor/config"
+	"github.com/flashbots/node-monitor/state"
+	"github.com/flashbots/node-monitor/utils"
+	"github.com/urfave/cli/v2"
+)
+
+// headFlags configure how the group's head is picked (shared by the commands
+// that maintain the state).
+func headFlags(
+	cfg *config.Config,
+	executionEndpointWeights *cli.StringSlice,
+	referenceEndpoints *cli.StringSlice,
+) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryEth,
+			Destination: &cfg.Eth.GroupHeadPolicy,
+			EnvVars:     []string{"NODE_MONITOR_GROUP_HEAD_POLICY"},
+			Name:        "group-head-policy",
+			Usage:       "`policy` to pick the group's head with (max, quorum, weighted)",
+			Value:       state.HeadPolicyMax,
+		},
+
+		&cli.IntFlag{
+			Category:    categoryEth,
+			Destination: &cfg.Eth.GroupHeadQuorum,
+			EnvVars:     []string{"NODE_MONITOR_GROUP_HEAD_QUORUM"},
+			Name:        "group-head-quorum",
+			Usage:       "`count` of the endpoints that must reach the block for it to become the group's head (with quorum policy)",
+			Value:       2,
+		},
+
+		&cli.Float64Flag{
+			Category:    categoryEth,
+			Destination: &cfg.Eth.GroupHeadWeightThreshold,
+			EnvVars:     []string{"NODE_MONITOR_GROUP_HEAD_WEIGHT_THRESHOLD"},
+			Name:        "group-head-weight-threshold",
+			Usage:       "`share` of the group's total weight that must reach the block for it to become the group's head (with weighted policy)",
+			Value:       0.5,
+		},
+
+		&cli.StringSliceFlag{
+			Category:    categoryEth,
+			Destination: executionEndpointWeights,
+			EnvVars:     []string{"NODE_MONITOR_ETH_EL_ENDPOINT_WEIGHTS"},
+			Name:        "eth-el-endpoint-weight",
+			Usage:       "trust weights of execution endpoints in the format of `[namespace:]id=weight` (default weight is 1)",
+		},
+
+		&cli.StringSliceFlag{
+			Category:    categoryEth,
+			Destination: referenceEndpoints,
+			EnvVars:     []string{"NODE_MONITOR_ETH_REFERENCE_ENDPOINTS"},
+			Name:        "eth-reference-endpoint",
+			Usage:       "`[namespace:]id` of the execution endpoint that contributes to the latency, but not to the group's head",
+		},
+	}
+}
+
+// labelFlags configure the labels that the endpoints' metrics are tagged with.
+func labelFlags(
+	cfg *config.Config,
+	executionEndpointLabels *cli.StringSlice,
+	executionEndpointLabelKeys *cli.StringSlice,
+) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Category:    categoryEth,
+			Destination: executionEndpointLabels,
+			EnvVars:     []string{"NODE_MONITOR_ETH_EL_ENDPOINT_LABELS"},
+			Name:        "eth-el-endpoint-label",
+			Usage:       "labels of execution endpoints (e.g. region, client, provider or role) in the format of `[namespace:]id=key=value`",
+		},
+
+		&cli.StringSliceFlag{
+			Category:    categoryEth,
+			Destination: executionEndpointLabelKeys,
+			EnvVars:     []string{"NODE_MONITOR_ETH_EL_ENDPOINT_LABEL_KEYS"},
+			Name:        "eth-el-endpoint-label-key",
+			Usage:       "`key` of the execution endpoint label that is exported with the metrics (the rest of the labels are ignored)",
+			Value:       cli.NewStringSlice("client", "provider", "region", "role"),
+		},
+	}
+}
+
+// slotFlags configure how the block production delays are derived.
+func slotFlags(cfg *config.Config) []cli.Flag {
+	return []cli.Flag{
+		&cli.Int64Flag{
+			Category:    categoryEth,
+			Destination: &cfg.Eth.GenesisTime,
+			EnvVars:     []string{"NODE_MONITOR_ETH_GENESIS_TIME"},
+			Name:        "eth-genesis-time",
+			Usage:       "unix `timestamp` of the beacon chain genesis to derive slot start times from (0 to disable)",
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryEth,
+			Destination: &cfg.Eth.SlotDuration,
+			EnvVars:     []string{"NODE_MONITOR_ETH_SLOT_DURATION"},
+			Name:        "eth-slot-duration",
+			Usage:       "`duration` of the beacon chain slot",
+			Value:       12 * time.Second,
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryEth,
+			Destination: &cfg.Eth.MaxClockSkew,
+			EnvVars:     []string{"NODE_MONITOR_MAX_CLOCK_SKEW"},
+			Name:        "max-clock-skew",
+			Usage:       "max `duration` by which a block may arrive before its timestamp (larger skews are not reported)",
+			Value:       time.Second,
+		},
+	}
+}
+
+func leaderboardFlags(cfg *config.Config) []cli.Flag {
+	return []cli.Flag{
+		&cli.Uint64Flag{
+			Category:    categoryLeaderboard,
+			Destination: &cfg.Leaderboard.Horizon,
+			EnvVars:     []string{"NODE_MONITOR_LEADERBOARD_HORIZON"},
+			Name:        "leaderboard-horizon",
+			Usage:       "`count` of blocks the group's head must move ahead before an unreported block is considered as missed by the endpoint",
+			Value:       8,
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryLeaderboard,
+			Destination: &cfg.Leaderboard.Threshold,
+			EnvVars:     []string{"NODE_MONITOR_LEADERBOARD_THRESHOLD"},
+			Name:        "leaderboard-threshold",
+			Usage:       "max `latency` after the first arrival within which the block is considered as seen in time",
+			Value:       50 * time.Millisecond,
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryLeaderboard,
+			Destination: &cfg.Leaderboard.Window,
+			EnvVars:     []string{"NODE_MONITOR_LEADERBOARD_WINDOW"},
+			Name:        "leaderboard-window",
+			Usage:       "`duration` of the sliding window to rank the endpoints over",
+			Value:       time.Hour,
+		},
+	}
+}
+
+func statsFlags(
+	cfg *config.Config,
+	statsQuantiles *cli.Float64Slice,
+	statsWindows *cli.StringSlice,
+) []cli.Flag {
+	return []cli.Flag{
+		&cli.Float64SliceFlag{
+			Category:    categoryStats,
+			Destination: statsQuantiles,
+			EnvVars:     []string{"NODE_MONITOR_STATS_QUANTILES"},
+			Name:        "stats-quantile",
+			Usage:       "`quantile` of the new block latency to estimate over sliding windows",
+			Value:       cli.NewFloat64Slice(0.5, 0.9, 0.95, 0.99),
+		},
+
+		&cli.StringSliceFlag{
+			Category:    categoryStats,
+			Destination: statsWindows,
+			EnvVars:     []string{"NODE_MONITOR_STATS_WINDOWS"},
+			Name:        "stats-window",
+			Usage:       "`duration` of the sliding window to estimate the new block latency quantiles over",
+			Value:       cli.NewStringSlice("5m", "1h", "24h"),
+		},
+	}
+}
+
+func parseHeadFlags(
+	cfg *config.Config,
+	executionEndpointWeights *cli.StringSlice,
+	referenceEndpoints *cli.StringSlice,
+) error {
+	weights := executionEndpointWeights.Value()
+	for idx, w := range weights {
+		w = strings.TrimSpace(w)
+		id, weight, found := strings.Cut(w, "=")
+		if !found {
+			return fmt.Errorf("%w: %s", ErrUnexpectedEndpointWeight, w)
+		}
+		id = strings.TrimSpace(id)
+		weight = strings.TrimSpace(weight)
+		if _, _, err := utils.ParseELEndpointID(id); err != nil {
+			return err
+		}
+		if v, err := strconv.ParseFloat(weight, 64); err != nil || v < 0 {
+			return fmt.Errorf("%w: %s", ErrUnexpectedEndpointWeight, w)
+		}
+		weights[idx] = id + "=" + weight
+	}
+	cfg.Eth.ExecutionEndpointWeights = weights
+
+	references := referenceEndpoints.Value()
+	for idx, id := range references {
+		id = strings.TrimSpace(id)
+		if _, _, err := utils.ParseELEndpointID(id); err != nil {
+			return err
+		}
+		references[idx] = id
+	}
+	cfg.Eth.ReferenceEndpoints = references
+
+	return nil
+}
+
+// parseLabelFlags only tidies up the labels, the server is the one to parse
+// and validate them.
+func parseLabelFlags(
+	cfg *config.Config,
+	executionEndpointLabels *cli.StringSlice,
+	executionEndpointLabelKeys *cli.StringSlice,
+) {
+	labels := executionEndpointLabels.Value()
+	for idx, label := range labels {
+		labels[idx] = strings.TrimSpace(label)
+	}
+	cfg.Eth.ExecutionEndpointLabels = labels
+
+	keys := executionEndpointLabelKeys.Value()
+	for idx, key := range keys {
+		keys[idx] = strings.TrimSpace(key)
+	}
+	cfg.Eth.ExecutionEndpointLabelKeys = keys
+}
+
+func parseLeaderboardFlags(cfg *config.Config) error {
+	if cfg.Leaderboard.Horizon == 0 {
+		return fmt.Errorf("%w: %d", ErrUnexpectedLeaderboardHorizon, cfg.Leaderboard.Horizon)
+	}
+	if cfg.Leaderboard.Window <= 0 {
+		return fmt.Errorf("%w: %s", ErrUnexpectedLeaderboardWindow, cfg.Leaderboard.Window)
+	}
+
+	return nil
+}
+
+func parseStatsFlags(
+	cfg *config.Config,
+	statsQuantiles *cli.Float64Slice,
+	statsWindows *cli.StringSlice,
+) error {
+	quantiles := statsQuantiles.Value()
+	for _, q := range quantiles {
+		if q < 0 || q > 1 {
+			return fmt.Errorf("%w: %f", ErrUnexpectedStatsQuantile, q)
+		}
+	}
+	cfg.Stats.Quantiles = quantiles
+
+	windows := make([]time.Duration, 0, len(statsWindows.Value()))
+	for _, w := range statsWindows.Value() {
+		window, err := time.ParseDuration(strings.TrimSpace(w))
+		if err != nil || window <= 0 {
+			return fmt.Errorf("%w: %s", ErrUnexpectedStatsWindow, w)
+		}
+		windows = append(windows, window)
+	}
+	cfg.Stats.Windows = windows
+
+	return nil
+}
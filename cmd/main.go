@@ -37,6 +37,9 @@ func main() {
 
 	commands := []*cli.Command{
 		CommandServe(cfg),
+		CommandReplay(cfg),
+		CommandCheck(cfg),
+		CommandTop(cfg),
 	}
 
 	app := &cli.App{
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/flashbots/node-monitor/config"
+	"github.com/flashbots/node-monitor/eventlog"
+	"github.com/flashbots/node-monitor/server"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	categoryReplay = "REPLAY:"
+
+	replayOutputMetrics = "metrics"
+	replayOutputReport  = "report"
+)
+
+var (
+	ErrUnexpectedReplayOutput = errors.New("unexpected replay output format (must be one of: metrics, report)")
+)
+
+func CommandReplay(cfg *config.Config) *cli.Command {
+	executionEndpointLabels := &cli.StringSlice{}
+	executionEndpointLabelKeys := &cli.StringSlice{}
+	executionEndpointWeights := &cli.StringSlice{}
+	referenceEndpoints := &cli.StringSlice{}
+	statsQuantiles := &cli.Float64Slice{}
+	statsWindows := &cli.StringSlice{}
+
+	var input, output string
+
+	replayFlags := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryReplay,
+			Destination: &input,
+			EnvVars:     []string{"NODE_MONITOR_REPLAY_INPUT"},
+			Name:        "input",
+			Required:    true,
+			Usage:       "`path` to the (optionally gzipped) event log to replay (- for stdin)",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryReplay,
+			Destination: &output,
+			EnvVars:     []string{"NODE_MONITOR_REPLAY_OUTPUT"},
+			Name:        "output",
+			Usage:       "`format` of the output (metrics, report)",
+			Value:       replayOutputReport,
+		},
+	}
+
+	flags := slices.Concat(
+		replayFlags,
+		headFlags(cfg, executionEndpointWeights, referenceEndpoints),
+		labelFlags(cfg, executionEndpointLabels, executionEndpointLabelKeys),
+		slotFlags(cfg),
+		leaderboardFlags(cfg),
+		statsFlags(cfg, statsQuantiles, statsWindows),
+	)
+
+	return &cli.Command{
+		Name:  "replay",
+		Usage: "replay the recorded header arrivals and report the resulting metrics",
+		Flags: flags,
+
+		Before: func(_ *cli.Context) error {
+			if output != replayOutputMetrics && output != replayOutputReport {
+				return fmt.Errorf("%w: %s", ErrUnexpectedReplayOutput, output)
+			}
+			if err := parseHeadFlags(cfg, executionEndpointWeights, referenceEndpoints); err != nil {
+				return err
+			}
+			if err := parseLeaderboardFlags(cfg); err != nil {
+				return err
+			}
+			parseLabelFlags(cfg, executionEndpointLabels, executionEndpointLabelKeys)
+			if err := parseStatsFlags(cfg, statsQuantiles, statsWindows); err != nil {
+				return err
+			}
+			cfg.Server.Name = "node-monitor"
+
+			return nil
+		},
+
+		Action: func(clictx *cli.Context) error {
+			var r io.Reader = os.Stdin
+			if input != "-" {
+				f, err := os.Open(input)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+
+			reader, err := eventlog.NewReader(r)
+			if err != nil {
+				return err
+			}
+			events, err := reader.ReadAll()
+			if err != nil {
+				return err
+			}
+
+			s, err := server.NewReplay(cfg, events)
+			if err != nil {
+				return err
+			}
+			if err := s.Replay(clictx.Context, events); err != nil {
+				return err
+			}
+
+			w := clictx.App.Writer
+			if output == replayOutputMetrics {
+				return s.WriteMetrics(w)
+			}
+			return s.WriteReport(w)
+		},
+	}
+}
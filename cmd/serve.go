@@ -9,22 +9,49 @@ import (
 
 	"github.com/flashbots/node-monitor/config"
 	"github.com/flashbots/node-monitor/server"
+	"github.com/flashbots/node-monitor/state"
 	"github.com/flashbots/node-monitor/utils"
 	"github.com/urfave/cli/v2"
 )
 
 const (
-	categoryEth    = "ETHEREUM:"
-	categoryServer = "SERVER:"
+	categoryBest        = "BEST ENDPOINT:"
+	categoryClock       = "CLOCK:"
+	categoryEth         = "ETHEREUM:"
+	categoryFederation  = "FEDERATION:"
+	categoryLeaderboard = "LEADERBOARD:"
+	categoryProbe       = "PROBE:"
+	categoryProxy       = "PROXY:"
+	categoryRecord      = "RECORD:"
+	categoryServer      = "SERVER:"
+	categoryStats       = "STATS:"
+	categoryTracing     = "TRACING:"
 )
 
 var (
-	ErrUnexpectedExecutionEndpoint = errors.New("unexpected execution endpoint rpc (must look like `id=127.0.0.1:8546`)")
+	ErrUnexpectedEngineEndpoint     = errors.New("unexpected engine endpoint rpc (must look like `id=127.0.0.1:8551`)")
+	ErrUnexpectedEndpointWeight     = errors.New("unexpected execution endpoint weight (must look like `id=0.5`)")
+	ErrUnexpectedExecutionEndpoint  = errors.New("unexpected execution endpoint rpc (must look like `id=127.0.0.1:8546`)")
+	ErrUnexpectedLeaderboardHorizon = errors.New("unexpected leaderboard horizon (must be positive)")
+	ErrUnexpectedLeaderboardWindow  = errors.New("unexpected leaderboard window (must be a positive duration)")
+	ErrUnexpectedNTPInterval        = errors.New("unexpected ntp probe interval (must be a positive duration)")
+	ErrUnexpectedRecordMaxSize      = errors.New("unexpected record max size (must not be negative)")
+	ErrUnexpectedStatsQuantile      = errors.New("unexpected stats quantile (must be within [0, 1])")
+	ErrUnexpectedStatsWindow        = errors.New("unexpected stats window (must be a positive duration)")
 )
 
 func CommandServe(cfg *config.Config) *cli.Command {
 	executionEndpoints := &cli.StringSlice{}
 	externalExecutionEndpoints := &cli.StringSlice{}
+	engineEndpoints := &cli.StringSlice{}
+	engineJWTSecrets := &cli.StringSlice{}
+	engineRequiredCapabilities := &cli.StringSlice{}
+	executionEndpointLabels := &cli.StringSlice{}
+	executionEndpointLabelKeys := &cli.StringSlice{}
+	executionEndpointWeights := &cli.StringSlice{}
+	referenceEndpoints := &cli.StringSlice{}
+	statsQuantiles := &cli.Float64Slice{}
+	statsWindows := &cli.StringSlice{}
 
 	ethFlags := []cli.Flag{
 		&cli.StringSliceFlag{
@@ -51,6 +78,285 @@ func CommandServe(cfg *config.Config) *cli.Command {
 			Usage:       "an `interval` at which the monitor will try to (re-)subscribe to node events",
 			Value:       5 * time.Second,
 		},
+
+		&cli.BoolFlag{
+			Category:    categoryEth,
+			Destination: &cfg.Eth.BackfillSkippedBlocks,
+			EnvVars:     []string{"NODE_MONITOR_BACKFILL_SKIPPED_BLOCKS"},
+			Name:        "backfill-skipped-blocks",
+			Usage:       "fetch the headers of the blocks that an endpoint jumped over without reporting them",
+		},
+
+		&cli.BoolFlag{
+			Category:    categoryEth,
+			Destination: &cfg.Eth.ValidateBlocks,
+			EnvVars:     []string{"NODE_MONITOR_VALIDATE_BLOCKS"},
+			Name:        "validate-blocks",
+			Usage:       "fetch full block and receipts for every new header and validate their consistency",
+		},
+
+		&cli.BoolFlag{
+			Category:    categoryEth,
+			Destination: &cfg.Eth.FetchTransactionCount,
+			EnvVars:     []string{"NODE_MONITOR_FETCH_TRANSACTION_COUNT"},
+			Name:        "fetch-transaction-count",
+			Usage:       "fetch the transaction count of every new head of the group (the headers do not carry it)",
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryEth,
+			Destination: &cfg.Eth.BlockFetchTimeout,
+			EnvVars:     []string{"NODE_MONITOR_BLOCK_FETCH_TIMEOUT"},
+			Name:        "block-fetch-timeout",
+			Usage:       "max `duration` to wait for the block body to become available (when validating blocks or fetching transaction counts)",
+			Value:       12 * time.Second,
+		},
+
+		&cli.StringSliceFlag{
+			Category:    categoryEth,
+			Destination: engineEndpoints,
+			EnvVars:     []string{"NODE_MONITOR_ETH_ENGINE_ENDPOINTS"},
+			Name:        "eth-engine-endpoint",
+			Usage:       "engine api (authrpc) endpoints of the execution clients in the format of `[namespace:]id=hostname:port` (id must match the one of execution endpoint)",
+		},
+
+		&cli.StringSliceFlag{
+			Category:    categoryEth,
+			Destination: engineJWTSecrets,
+			EnvVars:     []string{"NODE_MONITOR_ETH_ENGINE_JWT_SECRETS"},
+			Name:        "eth-engine-jwt-secret",
+			Usage:       "paths to engine api jwt secrets in the format of `[[namespace:]id=]path` (secret without id is used for all engine endpoints)",
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryEth,
+			Destination: &cfg.Eth.EngineProbeInterval,
+			EnvVars:     []string{"NODE_MONITOR_ETH_ENGINE_PROBE_INTERVAL"},
+			Name:        "eth-engine-probe-interval",
+			Usage:       "an `interval` at which the monitor will probe the engine api endpoints",
+			Value:       30 * time.Second,
+		},
+
+		&cli.StringSliceFlag{
+			Category:    categoryEth,
+			Destination: engineRequiredCapabilities,
+			EnvVars:     []string{"NODE_MONITOR_ETH_ENGINE_REQUIRED_CAPABILITIES"},
+			Name:        "eth-engine-required-capability",
+			Usage:       "engine api `method` that the execution client must support",
+			Value: cli.NewStringSlice(
+				"engine_forkchoiceUpdatedV3",
+				"engine_getPayloadV3",
+				"engine_newPayloadV3",
+			),
+		},
+	}
+
+	probeMethods := &cli.StringSlice{}
+
+	probeFlags := []cli.Flag{
+		&cli.DurationFlag{
+			Category:    categoryProbe,
+			Destination: &cfg.Probe.Interval,
+			EnvVars:     []string{"NODE_MONITOR_PROBE_INTERVAL"},
+			Name:        "probe-interval",
+			Usage:       "an `interval` at which the monitor will probe rpc response times of execution endpoints (0 to disable)",
+		},
+
+		&cli.StringSliceFlag{
+			Category:    categoryProbe,
+			Destination: probeMethods,
+			EnvVars:     []string{"NODE_MONITOR_PROBE_METHODS"},
+			Name:        "probe-method",
+			Usage:       "rpc `method` to probe (eth_blockNumber, eth_call, eth_estimateGas, eth_getBalance)",
+			Value:       cli.NewStringSlice("eth_blockNumber"),
+		},
+
+		&cli.StringFlag{
+			Category:    categoryProbe,
+			Destination: &cfg.Probe.Address,
+			EnvVars:     []string{"NODE_MONITOR_PROBE_ADDRESS"},
+			Name:        "probe-address",
+			Usage:       "`address` to query the balance of and to estimate gas from",
+			Value:       "0x0000000000000000000000000000000000000000",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryProbe,
+			Destination: &cfg.Probe.CallTo,
+			EnvVars:     []string{"NODE_MONITOR_PROBE_CALL_TO"},
+			Name:        "probe-call-to",
+			Usage:       "contract `address` to invoke with eth_call and eth_estimateGas probes",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryProbe,
+			Destination: &cfg.Probe.CallData,
+			EnvVars:     []string{"NODE_MONITOR_PROBE_CALL_DATA"},
+			Name:        "probe-call-data",
+			Usage:       "hex-encoded call `data` for eth_call and eth_estimateGas probes",
+		},
+	}
+
+	bestFlags := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryBest,
+			Destination: &cfg.Best.Policy,
+			EnvVars:     []string{"NODE_MONITOR_BEST_POLICY"},
+			Name:        "best-policy",
+			Usage:       "`policy` to score the group's healthy endpoints with when picking the best one (lag, latency, weighted)",
+			Value:       state.BestPolicyLag,
+		},
+
+		&cli.Uint64Flag{
+			Category:    categoryBest,
+			Destination: &cfg.Best.MaxLag,
+			EnvVars:     []string{"NODE_MONITOR_BEST_MAX_LAG"},
+			Name:        "best-max-lag",
+			Usage:       "max `count` of blocks the endpoint may be behind its group's head and still be considered healthy",
+			Value:       1,
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryBest,
+			Destination: &cfg.Best.MaxStaleness,
+			EnvVars:     []string{"NODE_MONITOR_BEST_MAX_STALENESS"},
+			Name:        "best-max-staleness",
+			Usage:       "max `duration` since the endpoint's last block for it to be considered healthy (0 to disable)",
+			Value:       30 * time.Second,
+		},
+
+		&cli.Float64Flag{
+			Category:    categoryBest,
+			Destination: &cfg.Best.LagWeight,
+			EnvVars:     []string{"NODE_MONITOR_BEST_LAG_WEIGHT"},
+			Name:        "best-lag-weight",
+			Usage:       "`weight` of each block of lag (with weighted policy)",
+			Value:       1,
+		},
+
+		&cli.Float64Flag{
+			Category:    categoryBest,
+			Destination: &cfg.Best.StalenessWeight,
+			EnvVars:     []string{"NODE_MONITOR_BEST_STALENESS_WEIGHT"},
+			Name:        "best-staleness-weight",
+			Usage:       "`weight` of each second since the endpoint's last block (with weighted policy)",
+			Value:       0,
+		},
+
+		&cli.Float64Flag{
+			Category:    categoryBest,
+			Destination: &cfg.Best.LatencyWeight,
+			EnvVars:     []string{"NODE_MONITOR_BEST_LATENCY_WEIGHT"},
+			Name:        "best-latency-weight",
+			Usage:       "`weight` of each second of the endpoint's median new block latency (with weighted policy)",
+			Value:       10,
+		},
+	}
+
+	ntpServers := &cli.StringSlice{}
+
+	clockFlags := []cli.Flag{
+		&cli.StringSliceFlag{
+			Category:    categoryClock,
+			Destination: ntpServers,
+			EnvVars:     []string{"NODE_MONITOR_NTP_SERVERS"},
+			Name:        "ntp-server",
+			Usage:       "`host[:port]` of the (s)ntp server to measure the local clock's offset against",
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryClock,
+			Destination: &cfg.Clock.NTPInterval,
+			EnvVars:     []string{"NODE_MONITOR_NTP_INTERVAL"},
+			Name:        "ntp-interval",
+			Usage:       "an `interval` at which the monitor will query the ntp servers",
+			Value:       time.Minute,
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryClock,
+			Destination: &cfg.Clock.MaxOffset,
+			EnvVars:     []string{"NODE_MONITOR_MAX_CLOCK_OFFSET"},
+			Name:        "max-clock-offset",
+			Usage:       "max `duration` by which the local clock may be off the ntp server's one before the monitor warns about it (0 to disable)",
+			Value:       100 * time.Millisecond,
+		},
+
+		&cli.IntFlag{
+			Category:    categoryClock,
+			Destination: &cfg.Clock.SkewBlocks,
+			EnvVars:     []string{"NODE_MONITOR_CLOCK_SKEW_BLOCKS"},
+			Name:        "clock-skew-blocks",
+			Usage:       "`count` of the recent blocks to sanity check the monitor's clock against the header timestamps over (0 to disable)",
+			Value:       64,
+		},
+	}
+
+	federationPeers := &cli.StringSlice{}
+
+	federationFlags := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryFederation,
+			Destination: &cfg.Federation.Region,
+			EnvVars:     []string{"NODE_MONITOR_FEDERATION_REGION"},
+			Name:        "federation-region",
+			Usage:       "`name` of the region this monitor observes the blocks from (federation is disabled if empty)",
+		},
+
+		&cli.StringSliceFlag{
+			Category:    categoryFederation,
+			Destination: federationPeers,
+			EnvVars:     []string{"NODE_MONITOR_FEDERATION_PEERS"},
+			Name:        "federation-peer",
+			Usage:       "`region=url` of the peer monitor in the other region to exchange the block arrivals with",
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryFederation,
+			Destination: &cfg.Federation.Settle,
+			EnvVars:     []string{"NODE_MONITOR_FEDERATION_SETTLE"},
+			Name:        "federation-settle",
+			Usage:       "`duration` to wait for the arrivals from the peers before accounting the block",
+			Value:       4 * time.Second,
+		},
+
+		&cli.StringFlag{
+			Category:    categoryFederation,
+			Destination: &cfg.Federation.Token,
+			EnvVars:     []string{"NODE_MONITOR_FEDERATION_TOKEN"},
+			Name:        "federation-token",
+			Usage:       "shared `secret` that the peers authenticate each other with",
+		},
+	}
+
+	proxyMethods := &cli.StringSlice{}
+
+	proxyFlags := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryProxy,
+			Destination: &cfg.Proxy.ListenAddress,
+			EnvVars:     []string{"NODE_MONITOR_PROXY_LISTEN_ADDRESS"},
+			Name:        "proxy-listen-address",
+			Usage:       "`host:port` for the json-rpc proxy to the best endpoints of the groups to listen on (proxy is disabled if empty)",
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryProxy,
+			Destination: &cfg.Proxy.Timeout,
+			EnvVars:     []string{"NODE_MONITOR_PROXY_TIMEOUT"},
+			Name:        "proxy-timeout",
+			Usage:       "max `duration` to wait for the endpoint to respond (the requests that time out are not retried with the next healthy endpoint)",
+			Value:       10 * time.Second,
+		},
+
+		&cli.StringSliceFlag{
+			Category:    categoryProxy,
+			Destination: proxyMethods,
+			EnvVars:     []string{"NODE_MONITOR_PROXY_METHODS"},
+			Name:        "proxy-method",
+			Usage:       "json-rpc `method` that the proxy forwards (the ones ending with `*` allow all the methods with the prefix; the subscriptions and the filters are only proxied over websocket)",
+			Value:       cli.NewStringSlice("eth_*", "net_*", "web3_*"),
+		},
 	}
 
 	serverFlags := []cli.Flag{
@@ -73,9 +379,78 @@ func CommandServe(cfg *config.Config) *cli.Command {
 		},
 	}
 
+	var recordMaxSize int64
+
+	recordFlags := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryRecord,
+			Destination: &cfg.Record.Path,
+			EnvVars:     []string{"NODE_MONITOR_RECORD"},
+			Name:        "record",
+			Usage:       "`path` prefix of the (rotated, gzipped) files to record all received headers to (recording is disabled if empty)",
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryRecord,
+			Destination: &cfg.Record.MaxAge,
+			EnvVars:     []string{"NODE_MONITOR_RECORD_MAX_AGE"},
+			Name:        "record-max-age",
+			Usage:       "`duration` after which the record file is rotated (0 to disable)",
+			Value:       24 * time.Hour,
+		},
+
+		&cli.Int64Flag{
+			Category:    categoryRecord,
+			Destination: &recordMaxSize,
+			EnvVars:     []string{"NODE_MONITOR_RECORD_MAX_SIZE"},
+			Name:        "record-max-size",
+			Usage:       "`megabytes` of (uncompressed) headers after which the record file is rotated (0 to disable)",
+			Value:       256,
+		},
+	}
+
+	tracingFlags := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryTracing,
+			Destination: &cfg.Tracing.Endpoint,
+			EnvVars:     []string{"NODE_MONITOR_TRACING_ENDPOINT"},
+			Name:        "tracing-endpoint",
+			Usage:       "`host:port` of the otlp/http collector to send the traces to (tracing is disabled if empty)",
+		},
+
+		&cli.BoolFlag{
+			Category:    categoryTracing,
+			Destination: &cfg.Tracing.Insecure,
+			EnvVars:     []string{"NODE_MONITOR_TRACING_INSECURE"},
+			Name:        "tracing-insecure",
+			Usage:       "send the traces over plain http instead of https",
+		},
+
+		&cli.Float64Flag{
+			Category:    categoryTracing,
+			Destination: &cfg.Tracing.SampleRatio,
+			EnvVars:     []string{"NODE_MONITOR_TRACING_SAMPLE_RATIO"},
+			Name:        "tracing-sample-ratio",
+			Usage:       "`ratio` of the traces to sample (from 0.0 to 1.0)",
+			Value:       1.0,
+		},
+	}
+
 	flags := slices.Concat(
 		ethFlags,
+		bestFlags,
+		clockFlags,
+		federationFlags,
+		headFlags(cfg, executionEndpointWeights, referenceEndpoints),
+		labelFlags(cfg, executionEndpointLabels, executionEndpointLabelKeys),
+		slotFlags(cfg),
+		leaderboardFlags(cfg),
+		probeFlags,
+		proxyFlags,
+		recordFlags,
 		serverFlags,
+		statsFlags(cfg, statsQuantiles, statsWindows),
+		tracingFlags,
 	)
 
 	return &cli.Command{
@@ -84,34 +459,79 @@ func CommandServe(cfg *config.Config) *cli.Command {
 		Flags: flags,
 
 		Before: func(ctx *cli.Context) error {
-			executionEndpoints := slices.Concat(
-				executionEndpoints.Value(),
-				externalExecutionEndpoints.Value(),
+			executionEndpoints, err := normaliseEndpoints(
+				executionEndpoints.Value(), "ws", ErrUnexpectedExecutionEndpoint,
 			)
-			for idx, ee := range executionEndpoints {
-				ee = strings.TrimSpace(ee)
-				parts := strings.Split(ee, "=")
-				if len(parts) != 2 {
-					return fmt.Errorf("%w: %s", ErrUnexpectedExecutionEndpoint, ee)
-				}
-				for idx, part := range parts {
-					parts[idx] = strings.TrimSpace(part)
-				}
-				id := parts[0]
-				if _, _, err := utils.ParseELEndpointID(id); err != nil {
-					return err
-				}
-				uri := parts[1]
-				parsed, err := utils.ParseRawURI(uri)
-				if err != nil {
-					return err
-				}
-				if parsed.Scheme == "" {
-					parsed.Scheme = "ws"
-				}
-				executionEndpoints[idx] = fmt.Sprintf("%s=%s", id, parsed.String())
+			if err != nil {
+				return err
 			}
 			cfg.Eth.ExecutionEndpoints = executionEndpoints
+
+			externalExecutionEndpoints, err := normaliseEndpoints(
+				externalExecutionEndpoints.Value(), "ws", ErrUnexpectedExecutionEndpoint,
+			)
+			if err != nil {
+				return err
+			}
+			cfg.Eth.ExternalExecutionEndpoints = externalExecutionEndpoints
+
+			engineEndpoints, err := normaliseEndpoints(
+				engineEndpoints.Value(), "http", ErrUnexpectedEngineEndpoint,
+			)
+			if err != nil {
+				return err
+			}
+			cfg.Eth.EngineEndpoints = engineEndpoints
+
+			engineJWTSecrets := engineJWTSecrets.Value()
+			for idx, secret := range engineJWTSecrets {
+				secret = strings.TrimSpace(secret)
+				if id, _, found := strings.Cut(secret, "="); found {
+					if _, _, err := utils.ParseELEndpointID(strings.TrimSpace(id)); err != nil {
+						return err
+					}
+				}
+				engineJWTSecrets[idx] = secret
+			}
+			cfg.Eth.EngineJWTSecrets = engineJWTSecrets
+			cfg.Eth.EngineRequiredCapabilities = engineRequiredCapabilities.Value()
+
+			if err := parseHeadFlags(cfg, executionEndpointWeights, referenceEndpoints); err != nil {
+				return err
+			}
+			if err := parseLeaderboardFlags(cfg); err != nil {
+				return err
+			}
+			parseLabelFlags(cfg, executionEndpointLabels, executionEndpointLabelKeys)
+			if err := parseStatsFlags(cfg, statsQuantiles, statsWindows); err != nil {
+				return err
+			}
+
+			cfg.Clock.NTPServers = ntpServers.Value()
+			if len(cfg.Clock.NTPServers) > 0 && cfg.Clock.NTPInterval <= 0 {
+				return fmt.Errorf("%w: %s", ErrUnexpectedNTPInterval, cfg.Clock.NTPInterval)
+			}
+
+			cfg.Federation.Peers = federationPeers.Value()
+
+			proxyMethods := proxyMethods.Value()
+			for idx, method := range proxyMethods {
+				proxyMethods[idx] = strings.TrimSpace(method)
+			}
+			cfg.Proxy.Methods = proxyMethods
+
+			if recordMaxSize < 0 {
+				return fmt.Errorf("%w: %d", ErrUnexpectedRecordMaxSize, recordMaxSize)
+			}
+			cfg.Record.MaxSize = recordMaxSize * 1024 * 1024
+
+			// the methods are validated by the subscribers
+			methods := probeMethods.Value()
+			for idx, method := range methods {
+				methods[idx] = strings.TrimSpace(method)
+			}
+			cfg.Probe.Methods = methods
+
 			return nil
 		},
 
@@ -124,3 +544,33 @@ func CommandServe(cfg *config.Config) *cli.Command {
 		},
 	}
 }
+
+func normaliseEndpoints(endpoints []string, defaultScheme string, errUnexpected error) (
+	[]string, error,
+) {
+	res := make([]string, 0, len(endpoints))
+	for _, ee := range endpoints {
+		ee = strings.TrimSpace(ee)
+		parts := strings.Split(ee, "=")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%w: %s", errUnexpected, ee)
+		}
+		for idx, part := range parts {
+			parts[idx] = strings.TrimSpace(part)
+		}
+		id := parts[0]
+		if _, _, err := utils.ParseELEndpointID(id); err != nil {
+			return nil, err
+		}
+		uri := parts[1]
+		parsed, err := utils.ParseRawURI(uri)
+		if err != nil {
+			return nil, err
+		}
+		if parsed.Scheme == "" {
+			parsed.Scheme = defaultScheme
+		}
+		res = append(res, fmt.Sprintf("%s=%s", id, parsed.String()))
+	}
+	return res, nil
+}
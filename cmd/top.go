@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/flashbots/node-monitor/config"
+	"github.com/flashbots/node-monitor/tui"
+	"github.com/flashbots/node-monitor/utils"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	categoryTop = "TOP:"
+)
+
+var (
+	ErrUnexpectedTopInterval = errors.New("unexpected top refresh interval (must be a positive duration)")
+	ErrUnexpectedTopWindow   = errors.New("unexpected top window (must be a positive duration)")
+)
+
+func CommandTop(cfg *config.Config) *cli.Command {
+	topFlags := []cli.Flag{
+		&cli.StringFlag{
+			Category:    categoryTop,
+			Destination: &cfg.Top.URL,
+			EnvVars:     []string{"NODE_MONITOR_TOP_URL"},
+			Name:        "url",
+			Usage:       "base `url` of the running monitor's api",
+			Value:       "http://127.0.0.1:8080",
+		},
+
+		&cli.DurationFlag{
+			Category:    categoryTop,
+			Destination: &cfg.Top.Interval,
+			EnvVars:     []string{"NODE_MONITOR_TOP_INTERVAL"},
+			Name:        "interval",
+			Usage:       "an `interval` at which the dashboard is refreshed",
+			Value:       time.Second,
+		},
+
+		&cli.StringFlag{
+			Category:    categoryTop,
+			Destination: &cfg.Top.Sort,
+			EnvVars:     []string{"NODE_MONITOR_TOP_SORT"},
+			Name:        "sort",
+			Usage:       "`column` to sort the endpoints by (" + strings.Join(tui.SortKeys, ", ") + ")",
+			Value:       tui.SortName,
+		},
+
+		&cli.StringFlag{
+			Category:    categoryTop,
+			Destination: &cfg.Top.Filter,
+			EnvVars:     []string{"NODE_MONITOR_TOP_FILTER"},
+			Name:        "filter",
+			Usage:       "show only the endpoints whose `[namespace:]id` contains the substring",
+		},
+
+		&cli.StringFlag{
+			Category:    categoryTop,
+			Destination: &cfg.Top.Window,
+			EnvVars:     []string{"NODE_MONITOR_TOP_WINDOW"},
+			Name:        "window",
+			Usage:       "stats `window` to show the latency quantiles over (the shortest one if empty)",
+		},
+	}
+
+	return &cli.Command{
+		Name:  "top",
+		Usage: "show the live status of a running monitor in the terminal",
+		Flags: topFlags,
+
+		Before: func(_ *cli.Context) error {
+			if cfg.Top.Interval <= 0 {
+				return fmt.Errorf("%w: %s", ErrUnexpectedTopInterval, cfg.Top.Interval)
+			}
+			if cfg.Top.Window != "" {
+				window, err := time.ParseDuration(cfg.Top.Window)
+				if err != nil || window <= 0 {
+					return fmt.Errorf("%w: %s", ErrUnexpectedTopWindow, cfg.Top.Window)
+				}
+				// the api renders the windows the same way
+				cfg.Top.Window = utils.FormatDuration(window)
+			}
+			return nil
+		},
+
+		Action: func(clictx *cli.Context) error {
+			d, err := tui.New(&cfg.Top)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(clictx.Context, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return d.Run(ctx, os.Stdin, clictx.App.Writer)
+		},
+	}
+}
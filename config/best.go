@@ -0,0 +1,12 @@
+package config
+
+import "time"
+
+type Best struct {
+	LagWeight       float64       `yaml:"lag_weight"`
+	LatencyWeight   float64       `yaml:"latency_weight"`
+	MaxLag          uint64        `yaml:"max_lag"`
+	MaxStaleness    time.Duration `yaml:"max_staleness"`
+	Policy          string        `yaml:"policy"`
+	StalenessWeight float64       `yaml:"staleness_weight"`
+}
@@ -0,0 +1,10 @@
+package config
+
+import "time"
+
+type Clock struct {
+	MaxOffset   time.Duration `yaml:"max_offset"`
+	NTPInterval time.Duration `yaml:"ntp_interval"`
+	NTPServers  []string      `yaml:"ntp_servers"`
+	SkewBlocks  int           `yaml:"skew_blocks"`
+}
@@ -1,7 +1,17 @@
 package config
 
 type Config struct {
-	Eth    Eth    `yaml:"eth"`
-	Log    Log    `yaml:"log"`
-	Server Server `yaml:"server"`
+	Best        Best        `yaml:"best"`
+	Clock       Clock       `yaml:"clock"`
+	Eth         Eth         `yaml:"eth"`
+	Federation  Federation  `yaml:"federation"`
+	Leaderboard Leaderboard `yaml:"leaderboard"`
+	Log         Log         `yaml:"log"`
+	Probe       Probe       `yaml:"probe"`
+	Proxy       Proxy       `yaml:"proxy"`
+	Record      Record      `yaml:"record"`
+	Server      Server      `yaml:"server"`
+	Stats       Stats       `yaml:"stats"`
+	Top         Top         `yaml:"top"`
+	Tracing     Tracing     `yaml:"tracing"`
 }
@@ -3,7 +3,25 @@ package config
 import "time"
 
 type Eth struct {
+	BackfillSkippedBlocks      bool          `yaml:"backfill_skipped_blocks"`
+	BlockFetchTimeout          time.Duration `yaml:"block_fetch_timeout"`
+	EngineEndpoints            []string      `yaml:"engine_endpoints"`
+	EngineJWTSecrets           []string      `yaml:"engine_jwt_secrets"`
+	EngineProbeInterval        time.Duration `yaml:"engine_probe_interval"`
+	EngineRequiredCapabilities []string      `yaml:"engine_required_capabilities"`
+	ExecutionEndpointLabels    []string      `yaml:"execution_endpoint_labels"`
+	ExecutionEndpointLabelKeys []string      `yaml:"execution_endpoint_label_keys"`
 	ExecutionEndpoints         []string      `yaml:"execution_endpoints"`
+	ExecutionEndpointWeights   []string      `yaml:"execution_endpoint_weights"`
 	ExternalExecutionEndpoints []string      `yaml:"external_execution_endpoints"`
+	FetchTransactionCount      bool          `yaml:"fetch_transaction_count"`
+	GenesisTime                int64         `yaml:"genesis_time"`
+	GroupHeadPolicy            string        `yaml:"group_head_policy"`
+	GroupHeadQuorum            int           `yaml:"group_head_quorum"`
+	GroupHeadWeightThreshold   float64       `yaml:"group_head_weight_threshold"`
+	MaxClockSkew               time.Duration `yaml:"max_clock_skew"`
+	ReferenceEndpoints         []string      `yaml:"reference_endpoints"`
 	ResubscribeInterval        time.Duration `yaml:"resubscribe_interval"`
+	SlotDuration               time.Duration `yaml:"slot_duration"`
+	ValidateBlocks             bool          `yaml:"validate_blocks"`
 }
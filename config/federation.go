@@ -0,0 +1,10 @@
+package config
+
+import "time"
+
+type Federation struct {
+	Peers  []string      `yaml:"peers"`
+	Region string        `yaml:"region"`
+	Settle time.Duration `yaml:"settle"`
+	Token  string        `yaml:"token"`
+}
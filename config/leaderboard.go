@@ -0,0 +1,9 @@
+package config
+
+import "time"
+
+type Leaderboard struct {
+	Horizon   uint64        `yaml:"horizon"`
+	Threshold time.Duration `yaml:"threshold"`
+	Window    time.Duration `yaml:"window"`
+}
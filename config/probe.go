@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+type Probe struct {
+	Address  string        `yaml:"address"`
+	CallData string        `yaml:"call_data"`
+	CallTo   string        `yaml:"call_to"`
+	Interval time.Duration `yaml:"interval"`
+	Methods  []string      `yaml:"methods"`
+}
@@ -0,0 +1,9 @@
+package config
+
+import "time"
+
+type Proxy struct {
+	ListenAddress string        `yaml:"listen_address"`
+	Methods       []string      `yaml:"methods"`
+	Timeout       time.Duration `yaml:"timeout"`
+}
@@ -0,0 +1,9 @@
+package config
+
+import "time"
+
+type Record struct {
+	MaxAge  time.Duration `yaml:"max_age"`
+	MaxSize int64         `yaml:"max_size"`
+	Path    string        `yaml:"path"`
+}
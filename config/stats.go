@@ -0,0 +1,8 @@
+package config
+
+import "time"
+
+type Stats struct {
+	Quantiles []float64       `yaml:"quantiles"`
+	Windows   []time.Duration `yaml:"windows"`
+}
@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+type Top struct {
+	Filter   string        `yaml:"filter"`
+	Interval time.Duration `yaml:"interval"`
+	Sort     string        `yaml:"sort"`
+	URL      string        `yaml:"url"`
+	Window   string        `yaml:"window"`
+}
@@ -0,0 +1,7 @@
+package config
+
+type Tracing struct {
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
@@ -0,0 +1,128 @@
+// Package eventlog reads and writes the logs of the header arrivals.
+//
+// The log is a sequence of JSON objects, one per line (optionally gzipped):
+//
+//	{"v":1,"endpoint":"group:name","kind":"internal","ts":"2024-03-01T12:00:00.123456789Z","header":{...}}
+//
+// where:
+//
+//   - v is the version of the format (currently 1);
+//   - endpoint is the id of the execution endpoint that received the header;
+//   - kind is the kind of the endpoint (internal or external);
+//   - ts is the moment when the header was received (RFC 3339 with nanoseconds);
+//   - header is the header as returned by the execution client's json-rpc,
+//     re-encoded by go-ethereum (so the fields that go-ethereum doesn't know
+//     about are not kept).
+//
+// The format is stable: the readers must ignore the fields they don't know
+// about, and any change that would break the existing readers comes with the
+// new version.  The events within the log are ordered by the moment they were
+// written at, which is not necessarily the order of their timestamps.
+package eventlog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	Version = 1
+)
+
+var (
+	ErrMalformedEvent     = errors.New("malformed event")
+	ErrUnsupportedVersion = errors.New("unsupported event log version")
+)
+
+// Event is a single header arrival.
+type Event struct {
+	Version  int              `json:"v"`
+	Endpoint string           `json:"endpoint"`
+	Kind     string           `json:"kind,omitempty"`
+	Time     time.Time        `json:"ts"`
+	Header   *ethtypes.Header `json:"header"`
+}
+
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewReader reads the events from the (plain or gzipped) log.
+func NewReader(r io.Reader) (*Reader, error) {
+	buffered := bufio.NewReader(r)
+	if magic, err := buffered.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		r = gz
+	} else {
+		r = buffered
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	return &Reader{
+		scanner: scanner,
+	}, nil
+}
+
+// Next returns the next event of the log, or io.EOF once there are no more.
+func (r *Reader) Next() (*Event, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		event := &Event{}
+		if err := json.Unmarshal(line, event); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w",
+				ErrMalformedEvent, r.line, err,
+			)
+		}
+		if event.Version != Version {
+			return nil, fmt.Errorf("%w: line %d: %d",
+				ErrUnsupportedVersion, r.line, event.Version,
+			)
+		}
+		if event.Endpoint == "" || event.Header == nil || event.Time.IsZero() {
+			return nil, fmt.Errorf("%w: line %d: missing endpoint, ts or header",
+				ErrMalformedEvent, r.line,
+			)
+		}
+
+		return event, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// ReadAll returns all the remaining events of the log.
+func (r *Reader) ReadAll() ([]*Event, error) {
+	events := make([]*Event, 0)
+	for {
+		event, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+}
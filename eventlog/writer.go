@@ -0,0 +1,146 @@
+package eventlog
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	fileTimeFormat = "20060102T150405Z"
+)
+
+// RotatingWriter appends the events to the gzipped log files, and starts the
+// new file once the current one grows too big or too old.  The files are
+// named `<prefix>.<utc time of creation>.jsonl.gz`.
+type RotatingWriter struct {
+	prefix  string
+	maxAge  time.Duration
+	maxSize int64
+
+	file    *os.File
+	gz      *gzip.Writer
+	opened  time.Time
+	written int64
+
+	mx sync.Mutex
+}
+
+// NewRotatingWriter creates the writer.  Zero max size or age means that the
+// files are not rotated by that criteria.
+func NewRotatingWriter(prefix string, maxSize int64, maxAge time.Duration) (
+	*RotatingWriter, error,
+) {
+	if err := os.MkdirAll(filepath.Dir(prefix), 0o755); err != nil {
+		return nil, err
+	}
+
+	return &RotatingWriter{
+		prefix:  prefix,
+		maxAge:  maxAge,
+		maxSize: maxSize,
+	}, nil
+}
+
+// Write appends the event to the current file.  The events are buffered by
+// the compressor until the next Flush (or until the file is rotated).
+func (w *RotatingWriter) Write(event *Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	if w.needsRotation(event.Time) {
+		if err := w.rotate(event.Time); err != nil {
+			return err
+		}
+	}
+
+	n, err := w.gz.Write(line)
+	w.written += int64(n)
+
+	return err
+}
+
+// Flush writes out the buffered events to the current file.
+func (w *RotatingWriter) Flush() error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	if w.gz == nil {
+		return nil
+	}
+	return w.gz.Flush()
+}
+
+// Close finalises the current file.
+func (w *RotatingWriter) Close() error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	return w.closeFile()
+}
+
+func (w *RotatingWriter) needsRotation(now time.Time) bool {
+	switch {
+	case w.file == nil:
+		return true
+	case w.maxSize > 0 && w.written >= w.maxSize:
+		return true
+	case w.maxAge > 0 && now.Sub(w.opened) >= w.maxAge:
+		return true
+	}
+	return false
+}
+
+func (w *RotatingWriter) rotate(now time.Time) error {
+	if err := w.closeFile(); err != nil {
+		return err
+	}
+
+	base := fmt.Sprintf("%s.%s", w.prefix, now.UTC().Format(fileTimeFormat))
+	name := base + ".jsonl.gz"
+	var (
+		file *os.File
+		err  error
+	)
+	for suffix := 1; ; suffix++ {
+		file, err = os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if !errors.Is(err, os.ErrExist) {
+			break
+		}
+		name = fmt.Sprintf("%s-%d.jsonl.gz", base, suffix)
+	}
+	if err != nil {
+		return err
+	}
+
+	w.file = file
+	w.gz = gzip.NewWriter(file)
+	w.opened = now
+	w.written = 0
+
+	return nil
+}
+
+func (w *RotatingWriter) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+
+	errGz := w.gz.Close()
+	errFile := w.file.Close()
+	w.file = nil
+	w.gz = nil
+
+	return errors.Join(errGz, errFile)
+}
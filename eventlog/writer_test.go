@@ -0,0 +1,85 @@
+package eventlog_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flashbots/node-monitor/eventlog"
+	"github.com/flashbots/node-monitor/fakenode"
+	"gotest.tools/assert"
+)
+
+func TestRotatingWriter(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Unix(1700000000, 0).UTC()
+
+	w, err := eventlog.NewRotatingWriter(filepath.Join(dir, "headers"), 0, time.Minute)
+	assert.NilError(t, err)
+
+	chain := fakenode.NewChain(100, start, 12*time.Second)
+	for i := 0; i < 10; i++ {
+		header := chain.Next()
+		assert.NilError(t, w.Write(&eventlog.Event{
+			Version:  eventlog.Version,
+			Endpoint: "g:a",
+			Kind:     "internal",
+			Time:     time.Unix(int64(header.Time), 0).UTC(),
+			Header:   header,
+		}))
+	}
+	assert.NilError(t, w.Close())
+
+	// 10 blocks 12s apart make 2 files of 1 minute each
+	files, err := filepath.Glob(filepath.Join(dir, "headers.*.jsonl.gz"))
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(files))
+
+	var number uint64 = 101
+	for _, file := range files {
+		f, err := os.Open(file)
+		assert.NilError(t, err)
+		r, err := eventlog.NewReader(f)
+		assert.NilError(t, err)
+		events, err := r.ReadAll()
+		assert.NilError(t, err)
+		for _, event := range events {
+			assert.Equal(t, "g:a", event.Endpoint)
+			assert.Equal(t, number, event.Header.Number.Uint64())
+			number++
+		}
+		assert.NilError(t, f.Close())
+	}
+	assert.Equal(t, uint64(111), number)
+}
+
+func TestRotatingWriterFlush(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := eventlog.NewRotatingWriter(filepath.Join(dir, "headers"), 0, 0)
+	assert.NilError(t, err)
+	defer w.Close()
+
+	header := fakenode.NewChain(100, time.Now(), 12*time.Second).Next()
+	assert.NilError(t, w.Write(&eventlog.Event{
+		Version:  eventlog.Version,
+		Endpoint: "g:a",
+		Time:     time.Now(),
+		Header:   header,
+	}))
+
+	// the flushed events can be read while the file is still open
+	assert.NilError(t, w.Flush())
+	files, err := filepath.Glob(filepath.Join(dir, "headers.*.jsonl.gz"))
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(files))
+	f, err := os.Open(files[0])
+	assert.NilError(t, err)
+	defer f.Close()
+	r, err := eventlog.NewReader(f)
+	assert.NilError(t, err)
+	event, err := r.Next()
+	assert.NilError(t, err)
+	assert.Equal(t, header.Hash(), event.Header.Hash())
+}
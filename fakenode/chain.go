@@ -0,0 +1,79 @@
+package fakenode
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// Chain produces the consistent sequences of (empty) headers.
+type Chain struct {
+	headers  []*ethtypes.Header
+	interval time.Duration
+	forks    uint64
+}
+
+// NewChain starts the chain at the given block whose timestamp is the given
+// one.  The timestamps of the following blocks are spaced by the interval.
+func NewChain(number uint64, ts time.Time, interval time.Duration) *Chain {
+	return &Chain{
+		headers: []*ethtypes.Header{
+			newHeader(common.Hash{}, number, ts, nil),
+		},
+		interval: interval,
+	}
+}
+
+// Head returns the most recent header of the chain.
+func (c *Chain) Head() *ethtypes.Header {
+	return c.headers[len(c.headers)-1]
+}
+
+// Next extends the chain by one block and returns its header.
+func (c *Chain) Next() *ethtypes.Header {
+	parent := c.Head()
+	header := newHeader(
+		parent.Hash(),
+		parent.Number.Uint64()+1,
+		time.Unix(int64(parent.Time), 0).Add(c.interval),
+		nil,
+	)
+	c.headers = append(c.headers, header)
+
+	return header
+}
+
+// Reorg replaces the last `depth` blocks of the chain with the single block
+// of a different hash and returns its header.
+func (c *Chain) Reorg(depth int) *ethtypes.Header {
+	c.headers = c.headers[:len(c.headers)-depth]
+	c.forks++
+
+	parent := c.Head()
+	header := newHeader(
+		parent.Hash(),
+		parent.Number.Uint64()+1,
+		time.Unix(int64(parent.Time), 0).Add(c.interval),
+		big.NewInt(0).SetUint64(c.forks).Bytes(),
+	)
+	c.headers = append(c.headers, header)
+
+	return header
+}
+
+func newHeader(parent common.Hash, number uint64, ts time.Time, extra []byte) *ethtypes.Header {
+	return &ethtypes.Header{
+		ParentHash:  parent,
+		UncleHash:   ethtypes.EmptyUncleHash,
+		Root:        ethtypes.EmptyRootHash,
+		TxHash:      ethtypes.EmptyTxsHash,
+		ReceiptHash: ethtypes.EmptyReceiptsHash,
+		Difficulty:  big.NewInt(0),
+		Number:      big.NewInt(0).SetUint64(number),
+		GasLimit:    30_000_000,
+		Time:        uint64(ts.Unix()),
+		Extra:       extra,
+	}
+}
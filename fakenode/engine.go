@@ -0,0 +1,149 @@
+package fakenode
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// engineTokenMaxAge is how far the token's iat may be off (as per the
+	// engine api spec).
+	engineTokenMaxAge = time.Minute
+)
+
+var (
+	ErrEngineTokenExpired = errors.New("stale jwt token")
+)
+
+// Engine is the authenticated engine api (authrpc) of the node.  It only
+// accepts the requests that carry the jwt signed with its secret.
+type Engine struct {
+	http *httptest.Server
+	rpc  *rpc.Server
+
+	secret [32]byte
+
+	capabilities []string
+	syncing      bool
+
+	mx sync.Mutex
+}
+
+// NewEngine starts the fake engine api that supports the capabilities.  It
+// must be closed once not needed anymore.
+func NewEngine(secret [32]byte, capabilities ...string) *Engine {
+	e := &Engine{
+		secret:       secret,
+		capabilities: capabilities,
+	}
+
+	e.rpc = rpc.NewServer()
+	if err := e.rpc.RegisterName("engine", &engineAPI{engine: e}); err != nil {
+		panic(err)
+	}
+	if err := e.rpc.RegisterName("eth", &engineEthAPI{engine: e}); err != nil {
+		panic(err)
+	}
+	e.http = httptest.NewServer(http.HandlerFunc(e.serveHTTP))
+
+	return e
+}
+
+// URL returns the http url of the engine api.
+func (e *Engine) URL() string {
+	return e.http.URL
+}
+
+func (e *Engine) Close() {
+	e.rpc.Stop()
+	e.http.Close()
+}
+
+// SetCapabilities changes the capabilities that the engine api supports.
+func (e *Engine) SetCapabilities(capabilities ...string) {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+
+	e.capabilities = capabilities
+}
+
+// SetSyncing makes the node report that it is (or is not) syncing.
+func (e *Engine) SetSyncing(syncing bool) {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+
+	e.syncing = syncing
+}
+
+func (e *Engine) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := e.authenticate(r); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	e.rpc.ServeHTTP(w, r)
+}
+
+func (e *Engine) authenticate(r *http.Request) error {
+	raw, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return jwt.ErrTokenMalformed
+	}
+
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(*jwt.Token) (interface{}, error) {
+		return e.secret[:], nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return err
+	}
+	if claims.IssuedAt == nil {
+		return ErrEngineTokenExpired
+	}
+	if age := time.Since(claims.IssuedAt.Time); age > engineTokenMaxAge || age < -engineTokenMaxAge {
+		return ErrEngineTokenExpired
+	}
+
+	return nil
+}
+
+// engineAPI is the "engine" namespace of the engine api.
+type engineAPI struct {
+	engine *Engine
+}
+
+func (api *engineAPI) ExchangeCapabilities(_ context.Context, _ []string) ([]string, error) {
+	api.engine.mx.Lock()
+	defer api.engine.mx.Unlock()
+
+	return append([]string{}, api.engine.capabilities...), nil
+}
+
+// engineEthAPI is the (subset of the) "eth" namespace that the engine api
+// serves too.
+type engineEthAPI struct {
+	engine *Engine
+}
+
+func (api *engineEthAPI) Syncing(_ context.Context) (interface{}, error) {
+	api.engine.mx.Lock()
+	defer api.engine.mx.Unlock()
+
+	if !api.engine.syncing {
+		return false, nil
+	}
+	return map[string]hexutil.Uint64{
+		"startingBlock": 0,
+		"currentBlock":  1,
+		"highestBlock":  2,
+	}, nil
+}
@@ -0,0 +1,378 @@
+// Package fakenode implements an in-process stand-in for the websocket rpc of
+// an execution client.  It serves the new headers subscription along with the
+// few methods that the monitor relies upon, and lets the tests script what
+// the "node" does: which headers it announces and when, when it drops the
+// connections, and which calls fail.  The Engine stands in for the node's
+// authenticated engine api.
+package fakenode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	MethodBlockNumber       = "eth_blockNumber"
+	MethodChainID           = "eth_chainId"
+	MethodGetBalance        = "eth_getBalance"
+	MethodGetBlockByHash    = "eth_getBlockByHash"
+	MethodGetBlockByNumber  = "eth_getBlockByNumber"
+	MethodGetBlockReceipts  = "eth_getBlockReceipts"
+	MethodGetBlockTxCount   = "eth_getBlockTransactionCountByHash"
+	MethodSubscribeNewHeads = "eth_subscribe"
+)
+
+var (
+	ErrNotSupported = errors.New("notifications not supported")
+)
+
+// DefaultChainID is the chain id that the node reports unless told otherwise.
+const DefaultChainID = 1
+
+type Node struct {
+	http *httptest.Server
+	rpc  *rpc.Server
+
+	chainID uint64
+
+	delays   map[string]time.Duration
+	failures map[string]error
+
+	byHash   map[common.Hash]*ethtypes.Header
+	byNumber map[uint64]*ethtypes.Header
+	head     *ethtypes.Header
+
+	subscriptions map[rpc.ID]*rpc.Notifier
+
+	mx sync.Mutex
+}
+
+// New starts the fake node.  It must be closed once not needed anymore.
+func New() *Node {
+	n := &Node{
+		chainID: DefaultChainID,
+
+		delays:   make(map[string]time.Duration),
+		failures: make(map[string]error),
+
+		byHash:   make(map[common.Hash]*ethtypes.Header),
+		byNumber: make(map[uint64]*ethtypes.Header),
+
+		subscriptions: make(map[rpc.ID]*rpc.Notifier),
+	}
+	n.rpc = n.newRPCServer()
+	n.http = httptest.NewServer(http.HandlerFunc(n.serveHTTP))
+
+	return n
+}
+
+// URL returns the websocket url of the node.
+func (n *Node) URL() string {
+	return "ws" + strings.TrimPrefix(n.http.URL, "http")
+}
+
+func (n *Node) Close() {
+	n.mx.Lock()
+	srv := n.rpc
+	n.mx.Unlock()
+
+	srv.Stop()
+	n.http.Close()
+}
+
+// Announce makes the header canonical and sends it out to all subscribers.
+func (n *Node) Announce(header *ethtypes.Header) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	n.store(header)
+	for id, notifier := range n.subscriptions {
+		_ = notifier.Notify(id, header)
+	}
+}
+
+// Store makes the header canonical without sending it out (as if the node
+// skipped announcing it).
+func (n *Node) Store(header *ethtypes.Header) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	n.store(header)
+}
+
+func (n *Node) store(header *ethtypes.Header) {
+	n.byHash[header.Hash()] = header
+	n.byNumber[header.Number.Uint64()] = header
+	for number := range n.byNumber {
+		// the reorged-out blocks are not canonical anymore
+		if number > header.Number.Uint64() {
+			delete(n.byNumber, number)
+		}
+	}
+	n.head = header
+}
+
+// Disconnect drops all connections (the node keeps accepting the new ones).
+func (n *Node) Disconnect() {
+	n.mx.Lock()
+	srv := n.rpc
+	n.rpc = n.newRPCServer()
+	n.subscriptions = make(map[rpc.ID]*rpc.Notifier)
+	n.mx.Unlock()
+
+	srv.Stop()
+}
+
+// SetChainID makes the node report the given chain id.
+func (n *Node) SetChainID(chainID uint64) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	n.chainID = chainID
+}
+
+// Delay makes the node wait before responding to the method.
+func (n *Node) Delay(method string, delay time.Duration) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	if delay == 0 {
+		delete(n.delays, method)
+		return
+	}
+	n.delays[method] = delay
+}
+
+// Fail makes the calls of the method fail with the error (nil error stops
+// the failures).
+func (n *Node) Fail(method string, err error) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	if err == nil {
+		delete(n.failures, method)
+		return
+	}
+	n.failures[method] = err
+}
+
+// Subscribers returns the count of active new headers subscriptions.
+func (n *Node) Subscribers() int {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	return len(n.subscriptions)
+}
+
+func (n *Node) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mx.Lock()
+	srv := n.rpc
+	n.mx.Unlock()
+
+	srv.WebsocketHandler([]string{"*"}).ServeHTTP(w, r)
+}
+
+func (n *Node) newRPCServer() *rpc.Server {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("eth", &ethAPI{node: n}); err != nil {
+		panic(err)
+	}
+	return srv
+}
+
+// call applies the scripted delay and failure of the method.
+func (n *Node) call(ctx context.Context, method string) error {
+	n.mx.Lock()
+	delay := n.delays[method]
+	err := n.failures[method]
+	n.mx.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return err
+}
+
+func (n *Node) subscribe(id rpc.ID, notifier *rpc.Notifier) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	n.subscriptions[id] = notifier
+}
+
+func (n *Node) unsubscribe(id rpc.ID) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	delete(n.subscriptions, id)
+}
+
+func (n *Node) headerByHash(hash common.Hash) *ethtypes.Header {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	return n.byHash[hash]
+}
+
+func (n *Node) headerByNumber(number rpc.BlockNumber) *ethtypes.Header {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	if number < 0 {
+		return n.head
+	}
+	return n.byNumber[uint64(number)]
+}
+
+// ethAPI is the "eth" namespace of the node's rpc.
+type ethAPI struct {
+	node *Node
+}
+
+func (api *ethAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	if err := api.node.call(ctx, MethodSubscribeNewHeads); err != nil {
+		return nil, err
+	}
+
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, ErrNotSupported
+	}
+
+	subscription := notifier.CreateSubscription()
+	api.node.subscribe(subscription.ID, notifier)
+	go func() {
+		<-subscription.Err()
+		api.node.unsubscribe(subscription.ID)
+	}()
+
+	return subscription, nil
+}
+
+func (api *ethAPI) BlockNumber(ctx context.Context) (hexutil.Uint64, error) {
+	if err := api.node.call(ctx, MethodBlockNumber); err != nil {
+		return 0, err
+	}
+
+	head := api.node.headerByNumber(rpc.LatestBlockNumber)
+	if head == nil {
+		return 0, nil
+	}
+	return hexutil.Uint64(head.Number.Uint64()), nil
+}
+
+// ChainId is named after the rpc method (eth_chainId).
+func (api *ethAPI) ChainId(ctx context.Context) (hexutil.Uint64, error) {
+	if err := api.node.call(ctx, MethodChainID); err != nil {
+		return 0, err
+	}
+
+	api.node.mx.Lock()
+	defer api.node.mx.Unlock()
+
+	return hexutil.Uint64(api.node.chainID), nil
+}
+
+func (api *ethAPI) GetBalance(
+	ctx context.Context, _ common.Address, _ rpc.BlockNumberOrHash,
+) (*hexutil.Big, error) {
+	if err := api.node.call(ctx, MethodGetBalance); err != nil {
+		return nil, err
+	}
+
+	return (*hexutil.Big)(big.NewInt(0)), nil
+}
+
+func (api *ethAPI) GetBlockByHash(
+	ctx context.Context, hash common.Hash, _ bool,
+) (map[string]interface{}, error) {
+	if err := api.node.call(ctx, MethodGetBlockByHash); err != nil {
+		return nil, err
+	}
+
+	return marshalBlock(api.node.headerByHash(hash))
+}
+
+func (api *ethAPI) GetBlockByNumber(
+	ctx context.Context, number rpc.BlockNumber, _ bool,
+) (map[string]interface{}, error) {
+	if err := api.node.call(ctx, MethodGetBlockByNumber); err != nil {
+		return nil, err
+	}
+
+	return marshalBlock(api.node.headerByNumber(number))
+}
+
+func (api *ethAPI) GetBlockReceipts(
+	ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash,
+) ([]*ethtypes.Receipt, error) {
+	if err := api.node.call(ctx, MethodGetBlockReceipts); err != nil {
+		return nil, err
+	}
+
+	var header *ethtypes.Header
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		header = api.node.headerByHash(hash)
+	} else if number, ok := blockNrOrHash.Number(); ok {
+		header = api.node.headerByNumber(number)
+	}
+	if header == nil {
+		return nil, nil
+	}
+
+	// the fake blocks never have any transactions
+	return []*ethtypes.Receipt{}, nil
+}
+
+func (api *ethAPI) GetBlockTransactionCountByHash(
+	ctx context.Context, hash common.Hash,
+) (*hexutil.Uint, error) {
+	if err := api.node.call(ctx, MethodGetBlockTxCount); err != nil {
+		return nil, err
+	}
+
+	if api.node.headerByHash(hash) == nil {
+		return nil, nil
+	}
+
+	// the fake blocks never have any transactions
+	count := hexutil.Uint(0)
+	return &count, nil
+}
+
+// marshalBlock renders the header as a block without any transactions.
+func marshalBlock(header *ethtypes.Header) (map[string]interface{}, error) {
+	if header == nil {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	block := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &block); err != nil {
+		return nil, err
+	}
+	block["transactions"] = []interface{}{}
+	block["uncles"] = []interface{}{}
+
+	return block, nil
+}
@@ -0,0 +1,81 @@
+package fakenode_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/flashbots/node-monitor/fakenode"
+	"gotest.tools/assert"
+)
+
+func TestNode(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	node := fakenode.New()
+	defer node.Close()
+
+	client, err := ethclient.DialContext(ctx, node.URL())
+	assert.NilError(t, err)
+	defer client.Close()
+
+	headers := make(chan *ethtypes.Header, 16)
+	sub, err := client.SubscribeNewHead(ctx, headers)
+	assert.NilError(t, err)
+
+	chain := fakenode.NewChain(100, time.Now(), 12*time.Second)
+	assert.NilError(t, node.Play(ctx,
+		fakenode.WaitForSubscribers(1),
+		fakenode.AnnounceHeader(chain.Next()),
+		fakenode.AnnounceHeader(chain.Next()),
+		fakenode.AnnounceHeader(chain.Reorg(1)),
+	))
+	for _, number := range []uint64{101, 102, 102} {
+		header := <-headers
+		assert.Equal(t, number, header.Number.Uint64())
+	}
+
+	// the block can be fetched (and is consistent with its header)
+	block, err := client.BlockByHash(ctx, chain.Head().Hash())
+	assert.NilError(t, err)
+	assert.Equal(t, chain.Head().Hash(), block.Hash())
+	receipts, err := client.BlockReceipts(ctx, rpc.BlockNumberOrHashWithHash(block.Hash(), false))
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(receipts))
+
+	// the reorged-out header is not canonical
+	header, err := client.HeaderByNumber(ctx, chain.Head().Number)
+	assert.NilError(t, err)
+	assert.Equal(t, chain.Head().Hash(), header.Hash())
+
+	chainID, err := client.ChainID(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, uint64(fakenode.DefaultChainID), chainID.Uint64())
+	node.SetChainID(5)
+	chainID, err = client.ChainID(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, uint64(5), chainID.Uint64())
+
+	// the failures are scripted per method
+	node.Fail(fakenode.MethodBlockNumber, errors.New("boom"))
+	_, err = client.BlockNumber(ctx)
+	assert.ErrorContains(t, err, "boom")
+	node.Fail(fakenode.MethodBlockNumber, nil)
+	number, err := client.BlockNumber(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, uint64(102), number)
+
+	// the subscription breaks when the node drops the connections
+	node.Disconnect()
+	select {
+	case err := <-sub.Err():
+		assert.Assert(t, err != nil)
+	case <-ctx.Done():
+		t.Fatal("subscription survived the disconnect")
+	}
+}
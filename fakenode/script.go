@@ -0,0 +1,75 @@
+package fakenode
+
+import (
+	"context"
+	"time"
+
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// Step is a single action of the node's script.
+type Step func(ctx context.Context, n *Node) error
+
+// Play runs the steps one after another.
+func (n *Node) Play(ctx context.Context, steps ...Step) error {
+	for _, step := range steps {
+		if err := step(ctx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AnnounceHeader is the step that announces the header.
+func AnnounceHeader(header *ethtypes.Header) Step {
+	return func(_ context.Context, n *Node) error {
+		n.Announce(header)
+		return nil
+	}
+}
+
+// Sleep is the step that waits for the duration.
+func Sleep(d time.Duration) Step {
+	return func(ctx context.Context, _ *Node) error {
+		select {
+		case <-time.After(d):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// DropConnections is the step that disconnects all clients.
+func DropConnections() Step {
+	return func(_ context.Context, n *Node) error {
+		n.Disconnect()
+		return nil
+	}
+}
+
+// FailMethod is the step that makes the method fail (or succeed again, if
+// the error is nil).
+func FailMethod(method string, err error) Step {
+	return func(_ context.Context, n *Node) error {
+		n.Fail(method, err)
+		return nil
+	}
+}
+
+// WaitForSubscribers is the step that waits until the node has at least the
+// given count of the new headers subscribers.
+func WaitForSubscribers(count int) Step {
+	return func(ctx context.Context, n *Node) error {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for n.Subscribers() < count {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+}
@@ -7,7 +7,14 @@ import (
 	"time"
 
 	"github.com/flashbots/node-monitor/logutils"
+	"github.com/flashbots/node-monitor/tracing"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -17,6 +24,20 @@ func Middleware(logger *zap.Logger, next http.Handler) http.Handler {
 		_uuid := [16]byte(uuid.New())
 		httpRequestID := base64.RawStdEncoding.EncodeToString(_uuid[:])
 
+		// Continue the caller's trace (if any)
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer().Start(ctx, fmt.Sprintf("HTTP %s", r.Method),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.EscapedPath()),
+				attribute.String("httpRequestID", httpRequestID),
+			),
+		)
+		defer span.End()
+		r = r.WithContext(ctx)
+
+		logger := logutils.LoggerWithSpan(logger, span)
 		l := logger.With(
 			zap.String("httpRequestID", httpRequestID),
 			zap.String("logType", "activity"),
@@ -32,6 +53,7 @@ func Middleware(logger *zap.Logger, next http.Handler) http.Handler {
 					method = r.Method
 					url = r.URL.EscapedPath()
 				}
+				span.SetStatus(codes.Error, fmt.Sprint(msg))
 				l.Error("HTTP request handler panicked",
 					zap.Any("error", msg),
 					zap.String("method", method),
@@ -44,6 +66,11 @@ func Middleware(logger *zap.Logger, next http.Handler) http.Handler {
 		wrapped := wrapResponseWriter(w)
 		next.ServeHTTP(wrapped, r)
 
+		span.SetAttributes(semconv.HTTPResponseStatusCode(wrapped.Status()))
+		if wrapped.Status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(wrapped.Status()))
+		}
+
 		// Passing request stats both in-message (for the human reader)
 		// as well as inside the structured log (for the machine parser)
 		logger.Debug(fmt.Sprintf("%s %s %d", r.Method, r.URL.EscapedPath(), wrapped.Status()),
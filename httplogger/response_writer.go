@@ -1,6 +1,8 @@
 package httplogger
 
 import (
+	"bufio"
+	"net"
 	"net/http"
 )
 
@@ -30,3 +32,19 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 	rw.wroteHeader = true
 }
+
+// Unwrap lets http.ResponseController reach the underlying writer (e.g. to
+// flush the streamed responses).
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Hijack lets the handlers take over the connection (e.g. for websockets).
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, buf, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil && !rw.wroteHeader {
+		rw.status = http.StatusSwitchingProtocols
+		rw.wroteHeader = true
+	}
+	return conn, buf, err
+}
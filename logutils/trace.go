@@ -0,0 +1,19 @@
+package logutils
+
+import (
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// LoggerWithSpan adds trace and span ids to the logger (if the span is being
+// recorded) so that the log lines could be correlated with the traces.
+func LoggerWithSpan(logger *zap.Logger, span trace.Span) *zap.Logger {
+	sc := span.SpanContext()
+	if !sc.IsValid() {
+		return logger
+	}
+	return logger.With(
+		zap.String("traceID", sc.TraceID().String()),
+		zap.String("spanID", sc.SpanID().String()),
+	)
+}
@@ -3,6 +3,7 @@ package prometheus
 import (
 	"context"
 
+	prom "github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/otel/exporters/prometheus"
 	otelapi "go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/sdk/metric"
@@ -10,7 +11,8 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 )
 
-func NewMeter(ctx context.Context, name string) (
+// NewMeter creates the meter whose metrics are exported into the registerer.
+func NewMeter(ctx context.Context, name string, registerer prom.Registerer) (
 	otelapi.Meter, error,
 ) {
 	res, err := resource.New(ctx,
@@ -22,6 +24,7 @@ func NewMeter(ctx context.Context, name string) (
 
 	exporter, err := prometheus.New(
 		prometheus.WithNamespace("node-monitor"),
+		prometheus.WithRegisterer(registerer),
 	)
 	if err != nil {
 		return nil, err
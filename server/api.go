@@ -0,0 +1,247 @@
+package server
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/flashbots/node-monitor/state"
+	"github.com/flashbots/node-monitor/utils"
+)
+
+type apiStatus struct {
+	Groups map[string]*apiGroupStatus `json:"groups"`
+
+	// NTP is the local clock's offset as per each of the ntp servers
+	NTP map[string]*apiNTPStatus `json:"ntp,omitempty"`
+}
+
+type apiNTPStatus struct {
+	Offset    float64   `json:"offset_s"`
+	RTT       float64   `json:"rtt_s"`
+	Stratum   uint8     `json:"stratum,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type apiGroupStatus struct {
+	HighestBlock       int64   `json:"highest_block"`
+	TimeSinceLastBlock float64 `json:"time_since_last_block_s"`
+
+	InternalExternalLatencyDelta *float64 `json:"internal_external_latency_delta_s,omitempty"`
+
+	Endpoints map[string]*apiEndpointStatus `json:"endpoints"`
+}
+
+type apiEndpointStatus struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+
+	HighestBlock       int64   `json:"highest_block"`
+	HighestBlockLag    int64   `json:"highest_block_lag"`
+	TimeSinceLastBlock float64 `json:"time_since_last_block_s"`
+
+	Subscribed     *bool    `json:"subscribed,omitempty"`
+	LastLatency    *float64 `json:"last_latency_s,omitempty"`
+	HeaderTimeSkew *float64 `json:"header_time_skew_s,omitempty"`
+
+	LatencyQuantiles map[string]*apiLatencyQuantiles `json:"latency_quantiles,omitempty"`
+
+	Engine *apiEngineStatus `json:"engine,omitempty"`
+}
+
+type apiLatencyQuantiles struct {
+	Count     uint64             `json:"count"`
+	Quantiles map[string]float64 `json:"quantiles_s"`
+}
+
+type apiEngineStatus struct {
+	Reachable     bool `json:"reachable"`
+	Authenticated bool `json:"authenticated"`
+	Capable       bool `json:"capable"`
+	Syncing       bool `json:"syncing"`
+
+	Capabilities []string  `json:"capabilities"`
+	Error        string    `json:"error,omitempty"`
+	Latency      float64   `json:"latency_s"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+func (s *Server) status() *apiStatus {
+	now := s.now()
+	res := &apiStatus{
+		Groups: make(map[string]*apiGroupStatus),
+	}
+
+	s.state.IterateELGroupsRO(func(gname string, g *state.ELGroup) {
+		blockGroup, tsBlockGroup := g.TimeSinceHighestBlock(now)
+
+		group := &apiGroupStatus{
+			HighestBlock: blockGroup,
+			Endpoints:    make(map[string]*apiEndpointStatus),
+		}
+		if blockGroup != 0 {
+			group.TimeSinceLastBlock = tsBlockGroup.Seconds()
+		}
+		if _, delta, ok := g.InternalExternalDelta(); ok {
+			d := delta.Seconds()
+			group.InternalExternalLatencyDelta = &d
+		}
+
+		g.IterateEndpointsRO(func(ename string, e *state.ELEndpoint) {
+			blockEndpoint, tsBlockEndpoint := e.TimeSinceHighestBlock(now)
+
+			endpoint := &apiEndpointStatus{
+				ID:           utils.MakeELEndpointID(gname, ename),
+				Kind:         string(e.Kind()),
+				HighestBlock: blockEndpoint,
+			}
+			if blockEndpoint != 0 {
+				endpoint.TimeSinceLastBlock = tsBlockEndpoint.Seconds()
+				if blockGroup != 0 {
+					endpoint.HighestBlockLag = blockGroup - blockEndpoint
+				}
+			}
+
+			if sub, exists := s.subs[endpoint.ID]; exists {
+				subscribed := sub.IsSubscribed()
+				endpoint.Subscribed = &subscribed
+			}
+			if latency, ok := e.LastLatency(); ok {
+				l := latency.Seconds()
+				endpoint.LastLatency = &l
+			}
+			if skew, _, ok := e.HeaderTimeSkew(); ok {
+				d := skew.Seconds()
+				endpoint.HeaderTimeSkew = &d
+			}
+
+			for _, lq := range e.LatencyQuantiles(now, s.cfg.Stats.Quantiles...) {
+				if endpoint.LatencyQuantiles == nil {
+					endpoint.LatencyQuantiles = make(map[string]*apiLatencyQuantiles)
+				}
+				quantiles := &apiLatencyQuantiles{
+					Count:     lq.Count,
+					Quantiles: make(map[string]float64, len(lq.Quantiles)),
+				}
+				for idx, q := range lq.Quantiles {
+					if math.IsNaN(q) {
+						continue
+					}
+					quantiles.Quantiles[formatQuantile(s.cfg.Stats.Quantiles[idx])] = q
+				}
+				endpoint.LatencyQuantiles[utils.FormatDuration(lq.Window)] = quantiles
+			}
+
+			if engine, ok := e.EngineStatus(); ok {
+				endpoint.Engine = &apiEngineStatus{
+					Reachable:     engine.Reachable,
+					Authenticated: engine.Authenticated,
+					Capable:       engine.Capable,
+					Syncing:       engine.Syncing,
+
+					Capabilities: engine.Capabilities,
+					Error:        engine.Error,
+					Latency:      engine.Latency.Seconds(),
+					Timestamp:    engine.Timestamp,
+				}
+			}
+
+			group.Endpoints[ename] = endpoint
+		})
+
+		res.Groups[normalisedGroup(gname)] = group
+	})
+
+	s.state.IterateClockOffsetsRO(func(server string, offset state.ClockOffset) {
+		if res.NTP == nil {
+			res.NTP = make(map[string]*apiNTPStatus)
+		}
+		res.NTP[server] = &apiNTPStatus{
+			Offset:    offset.Offset.Seconds(),
+			RTT:       offset.RTT.Seconds(),
+			Stratum:   offset.Stratum,
+			Error:     offset.Error,
+			Timestamp: offset.Timestamp,
+		}
+	})
+
+	return res
+}
+
+type apiLeaderboard struct {
+	Window    string  `json:"window"`
+	Threshold float64 `json:"threshold_s"`
+
+	Groups map[string][]*apiLeaderboardEntry `json:"groups"`
+}
+
+type apiLeaderboardEntry struct {
+	Rank int    `json:"rank"`
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+
+	FirstSeen uint64 `json:"first_seen"`
+	Within    uint64 `json:"within"`
+	Missed    uint64 `json:"missed"`
+	Seen      uint64 `json:"seen"`
+
+	FirstSeenRatio float64 `json:"first_seen_ratio"`
+}
+
+// leaderboard ranks the endpoints of each group by how often they were the
+// first to see a block, then by how often they saw it within the threshold,
+// and then by how rarely they missed blocks altogether.
+func (s *Server) leaderboard(group string) *apiLeaderboard {
+	now := s.now()
+	res := &apiLeaderboard{
+		Window:    utils.FormatDuration(s.cfg.Leaderboard.Window),
+		Threshold: s.cfg.Leaderboard.Threshold.Seconds(),
+		Groups:    make(map[string][]*apiLeaderboardEntry),
+	}
+
+	s.state.IterateELGroupsRO(func(gname string, g *state.ELGroup) {
+		if group != "" && group != normalisedGroup(gname) {
+			return
+		}
+
+		entries := make([]*apiLeaderboardEntry, 0)
+		g.IterateEndpointsRO(func(ename string, e *state.ELEndpoint) {
+			stats := e.LeaderboardStats(now)
+			entry := &apiLeaderboardEntry{
+				ID:   utils.MakeELEndpointID(gname, ename),
+				Kind: string(e.Kind()),
+
+				FirstSeen: stats.FirstSeen,
+				Within:    stats.Within,
+				Missed:    stats.Missed,
+				Seen:      stats.Seen,
+			}
+			if stats.Seen > 0 {
+				entry.FirstSeenRatio = float64(stats.FirstSeen) / float64(stats.Seen)
+			}
+			entries = append(entries, entry)
+		})
+
+		slices.SortFunc(entries, func(a, b *apiLeaderboardEntry) int {
+			if c := cmp.Compare(b.FirstSeen, a.FirstSeen); c != 0 {
+				return c
+			}
+			if c := cmp.Compare(b.Within, a.Within); c != 0 {
+				return c
+			}
+			if c := cmp.Compare(a.Missed, b.Missed); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.ID, b.ID)
+		})
+		for idx, entry := range entries {
+			entry.Rank = idx + 1
+		}
+
+		res.Groups[normalisedGroup(gname)] = entries
+	})
+
+	return res
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/flashbots/node-monitor/logutils"
+	"github.com/flashbots/node-monitor/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	maxBackfillBlocks = 32
+)
+
+// backfillSkippedBlocks fetches the headers that the endpoint jumped over, and
+// publishes them as the backfilled events, so that the gap becomes visible in
+// the propagation timeline.  Only the most recent ones are fetched if the gap
+// is too wide.
+func (s *Server) backfillSkippedBlocks(
+	ctx context.Context,
+	gname, ename string,
+	block *big.Int,
+	skipped uint64,
+) {
+	l := logutils.LoggerFromContext(ctx)
+
+	sub, exists := s.subs[utils.MakeELEndpointID(gname, ename)]
+	if !exists {
+		return
+	}
+
+	count := min(skipped, maxBackfillBlocks)
+	from := big.NewInt(0).Sub(block, big.NewInt(int64(count)))
+	for number := from; number.Cmp(block) == -1; number = big.NewInt(0).Add(number, big.NewInt(1)) {
+		ctx, cancel := context.WithTimeout(ctx, s.cfg.Eth.BlockFetchTimeout)
+		header, err := sub.HeaderByNumber(ctx, number)
+		cancel()
+		if err != nil {
+			l.Warn("Failed to backfill skipped header",
+				zap.String("block", number.String()),
+				zap.String("endpoint_group", gname),
+				zap.String("endpoint_name", ename),
+				zap.Error(err),
+			)
+			continue
+		}
+		s.handleEventEthBackfilledHeader(ctx, gname, ename, s.now(), header)
+	}
+}
+
+func (s *Server) handleEventEthBackfilledHeader(
+	ctx context.Context,
+	gname, ename string,
+	ts time.Time,
+	header *ethtypes.Header,
+) {
+	l := logutils.LoggerFromContext(ctx)
+
+	blockTime := time.Unix(int64(header.Time), 0)
+	l.Info("Backfilled skipped header",
+		zap.String("block", header.Number.String()),
+		zap.String("block_hash", header.Hash().String()),
+		zap.Time("block_time", blockTime),
+		zap.String("endpoint_group", gname),
+		zap.String("endpoint_name", ename),
+		zap.Time("ts", ts),
+	)
+
+	group := normalisedGroup(gname)
+	id := utils.MakeELEndpointID(gname, ename)
+	s.events.publish(&apiEvent{
+		Type: eventTypeBackfilled,
+		Data: &apiBackfilledEvent{
+			Group:    group,
+			Endpoint: ename,
+			ID:       id,
+
+			Block:     header.Number.Uint64(),
+			Hash:      header.Hash().String(),
+			BlockTime: blockTime,
+			Time:      ts,
+		},
+		group: group,
+		id:    id,
+	})
+}
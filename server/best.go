@@ -0,0 +1,148 @@
+package server
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/flashbots/node-monitor/state"
+	"github.com/flashbots/node-monitor/utils"
+)
+
+type apiBest struct {
+	Group   string `json:"group"`
+	Policy  string `json:"policy"`
+	Healthy int    `json:"healthy"`
+
+	// Best is omitted when none of the group's endpoints is healthy
+	Best *apiBestCandidate `json:"best,omitempty"`
+
+	Candidates []*apiBestCandidate `json:"candidates"`
+}
+
+type apiBestCandidate struct {
+	ID       string `json:"id"`
+	Endpoint string `json:"endpoint"`
+	Kind     string `json:"kind"`
+
+	Healthy  bool           `json:"healthy"`
+	Problems []CheckProblem `json:"problems,omitempty"`
+	Score    *float64       `json:"score,omitempty"`
+
+	Subscribed *bool    `json:"subscribed,omitempty"`
+	Head       uint64   `json:"head"`
+	Lag        uint64   `json:"lag"`
+	Staleness  float64  `json:"staleness_s"`
+	Latency    *float64 `json:"latency_s,omitempty"`
+}
+
+// best evaluates the health of the group's endpoints and picks the one with
+// the lowest score (as per the configured policy) out of the healthy ones.
+// The candidates are ordered from the best to the worst.
+//
+// The endpoint is healthy if it is subscribed (when we know the connection
+// state), has reported some blocks, is not too far behind its group's head
+// and has not been silent for too long.
+func (s *Server) best(gname string, g *state.ELGroup, now time.Time) *apiBest {
+	res := &apiBest{
+		Group:      normalisedGroup(gname),
+		Policy:     s.cfg.Best.Policy,
+		Candidates: make([]*apiBestCandidate, 0),
+	}
+
+	blockGroup, _ := g.TimeSinceHighestBlock(now)
+
+	scores := make(map[*apiBestCandidate]float64)
+	g.IterateEndpointsRO(func(ename string, e *state.ELEndpoint) {
+		blockEndpoint, tsBlockEndpoint := e.TimeSinceHighestBlock(now)
+
+		candidate := &apiBestCandidate{
+			ID:       utils.MakeELEndpointID(gname, ename),
+			Endpoint: ename,
+			Kind:     string(e.Kind()),
+			Head:     uint64(blockEndpoint),
+		}
+		if sub, exists := s.subs[candidate.ID]; exists {
+			subscribed := sub.IsSubscribed()
+			candidate.Subscribed = &subscribed
+			if !subscribed {
+				candidate.Problems = append(candidate.Problems, CheckProblemUnsubscribed)
+			}
+		}
+		if blockEndpoint == 0 {
+			candidate.Problems = append(candidate.Problems, CheckProblemNoBlocks)
+		} else {
+			candidate.Staleness = tsBlockEndpoint.Seconds()
+			if blockGroup > blockEndpoint {
+				candidate.Lag = uint64(blockGroup - blockEndpoint)
+			}
+			if candidate.Lag > s.cfg.Best.MaxLag {
+				candidate.Problems = append(candidate.Problems, CheckProblemBehind)
+			}
+			if s.cfg.Best.MaxStaleness > 0 && tsBlockEndpoint > s.cfg.Best.MaxStaleness {
+				candidate.Problems = append(candidate.Problems, CheckProblemStale)
+			}
+		}
+
+		latency := s.bestLatency(e, now)
+		if latency != state.Infinity {
+			l := latency.Seconds()
+			candidate.Latency = &l
+		}
+
+		candidate.Healthy = len(candidate.Problems) == 0
+		if candidate.Healthy {
+			score := s.bestPolicy(state.BestCandidate{
+				Lag:       candidate.Lag,
+				Staleness: tsBlockEndpoint,
+				Latency:   latency,
+			})
+			candidate.Score = &score
+			scores[candidate] = score
+			res.Healthy++
+		}
+
+		res.Candidates = append(res.Candidates, candidate)
+	})
+
+	slices.SortFunc(res.Candidates, func(a, b *apiBestCandidate) int {
+		sa, healthyA := scores[a]
+		sb, healthyB := scores[b]
+		switch {
+		case healthyA && !healthyB:
+			return -1
+		case !healthyA && healthyB:
+			return 1
+		}
+		if c := cmp.Compare(sa, sb); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if res.Healthy > 0 {
+		res.Best = res.Candidates[0]
+	}
+
+	return res
+}
+
+// bestLatency is the median latency of the endpoint over the shortest of the
+// stats windows (or its last latency if there were no samples).
+func (s *Server) bestLatency(e *state.ELEndpoint, now time.Time) time.Duration {
+	if lqs := e.LatencyQuantiles(now, 0.5); len(lqs) > 0 {
+		shortest := lqs[0]
+		for _, lq := range lqs[1:] {
+			if lq.Window < shortest.Window {
+				shortest = lq
+			}
+		}
+		if q := shortest.Quantiles[0]; shortest.Count > 0 && !math.IsNaN(q) {
+			return time.Duration(q * float64(time.Second))
+		}
+	}
+	if latency, ok := e.LastLatency(); ok {
+		return latency
+	}
+	return state.Infinity
+}
@@ -0,0 +1,92 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/flashbots/node-monitor/fakenode"
+	"gotest.tools/assert"
+)
+
+type best struct {
+	Group   string `json:"group"`
+	Healthy int    `json:"healthy"`
+	Best    *struct {
+		ID string `json:"id"`
+	} `json:"best"`
+	Candidates []struct {
+		ID       string   `json:"id"`
+		Healthy  bool     `json:"healthy"`
+		Problems []string `json:"problems"`
+		Lag      uint64   `json:"lag"`
+	} `json:"candidates"`
+}
+
+func getBest(t *testing.T, m *monitor, group string) (int, *best) {
+	t.Helper()
+
+	res, err := http.Get(m.url + "/api/v1/groups/" + group + "/best")
+	assert.NilError(t, err)
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return res.StatusCode, nil
+	}
+	b := &best{}
+	assert.NilError(t, json.NewDecoder(res.Body).Decode(b))
+	return res.StatusCode, b
+}
+
+func TestBest(t *testing.T) {
+	a, b, c := fakenode.New(), fakenode.New(), fakenode.New()
+	defer a.Close()
+	defer b.Close()
+	defer c.Close()
+
+	m := startMonitor(t, newTestConfig(t, map[string]*fakenode.Node{"g:a": a, "g:b": b, "h:c": c}))
+
+	chain := fakenode.NewChain(100, time.Now(), 12*time.Second)
+	header := chain.Next()
+	play(t, a, fakenode.WaitForSubscribers(1), fakenode.AnnounceHeader(header))
+	m.waitFor(series("highest_block", "g", "a", "101"))
+	play(t, b, fakenode.WaitForSubscribers(1), fakenode.Sleep(100*time.Millisecond), fakenode.AnnounceHeader(header))
+	m.waitFor(series("highest_block", "g", "b", "101"))
+
+	// both are healthy, but a was faster
+	status, res := getBest(t, m, "g")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 2, res.Healthy)
+	assert.Equal(t, "g:a", res.Best.ID)
+	assert.Equal(t, "g:b", res.Candidates[1].ID)
+	assert.Assert(t, res.Candidates[1].Healthy)
+
+	m.waitFor(series("best_endpoint", "g", "a", "1"))
+	m.waitFor(series("best_endpoint", "g", "b", "0"))
+	m.waitFor(regexp.MustCompile(
+		`^node_monitor_healthy_endpoints\{.*node_monitor_target_group="g".*\} 2$`,
+	))
+
+	// b falls behind by more than the allowed lag
+	play(t, a, fakenode.AnnounceHeader(chain.Next()), fakenode.AnnounceHeader(chain.Next()))
+	m.waitFor(series("highest_block", "g", "a", "103"))
+
+	status, res = getBest(t, m, "g")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 1, res.Healthy)
+	assert.Equal(t, "g:a", res.Best.ID)
+	assert.Equal(t, false, res.Candidates[1].Healthy)
+	assert.Equal(t, uint64(2), res.Candidates[1].Lag)
+	assert.DeepEqual(t, []string{"behind"}, res.Candidates[1].Problems)
+
+	// c has not reported any blocks yet
+	status, res = getBest(t, m, "h")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, 0, res.Healthy)
+	assert.Assert(t, res.Best == nil)
+	assert.DeepEqual(t, []string{"no blocks"}, res.Candidates[0].Problems)
+
+	status, _ = getBest(t, m, "unknown")
+	assert.Equal(t, http.StatusNotFound, status)
+}
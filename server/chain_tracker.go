@@ -0,0 +1,99 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/flashbots/node-monitor/utils"
+)
+
+const (
+	chainTrackerHistoryBlocks = 128
+)
+
+// chainTracker follows the chains reported by the endpoints to spot the moves
+// of the groups' heads, the reorgs and the forks (for the live events).
+type chainTracker struct {
+	heads  map[string]uint64                         // group -> head
+	tips   map[string]chainTip                       // endpoint id -> latest header
+	hashes map[string]*utils.BlockRing[*common.Hash] // group -> canonical hashes
+
+	mx sync.Mutex
+}
+
+type chainTip struct {
+	number uint64
+	hash   common.Hash
+}
+
+// chainFindings is what the tracker learned from the header.
+type chainFindings struct {
+	// head is true if the group's head moved forward
+	head bool
+
+	// reorg is the count of the blocks that the endpoint replaced
+	reorg uint64
+
+	// fork is the group's hash of the block when the endpoint reports another
+	// one (without reorging into it)
+	fork *common.Hash
+}
+
+func newChainTracker() *chainTracker {
+	return &chainTracker{
+		heads:  make(map[string]uint64),
+		tips:   make(map[string]chainTip),
+		hashes: make(map[string]*utils.BlockRing[*common.Hash]),
+	}
+}
+
+func (t *chainTracker) track(
+	gname, id string,
+	header *ethtypes.Header,
+	groupHead uint64,
+) chainFindings {
+	res := chainFindings{}
+	if !header.Number.IsUint64() {
+		return res
+	}
+	number := header.Number.Uint64()
+	hash := header.Hash()
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	if groupHead > t.heads[gname] {
+		t.heads[gname] = groupHead
+		res.head = true
+	}
+
+	if prev, exists := t.tips[id]; exists {
+		switch {
+		case number <= prev.number && hash != prev.hash:
+			res.reorg = prev.number - number + 1
+		case number == prev.number+1 && header.ParentHash != prev.hash:
+			res.reorg = 1
+		}
+	}
+	t.tips[id] = chainTip{number: number, hash: hash}
+
+	hashes, exists := t.hashes[gname]
+	if !exists {
+		hashes = utils.NewBlockRing[*common.Hash](chainTrackerHistoryBlocks)
+		t.hashes[gname] = hashes
+	}
+	switch canonical, seen := hashes.Get(number); {
+	case !seen:
+		hashes.Put(number, &hash)
+	case *canonical == hash:
+	case res.reorg > 0:
+		// the endpoint is the first one to follow the reorg
+		*canonical = hash
+	default:
+		fork := *canonical
+		res.fork = &fork
+	}
+
+	return res
+}
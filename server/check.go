@@ -0,0 +1,358 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/flashbots/node-monitor/config"
+	"github.com/flashbots/node-monitor/logutils"
+	"github.com/flashbots/node-monitor/state"
+	"github.com/flashbots/node-monitor/utils"
+)
+
+const (
+	checkChainIDTimeout = 5 * time.Second
+	checkPollInterval   = 100 * time.Millisecond
+)
+
+// CheckProblem is the reason for the endpoint to fail the check.
+type CheckProblem string
+
+const (
+	CheckProblemBehind         CheckProblem = "behind"
+	CheckProblemDifferentChain CheckProblem = "different chain"
+	CheckProblemFork           CheckProblem = "fork"
+	CheckProblemNoBlocks       CheckProblem = "no blocks"
+	CheckProblemNoChainID      CheckProblem = "no chain id"
+	CheckProblemStale          CheckProblem = "stale"
+	CheckProblemUnsubscribed   CheckProblem = "unsubscribed"
+)
+
+// CheckOptions tell how long the check lasts and which endpoints fail it.
+type CheckOptions struct {
+	// Blocks is the count of blocks that the head of each group must advance
+	// through before the check is over.
+	Blocks uint64
+
+	// MaxLag is the max count of blocks that the endpoint may be behind the
+	// head of its group.
+	MaxLag uint64
+
+	// MaxStaleness is the max duration since the endpoint's latest block.
+	MaxStaleness time.Duration
+
+	// Timeout is the max duration of the check (it's over even if the groups
+	// did not advance through enough blocks by then).
+	Timeout time.Duration
+}
+
+// CheckResult is the state of the endpoint by the end of the check.
+type CheckResult struct {
+	ID      string
+	Kind    state.EndpointKind
+	ChainID *big.Int // nil if not known
+
+	Head      uint64
+	Lag       uint64
+	Staleness time.Duration
+
+	// Latency is the median of the new block latency (valid only if some
+	// blocks were seen).
+	Latency time.Duration
+	Blocks  uint64
+
+	Problems []CheckProblem
+}
+
+func (r *CheckResult) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Check subscribes to the execution endpoints for a short while and reports
+// whether they are healthy and in agreement with each other.
+type Check struct {
+	opts   CheckOptions
+	server *Server
+
+	// firstHeads are the heads of the groups as of their first blocks
+	firstHeads map[string]uint64
+
+	// hashes are the (latest) hashes of the blocks reported by the endpoints
+	hashes map[string]map[uint64]common.Hash
+
+	mx sync.Mutex
+}
+
+func NewCheck(cfg *config.Config, opts CheckOptions) (*Check, error) {
+	// the check only needs the headers, and its latency statistics span the
+	// whole check
+	checkCfg := *cfg
+	checkCfg.Best = config.Best{Policy: state.BestPolicyLag} // not used by the check
+	checkCfg.Eth.BackfillSkippedBlocks = false
+	checkCfg.Eth.EngineEndpoints = nil
+	checkCfg.Eth.ValidateBlocks = false
+	checkCfg.Probe.Interval = 0
+	checkCfg.Record.Path = ""
+	checkCfg.Stats.Quantiles = []float64{0.5}
+	checkCfg.Stats.Windows = []time.Duration{opts.Timeout}
+	cfg = &checkCfg
+
+	s, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.metrics.setup(s.meter, s.handleEventPrometheusObserve); err != nil {
+		return nil, fmt.Errorf("%w: %w",
+			ErrPrometheusFailedToSetupMetrics, err,
+		)
+	}
+
+	hashes := make(map[string]map[uint64]common.Hash, len(s.subs))
+	for id := range s.subs {
+		hashes[id] = make(map[uint64]common.Hash)
+	}
+
+	return &Check{
+		opts:   opts,
+		server: s,
+
+		firstHeads: make(map[string]uint64),
+		hashes:     hashes,
+	}, nil
+}
+
+// Run waits until each group advances through the configured count of blocks
+// (or until the timeout) and returns the results ordered by endpoint id.
+func (c *Check) Run(ctx context.Context) ([]*CheckResult, error) {
+	s := c.server
+	subCtx := logutils.ContextWithLogger(context.Background(), s.log)
+
+	for _, sub := range s.subs {
+		sub.Subscribe(subCtx, c.handleEventEthNewHeader)
+	}
+	defer func() {
+		for _, sub := range s.subs {
+			sub.Unsubscribe()
+		}
+	}()
+
+	waitCtx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+	c.wait(waitCtx)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	chainIDs := c.chainIDs(ctx)
+	return c.results(s.now(), chainIDs), nil
+}
+
+func (c *Check) handleEventEthNewHeader(
+	ctx context.Context,
+	gname, ename string,
+	ts time.Time,
+	header *ethtypes.Header,
+) {
+	c.server.handleEventEthNewHeader(ctx, gname, ename, ts, header)
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if header.Number.IsUint64() {
+		c.hashes[utils.MakeELEndpointID(gname, ename)][header.Number.Uint64()] = header.Hash()
+	}
+	if _, seen := c.firstHeads[gname]; !seen {
+		c.firstHeads[gname] = c.server.state.ExecutionGroup(gname).HighestBlock().Uint64()
+	}
+}
+
+// wait polls the groups' heads until each of them advances through enough
+// blocks since the group's first block.
+func (c *Check) wait(ctx context.Context) {
+	ticker := time.NewTicker(checkPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		done := true
+		c.mx.Lock()
+		c.server.state.IterateELGroupsRO(func(gname string, g *state.ELGroup) {
+			first, seen := c.firstHeads[gname]
+			if !seen || g.HighestBlock().Uint64()+1 < first+c.opts.Blocks {
+				done = false
+			}
+		})
+		c.mx.Unlock()
+		if done {
+			return
+		}
+	}
+}
+
+// chainIDs queries all endpoints for their chain ids (the ones that failed to
+// respond are omitted).
+func (c *Check) chainIDs(ctx context.Context) map[string]*big.Int {
+	ctx, cancel := context.WithTimeout(ctx, checkChainIDTimeout)
+	defer cancel()
+
+	res := make(map[string]*big.Int, len(c.server.subs))
+	mx := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for id, sub := range c.server.subs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			chainID, err := sub.ChainID(ctx)
+			if err != nil {
+				return
+			}
+			mx.Lock()
+			res[id] = chainID
+			mx.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return res
+}
+
+func (c *Check) results(now time.Time, chainIDs map[string]*big.Int) []*CheckResult {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	res := make([]*CheckResult, 0, len(c.server.subs))
+	c.server.state.IterateELGroupsRO(func(gname string, g *state.ELGroup) {
+		groupHead := g.HighestBlock().Uint64()
+		group := make([]*CheckResult, 0)
+
+		g.IterateEndpointsRO(func(ename string, e *state.ELEndpoint) {
+			id := utils.MakeELEndpointID(gname, ename)
+			head, staleness := e.TimeSinceHighestBlock(now)
+
+			r := &CheckResult{
+				ID:      id,
+				Kind:    e.Kind(),
+				ChainID: chainIDs[id],
+				Head:    uint64(head),
+			}
+			for _, lq := range e.LatencyQuantiles(now, 0.5) {
+				if lq.Count > 0 {
+					r.Latency = time.Duration(lq.Quantiles[0] * float64(time.Second))
+					r.Blocks = lq.Count
+				}
+			}
+
+			if r.ChainID == nil {
+				r.Problems = append(r.Problems, CheckProblemNoChainID)
+			}
+			if r.Head == 0 {
+				r.Problems = append(r.Problems, CheckProblemNoBlocks)
+			} else {
+				r.Staleness = staleness
+				if groupHead > r.Head {
+					r.Lag = groupHead - r.Head
+				}
+				if r.Lag > c.opts.MaxLag {
+					r.Problems = append(r.Problems, CheckProblemBehind)
+				}
+				if r.Staleness > c.opts.MaxStaleness {
+					r.Problems = append(r.Problems, CheckProblemStale)
+				}
+			}
+
+			group = append(group, r)
+		})
+
+		c.checkChains(group)
+		c.checkForks(group, groupHead)
+		res = append(res, group...)
+	})
+
+	slices.SortFunc(res, func(a, b *CheckResult) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return res
+}
+
+// checkChains flags the endpoints whose chain id differs from the one of the
+// majority of the group.
+func (c *Check) checkChains(group []*CheckResult) {
+	votes := make(map[string]int, 1)
+	for _, r := range group {
+		if r.ChainID != nil {
+			votes[r.ChainID.String()]++
+		}
+	}
+	chainID, agreed := majority(votes)
+
+	for _, r := range group {
+		if r.ChainID != nil && (!agreed || r.ChainID.String() != chainID) {
+			r.Problems = append(r.Problems, CheckProblemDifferentChain)
+		}
+	}
+}
+
+// checkForks flags the endpoints that reported the blocks with the hashes that
+// differ from the ones of the majority of the group.  The group's head itself
+// is not compared as it might still be in the middle of a reorg.
+func (c *Check) checkForks(group []*CheckResult, groupHead uint64) {
+	forked := make(map[string]struct{})
+
+	numbers := make(map[uint64]struct{})
+	for _, r := range group {
+		for number := range c.hashes[r.ID] {
+			if number < groupHead {
+				numbers[number] = struct{}{}
+			}
+		}
+	}
+
+	for number := range numbers {
+		votes := make(map[common.Hash]int, 1)
+		for _, r := range group {
+			if hash, reported := c.hashes[r.ID][number]; reported {
+				votes[hash]++
+			}
+		}
+		hash, agreed := majority(votes)
+
+		for _, r := range group {
+			if h, reported := c.hashes[r.ID][number]; reported && (!agreed || h != hash) {
+				forked[r.ID] = struct{}{}
+			}
+		}
+	}
+
+	for _, r := range group {
+		if _, fork := forked[r.ID]; fork {
+			r.Problems = append(r.Problems, CheckProblemFork)
+		}
+	}
+}
+
+// majority returns the value with the most votes (if there's no tie).
+func majority[T comparable](votes map[T]int) (winner T, ok bool) {
+	best := 0
+	for value, count := range votes {
+		switch {
+		case count > best:
+			winner, best, ok = value, count, true
+		case count == best:
+			ok = false
+		}
+	}
+	return winner, ok
+}
@@ -0,0 +1,80 @@
+package server_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flashbots/node-monitor/fakenode"
+	"github.com/flashbots/node-monitor/server"
+	"gotest.tools/assert"
+)
+
+func TestCheck(t *testing.T) {
+	a, b, c, d := fakenode.New(), fakenode.New(), fakenode.New(), fakenode.New()
+	for _, node := range []*fakenode.Node{a, b, c, d} {
+		defer node.Close()
+	}
+	// d is on a different chain altogether
+	d.SetChainID(5)
+
+	cfg := newTestConfig(t, map[string]*fakenode.Node{"g:a": a, "g:b": b, "g:c": c, "g:d": d})
+	check, err := server.NewCheck(cfg, server.CheckOptions{
+		Blocks:       3,
+		MaxLag:       1,
+		MaxStaleness: time.Minute,
+		Timeout:      testTimeout,
+	})
+	assert.NilError(t, err)
+
+	type result struct {
+		results []*server.CheckResult
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		results, err := check.Run(context.Background())
+		done <- result{results, err}
+	}()
+
+	for _, node := range []*fakenode.Node{a, b, c, d} {
+		play(t, node, fakenode.WaitForSubscribers(1))
+	}
+
+	// c gets stuck after the first block
+	chain := fakenode.NewChain(100, time.Now(), 12*time.Second)
+	fork := fakenode.NewChain(100, time.Now().Add(time.Second), 12*time.Second)
+	for i := 0; i < 3; i++ {
+		header := chain.Next()
+		play(t, a, fakenode.AnnounceHeader(header))
+		play(t, b, fakenode.AnnounceHeader(header))
+		if i == 0 {
+			play(t, c, fakenode.AnnounceHeader(header))
+		}
+		play(t, d, fakenode.AnnounceHeader(fork.Next()))
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	res := <-done
+	assert.NilError(t, res.err)
+	assert.Equal(t, 4, len(res.results))
+
+	problems := make(map[string][]server.CheckProblem, len(res.results))
+	for _, r := range res.results {
+		problems[r.ID] = r.Problems
+	}
+	assert.DeepEqual(t, map[string][]server.CheckProblem{
+		"g:a": nil,
+		"g:b": nil,
+		"g:c": {server.CheckProblemBehind},
+		"g:d": {server.CheckProblemDifferentChain, server.CheckProblemFork},
+	}, problems)
+
+	a0 := res.results[0]
+	assert.Equal(t, "g:a", a0.ID)
+	assert.Equal(t, uint64(103), a0.Head)
+	assert.Equal(t, uint64(0), a0.Lag)
+	assert.Equal(t, uint64(fakenode.DefaultChainID), a0.ChainID.Uint64())
+	assert.Equal(t, uint64(3), a0.Blocks)
+	assert.Equal(t, uint64(2), res.results[2].Lag)
+}
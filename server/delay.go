@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/flashbots/node-monitor/logutils"
+	"github.com/flashbots/node-monitor/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+const (
+	delayReferenceHeaderTime = "header_time"
+	delayReferenceSlotStart  = "slot_start"
+)
+
+// recordBlockProductionDelay reports the time passed between the moment the
+// block was supposed to be produced and the moment it was received.
+//
+// Header timestamps have a resolution of one second, therefore on the chains
+// where they are not aligned to the slot boundaries the reported delay is an
+// upper bound that can exceed the real one by up to a second.
+//
+// Receive times that precede the reference within max-clock-skew are clamped
+// to zero (the local clock or the block builder's one is a bit off).  Larger
+// skews are not reported at all, since they would only poison the histogram.
+func (s *Server) recordBlockProductionDelay(
+	ctx context.Context,
+	attrs []attribute.KeyValue,
+	ts time.Time,
+	header *ethtypes.Header,
+) {
+	headerTime := time.Unix(int64(header.Time), 0)
+
+	references := map[string]time.Time{
+		delayReferenceHeaderTime: headerTime,
+	}
+	if s.cfg.Eth.GenesisTime != 0 {
+		genesis := time.Unix(s.cfg.Eth.GenesisTime, 0)
+		references[delayReferenceSlotStart] = utils.SlotStart(genesis, s.cfg.Eth.SlotDuration, headerTime)
+	}
+
+	for reference, start := range references {
+		delay := ts.Sub(start)
+		if delay < 0 {
+			if -delay > s.cfg.Eth.MaxClockSkew {
+				l := logutils.LoggerFromContext(ctx)
+				l.Warn("Block arrived before its production time, check the clocks",
+					zap.String("block", header.Number.String()),
+					zap.Duration("skew", -delay),
+					zap.String("reference", reference),
+					zap.Time("reference_ts", start),
+					zap.Time("ts", ts),
+				)
+				continue
+			}
+			delay = 0
+		}
+
+		s.metrics.blockProductionDelay.Record(ctx,
+			delay.Seconds(),
+			metric.WithAttributes(append(attrs,
+				attribute.String(keyDelayReference, reference),
+			)...),
+		)
+	}
+}
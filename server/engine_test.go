@@ -0,0 +1,72 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/flashbots/node-monitor/fakenode"
+	"gotest.tools/assert"
+)
+
+// writeJWTSecret writes the secret the way the execution clients do (as hex).
+func writeJWTSecret(t *testing.T, secret [32]byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "jwt.hex")
+	assert.NilError(t, os.WriteFile(path, []byte(hexutil.Encode(secret[:])+"\n"), 0o600))
+	return path
+}
+
+func TestEngineProbe(t *testing.T) {
+	a, b, c := fakenode.New(), fakenode.New(), fakenode.New()
+	defer a.Close()
+	defer b.Close()
+	defer c.Close()
+
+	secret := [32]byte{1, 2, 3}
+	capabilities := []string{"engine_forkchoiceUpdatedV3", "engine_newPayloadV3"}
+	engineA := fakenode.NewEngine(secret, capabilities...)
+	defer engineA.Close()
+	engineB := fakenode.NewEngine(secret, capabilities...)
+	defer engineB.Close()
+
+	// the proxy in front of c's engine api fails
+	engineC := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "upstream is down", http.StatusInternalServerError)
+	}))
+	defer engineC.Close()
+
+	// b is probed with the wrong secret
+	cfg := newTestConfig(t, map[string]*fakenode.Node{"g:a": a, "g:b": b, "g:c": c})
+	cfg.Eth.EngineEndpoints = []string{
+		"g:a=" + engineA.URL(),
+		"g:b=" + engineB.URL(),
+		"g:c=" + engineC.URL,
+	}
+	cfg.Eth.EngineJWTSecrets = []string{
+		writeJWTSecret(t, secret),
+		"g:b=" + writeJWTSecret(t, [32]byte{3, 2, 1}),
+	}
+	cfg.Eth.EngineProbeInterval = 100 * time.Millisecond
+	cfg.Eth.EngineRequiredCapabilities = []string{"engine_newPayloadV3"}
+	m := startMonitor(t, cfg)
+
+	// the engine api status is reported before the nodes produce any head
+	m.waitFor(series("engine_api_up", "g", "a", "1"))
+	m.waitFor(series("engine_api_capable", "g", "a", "1"))
+	m.waitFor(series("engine_api_syncing", "g", "a", "0"))
+	m.waitFor(series("engine_api_up", "g", "b", "0"))
+	m.waitFor(series("engine_api_up", "g", "c", "0"))
+
+	// a starts syncing, and drops the required capability
+	engineA.SetSyncing(true)
+	m.waitFor(series("engine_api_syncing", "g", "a", "1"))
+	engineA.SetCapabilities("engine_forkchoiceUpdatedV3")
+	m.waitFor(series("engine_api_capable", "g", "a", "0"))
+	m.waitFor(series("engine_api_up", "g", "a", "1"))
+}
@@ -0,0 +1,489 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/flashbots/node-monitor/logutils"
+	"github.com/flashbots/node-monitor/state"
+	"github.com/flashbots/node-monitor/utils"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	eventsBufferSize        = 256
+	eventsKeepaliveInterval = 15 * time.Second
+
+	eventTypeArrival      = "arrival"
+	eventTypeBackfilled   = "backfilled"
+	eventTypeFork         = "fork"
+	eventTypeHead         = "head"
+	eventTypeHeader       = "header"
+	eventTypeReorg        = "reorg"
+	eventTypeSubscription = "subscription"
+)
+
+// apiEvent is what the live stream subscribers receive.
+type apiEvent struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+
+	group string // normalised group of the event
+	id    string // endpoint id (empty for the group-level events)
+}
+
+// apiHeaderEvent is the new header received by the endpoint.
+type apiHeaderEvent struct {
+	Group    string `json:"group"`
+	Endpoint string `json:"endpoint"`
+	ID       string `json:"id"`
+	Kind     string `json:"kind"`
+
+	Block uint64    `json:"block"`
+	Hash  string    `json:"hash"`
+	Time  time.Time `json:"ts"`
+
+	// Latency is omitted for the blocks that were too late to be accounted
+	Latency *float64 `json:"latency_s,omitempty"`
+}
+
+// apiHeadEvent is the move of the group's head.
+type apiHeadEvent struct {
+	Group string    `json:"group"`
+	Block uint64    `json:"block"`
+	Time  time.Time `json:"ts"`
+}
+
+// apiSubscriptionEvent is the change of the endpoint's subscription state.
+type apiSubscriptionEvent struct {
+	Group    string `json:"group"`
+	Endpoint string `json:"endpoint"`
+	ID       string `json:"id"`
+
+	Subscribed bool      `json:"subscribed"`
+	Error      string    `json:"error,omitempty"`
+	Time       time.Time `json:"ts"`
+}
+
+// apiBackfilledEvent is the header that the endpoint jumped over, and that
+// was fetched afterwards.  It was never announced, so it has no arrival time
+// nor latency.
+type apiBackfilledEvent struct {
+	Group    string `json:"group"`
+	Endpoint string `json:"endpoint"`
+	ID       string `json:"id"`
+
+	Block     uint64    `json:"block"`
+	Hash      string    `json:"hash"`
+	BlockTime time.Time `json:"block_ts"` // as per the header
+	Time      time.Time `json:"ts"`       // when it was fetched
+}
+
+// apiReorgEvent is the endpoint replacing the blocks it reported before.
+type apiReorgEvent struct {
+	Group    string `json:"group"`
+	Endpoint string `json:"endpoint"`
+	ID       string `json:"id"`
+
+	Block uint64    `json:"block"`
+	Hash  string    `json:"hash"`
+	Depth uint64    `json:"depth"`
+	Time  time.Time `json:"ts"`
+}
+
+// apiForkEvent is the endpoint reporting the block that differs from the one
+// the rest of the group is on.
+type apiForkEvent struct {
+	Group    string `json:"group"`
+	Endpoint string `json:"endpoint"`
+	ID       string `json:"id"`
+
+	Block     uint64    `json:"block"`
+	Hash      string    `json:"hash"`
+	GroupHash string    `json:"group_hash"`
+	Time      time.Time `json:"ts"`
+}
+
+// eventHub fans the events out to the live stream subscribers.  The slow
+// subscribers miss the events rather than hold the publishers back.
+type eventHub struct {
+	subscribers map[chan *apiEvent]struct{}
+	closed      bool
+
+	mx sync.RWMutex
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		subscribers: make(map[chan *apiEvent]struct{}),
+	}
+}
+
+func (h *eventHub) publish(event *apiEvent) {
+	h.mx.RLock()
+	defer h.mx.RUnlock()
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// subscribe returns the channel with the events and the function to stop
+// receiving them.  The channel is closed once the hub is closed.
+func (h *eventHub) subscribe() (<-chan *apiEvent, func()) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	ch := make(chan *apiEvent, eventsBufferSize)
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	h.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		h.mx.Lock()
+		defer h.mx.Unlock()
+
+		if _, exists := h.subscribers[ch]; exists {
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (h *eventHub) close() {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	h.closed = true
+	for ch := range h.subscribers {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+// eventFilter selects the events by their groups, endpoints and types (the
+// empty criteria match everything).  The group-level events match any of the
+// endpoints.
+type eventFilter struct {
+	groups    map[string]struct{}
+	endpoints map[string]struct{}
+	types     map[string]struct{}
+}
+
+func newEventFilter(query url.Values) *eventFilter {
+	set := func(values []string) map[string]struct{} {
+		res := make(map[string]struct{}, len(values))
+		for _, v := range values {
+			res[v] = struct{}{}
+		}
+		return res
+	}
+
+	return &eventFilter{
+		groups:    set(query["group"]),
+		endpoints: set(query["endpoint"]),
+		types:     set(query["type"]),
+	}
+}
+
+func (f *eventFilter) match(event *apiEvent) bool {
+	if len(f.groups) > 0 {
+		if _, match := f.groups[event.group]; !match {
+			return false
+		}
+	}
+	if len(f.endpoints) > 0 && event.id != "" {
+		if _, match := f.endpoints[event.id]; !match {
+			return false
+		}
+	}
+	if len(f.types) > 0 {
+		if _, match := f.types[event.Type]; !match {
+			return false
+		}
+	}
+	return true
+}
+
+// publishHeaderEvents publishes the header along with whatever it tells about
+// the group's head and the endpoint's chain, and returns the latter.
+func (s *Server) publishHeaderEvents(
+	ctx context.Context,
+	gname, ename string,
+	kind state.EndpointKind,
+	ts time.Time,
+	header *ethtypes.Header,
+	latency time.Duration,
+	groupHead uint64,
+) chainFindings {
+	l := logutils.LoggerFromContext(ctx)
+
+	group := normalisedGroup(gname)
+	id := utils.MakeELEndpointID(gname, ename)
+	hash := header.Hash().String()
+
+	event := &apiHeaderEvent{
+		Group:    group,
+		Endpoint: ename,
+		ID:       id,
+		Kind:     string(kind),
+
+		Block: header.Number.Uint64(),
+		Hash:  hash,
+		Time:  ts,
+	}
+	if latency != state.Infinity {
+		latency_s := latency.Seconds()
+		event.Latency = &latency_s
+	}
+	s.events.publish(&apiEvent{Type: eventTypeHeader, Data: event, group: group, id: id})
+
+	findings := s.chain.track(gname, id, header, groupHead)
+
+	if findings.reorg > 0 {
+		l.Info("Execution endpoint reorged",
+			zap.String("block", header.Number.String()),
+			zap.String("block_hash", hash),
+			zap.Uint64("depth", findings.reorg),
+			zap.String("endpoint_group", gname),
+			zap.String("endpoint_name", ename),
+		)
+		s.events.publish(&apiEvent{
+			Type: eventTypeReorg,
+			Data: &apiReorgEvent{
+				Group:    group,
+				Endpoint: ename,
+				ID:       id,
+
+				Block: header.Number.Uint64(),
+				Hash:  hash,
+				Depth: findings.reorg,
+				Time:  ts,
+			},
+			group: group,
+			id:    id,
+		})
+	}
+
+	if findings.fork != nil {
+		l.Warn("Execution endpoint reported a block that differs from the group's one",
+			zap.String("block", header.Number.String()),
+			zap.String("block_hash", hash),
+			zap.String("group_block_hash", findings.fork.String()),
+			zap.String("endpoint_group", gname),
+			zap.String("endpoint_name", ename),
+		)
+		s.events.publish(&apiEvent{
+			Type: eventTypeFork,
+			Data: &apiForkEvent{
+				Group:    group,
+				Endpoint: ename,
+				ID:       id,
+
+				Block:     header.Number.Uint64(),
+				Hash:      hash,
+				GroupHash: findings.fork.String(),
+				Time:      ts,
+			},
+			group: group,
+			id:    id,
+		})
+	}
+
+	if findings.head {
+		s.events.publish(&apiEvent{
+			Type: eventTypeHead,
+			Data: &apiHeadEvent{
+				Group: group,
+				Block: groupHead,
+				Time:  ts,
+			},
+			group: group,
+		})
+	}
+
+	return findings
+}
+
+func (s *Server) handleEventEthSubscription(
+	_ context.Context,
+	gname, ename string,
+	subscribed bool,
+	err error,
+) {
+	group := normalisedGroup(gname)
+	id := utils.MakeELEndpointID(gname, ename)
+
+	event := &apiSubscriptionEvent{
+		Group:    group,
+		Endpoint: ename,
+		ID:       id,
+
+		Subscribed: subscribed,
+		Time:       s.now(),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	s.events.publish(&apiEvent{Type: eventTypeSubscription, Data: event, group: group, id: id})
+}
+
+var eventsUpgrader = websocket.Upgrader{}
+
+// handleEvents streams the monitor's events as server-sent events (or over
+// the websocket if the client asks for the upgrade).
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	l := logutils.LoggerFromRequest(r)
+
+	filter := newEventFilter(r.URL.Query())
+	events, unsubscribe := s.events.subscribe()
+	defer unsubscribe()
+
+	if websocket.IsWebSocketUpgrade(r) {
+		conn, err := eventsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// the upgrader has already responded with the error
+			l.Warn("Failed to upgrade events stream to websocket",
+				zap.Error(err),
+			)
+			return
+		}
+		defer conn.Close()
+		streamEventsWebsocket(conn, events, filter)
+		return
+	}
+
+	streamEventsSSE(w, r, events, filter)
+}
+
+// streamEventsSSE sends each event as the server-sent event of its type until
+// the client goes away (or the events are over).
+func streamEventsSSE(
+	w http.ResponseWriter,
+	r *http.Request,
+	events <-chan *apiEvent,
+	filter *eventFilter,
+) {
+	l := logutils.LoggerFromRequest(r)
+
+	rc := http.NewResponseController(w)
+	// the stream outlives the server's write timeout
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		l.Error("Failed to flush events stream",
+			zap.Error(err),
+		)
+		return
+	}
+
+	keepalive := time.NewTicker(eventsKeepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if !filter.match(event) {
+				continue
+			}
+			data, err := json.Marshal(event.Data)
+			if err != nil {
+				l.Error("Failed to encode event",
+					zap.String("type", event.Type),
+					zap.Error(err),
+				)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// streamEventsWebsocket sends each event as a json message with its type and
+// data until the client goes away.
+func streamEventsWebsocket(
+	conn *websocket.Conn,
+	events <-chan *apiEvent,
+	filter *eventFilter,
+) {
+	// the client is not expected to send anything, but we have to read to
+	// learn when it's gone (and to process the pongs)
+	gone := make(chan struct{})
+	_ = conn.SetReadDeadline(time.Now().Add(2 * eventsKeepaliveInterval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * eventsKeepaliveInterval))
+	})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	keepalive := time.NewTicker(eventsKeepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-gone:
+			return
+
+		case <-keepalive.C:
+			deadline := time.Now().Add(eventsKeepaliveInterval)
+			if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				return
+			}
+
+		case event, ok := <-events:
+			if !ok {
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, ""),
+					time.Now().Add(time.Second),
+				)
+				return
+			}
+			if !filter.match(event) {
+				continue
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(eventsKeepaliveInterval))
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		}
+	}
+}
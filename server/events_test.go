@@ -0,0 +1,255 @@
+package server_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/flashbots/node-monitor/fakenode"
+	"github.com/gorilla/websocket"
+	"gotest.tools/assert"
+)
+
+// nextEvent reads the stream until the next event of the given type and
+// returns its data.
+func nextEvent(t *testing.T, r *bufio.Reader, typ string) string {
+	t.Helper()
+
+	current := ""
+	for {
+		line, err := r.ReadString('\n')
+		assert.NilError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			current = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: ") && current == typ:
+			return strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	a, b := fakenode.New(), fakenode.New()
+	defer a.Close()
+	defer b.Close()
+
+	m := startMonitor(t, newTestConfig(t, map[string]*fakenode.Node{"g:a": a, "g:b": b}))
+
+	res, err := http.Get(m.url + "/api/v1/events")
+	assert.NilError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	stream := bufio.NewReader(res.Body)
+
+	type event struct {
+		ID      string   `json:"id"`
+		Group   string   `json:"group"`
+		Block   uint64   `json:"block"`
+		Hash    string   `json:"hash"`
+		Latency *float64 `json:"latency_s"`
+	}
+
+	chain := fakenode.NewChain(100, time.Now(), 12*time.Second)
+	header := chain.Next()
+	for _, step := range []struct {
+		id   string
+		node *fakenode.Node
+	}{{"g:a", a}, {"g:b", b}} {
+		// b is late by at least 50ms
+		play(t, step.node, fakenode.WaitForSubscribers(1), fakenode.Sleep(50*time.Millisecond), fakenode.AnnounceHeader(header))
+
+		e := event{}
+		assert.NilError(t, json.Unmarshal([]byte(nextEvent(t, stream, "header")), &e))
+		assert.Equal(t, step.id, e.ID)
+		assert.Equal(t, "g", e.Group)
+		assert.Equal(t, uint64(101), e.Block)
+		assert.Equal(t, header.Hash().String(), e.Hash)
+		assert.Assert(t, e.Latency != nil)
+		if step.id == "g:b" {
+			assert.Assert(t, *e.Latency >= 0.05)
+		}
+	}
+
+	// the stream ends when the monitor stops
+	m.stop()
+	_, err = io.ReadAll(stream)
+	assert.NilError(t, err)
+}
+
+func TestEventsWebsocket(t *testing.T) {
+	a, b := fakenode.New(), fakenode.New()
+	defer a.Close()
+	defer b.Close()
+
+	m := startMonitor(t, newTestConfig(t, map[string]*fakenode.Node{"g:a": a, "g:b": b}))
+	// the initial subscriptions are not streamed
+	for deadline := time.Now().Add(testTimeout); ; {
+		res, err := http.Get(m.url + "/api/v1/status")
+		assert.NilError(t, err)
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		assert.NilError(t, err)
+		if strings.Count(string(body), `"subscribed":true`) == 2 {
+			break
+		}
+		assert.Assert(t, time.Now().Before(deadline), "not subscribed: %s", body)
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	url := "ws" + strings.TrimPrefix(m.url, "http") + "/api/v1/events" +
+		"?endpoint=g:b&type=head&type=fork&type=reorg&type=subscription"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.NilError(t, err)
+	defer conn.Close()
+
+	type event struct {
+		Type string `json:"type"`
+		Data struct {
+			ID         string `json:"id"`
+			Block      uint64 `json:"block"`
+			Hash       string `json:"hash"`
+			GroupHash  string `json:"group_hash"`
+			Depth      uint64 `json:"depth"`
+			Subscribed bool   `json:"subscribed"`
+		} `json:"data"`
+	}
+	next := func() event {
+		t.Helper()
+		assert.NilError(t, conn.SetReadDeadline(time.Now().Add(testTimeout)))
+		e := event{}
+		assert.NilError(t, conn.ReadJSON(&e))
+		return e
+	}
+
+	now := time.Now()
+	chain := fakenode.NewChain(100, now, 12*time.Second)
+	fork := fakenode.NewChain(100, now.Add(time.Second), 12*time.Second)
+	header, forked := chain.Next(), fork.Next()
+
+	// the group-level events pass the endpoint filter
+	play(t, a, fakenode.AnnounceHeader(header))
+	e := next()
+	assert.Equal(t, "head", e.Type)
+	assert.Equal(t, uint64(101), e.Data.Block)
+
+	// b is on another chain
+	play(t, b, fakenode.AnnounceHeader(forked))
+	e = next()
+	assert.Equal(t, "fork", e.Type)
+	assert.Equal(t, "g:b", e.Data.ID)
+	assert.Equal(t, forked.Hash().String(), e.Data.Hash)
+	assert.Equal(t, header.Hash().String(), e.Data.GroupHash)
+
+	// ...until it reorgs onto the group's one
+	play(t, b, fakenode.AnnounceHeader(header))
+	e = next()
+	assert.Equal(t, "reorg", e.Type)
+	assert.Equal(t, "g:b", e.Data.ID)
+	assert.Equal(t, uint64(1), e.Data.Depth)
+
+	play(t, b, fakenode.DropConnections())
+	e = next()
+	assert.Equal(t, "subscription", e.Type)
+	assert.Equal(t, "g:b", e.Data.ID)
+	assert.Equal(t, false, e.Data.Subscribed)
+	e = next()
+	assert.Equal(t, "subscription", e.Type)
+	assert.Equal(t, true, e.Data.Subscribed)
+}
+
+func TestUI(t *testing.T) {
+	m := startMonitor(t, newTestConfig(t, map[string]*fakenode.Node{}))
+
+	res, err := http.Get(m.url + "/ui/")
+	assert.NilError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(body), `new EventSource("../api/v1/events")`))
+}
+
+func TestBackfilledEvents(t *testing.T) {
+	node := fakenode.New()
+	defer node.Close()
+
+	cfg := newTestConfig(t, map[string]*fakenode.Node{"g:a": node})
+	cfg.Eth.BackfillSkippedBlocks = true
+	m := startMonitor(t, cfg)
+
+	res, err := http.Get(m.url + "/api/v1/events?type=backfilled")
+	assert.NilError(t, err)
+	defer res.Body.Close()
+	stream := bufio.NewReader(res.Body)
+
+	type event struct {
+		ID        string    `json:"id"`
+		Block     uint64    `json:"block"`
+		Hash      string    `json:"hash"`
+		BlockTime time.Time `json:"block_ts"`
+	}
+
+	// the node jumps from 101 to 104
+	chain := fakenode.NewChain(100, time.Now(), 12*time.Second)
+	play(t, node, fakenode.WaitForSubscribers(1), fakenode.AnnounceHeader(chain.Next()))
+	m.waitFor(series("highest_block", "g", "a", "101"))
+	skipped := []*ethtypes.Header{chain.Next(), chain.Next()}
+	for _, header := range skipped {
+		node.Store(header)
+	}
+	play(t, node, fakenode.AnnounceHeader(chain.Next()))
+
+	for _, header := range skipped {
+		e := event{}
+		assert.NilError(t, json.Unmarshal([]byte(nextEvent(t, stream, "backfilled")), &e))
+		assert.Equal(t, "g:a", e.ID)
+		assert.Equal(t, header.Number.Uint64(), e.Block)
+		assert.Equal(t, header.Hash().String(), e.Hash)
+		assert.Assert(t, e.BlockTime.Equal(time.Unix(int64(header.Time), 0)))
+	}
+}
+
+func TestSubscriptionEvents(t *testing.T) {
+	node := fakenode.New()
+	defer node.Close()
+	node.Fail(fakenode.MethodSubscribeNewHeads, errors.New("too many subscriptions"))
+
+	// the first attempt to subscribe comes after the resubscribe interval,
+	// which leaves the time to open the stream
+	cfg := newTestConfig(t, map[string]*fakenode.Node{"g:a": node})
+	cfg.Eth.ResubscribeInterval = 500 * time.Millisecond
+	m := startMonitor(t, cfg)
+
+	client := &http.Client{Timeout: testTimeout}
+	res, err := client.Get(m.url + "/api/v1/events?type=subscription")
+	assert.NilError(t, err)
+	defer res.Body.Close()
+	stream := bufio.NewReader(res.Body)
+
+	type event struct {
+		ID         string `json:"id"`
+		Subscribed bool   `json:"subscribed"`
+		Error      string `json:"error"`
+	}
+
+	// the failed attempts are reported once
+	e := event{}
+	assert.NilError(t, json.Unmarshal([]byte(nextEvent(t, stream, "subscription")), &e))
+	assert.Equal(t, "g:a", e.ID)
+	assert.Equal(t, false, e.Subscribed)
+	assert.Assert(t, strings.Contains(e.Error, "too many subscriptions"), e.Error)
+	time.Sleep(3 * cfg.Eth.ResubscribeInterval)
+
+	node.Fail(fakenode.MethodSubscribeNewHeads, nil)
+	e = event{}
+	assert.NilError(t, json.Unmarshal([]byte(nextEvent(t, stream, "subscription")), &e))
+	assert.Equal(t, true, e.Subscribed)
+	assert.Equal(t, "", e.Error)
+}
@@ -0,0 +1,11 @@
+package server
+
+import (
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// ValidateBlock exposes the block validation to the tests.
+var ValidateBlock = validateBlock
+
+// NewTrieHasher exposes the trie hasher to the tests.
+var NewTrieHasher = func() ethtypes.TrieHasher { return newTrieHasher() }
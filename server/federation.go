@@ -0,0 +1,458 @@
+package server
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/flashbots/node-monitor/config"
+	"github.com/flashbots/node-monitor/logutils"
+	"github.com/flashbots/node-monitor/state"
+	"github.com/flashbots/node-monitor/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+const (
+	federationRecentBlocks  = 32
+	federationSettledBlocks = 1024
+)
+
+var (
+	ErrFederationDuplicatePeer     = errors.New("duplicate federation peer region")
+	ErrFederationMissingToken      = errors.New("federation requires the token to authenticate the peers with")
+	ErrFederationUnexpectedPeer    = errors.New("unexpected federation peer (must look like `region=http://127.0.0.1:8080`)")
+	ErrFederationUnexpectedStatus  = errors.New("unexpected federation peer response status")
+	ErrFederationPeerStreamStalled = errors.New("federation peer stream stalled")
+)
+
+// apiArrivalEvent is the first arrival of the block within the group as seen
+// by the region's monitor.
+type apiArrivalEvent struct {
+	Region   string `json:"region"`
+	Group    string `json:"group"`
+	Endpoint string `json:"endpoint"` // the first endpoint to report the block
+
+	Block uint64    `json:"block"`
+	Hash  string    `json:"hash"`
+	Time  time.Time `json:"ts"` // as per the region's local clock
+
+	// ClockOffset is how much the region's local clock must be adjusted by to
+	// match the ntp servers' one
+	ClockOffset float64 `json:"clock_offset_s"`
+}
+
+type apiFederation struct {
+	Region      string  `json:"region"`
+	ClockOffset float64 `json:"clock_offset_s"`
+
+	Peers map[string]*apiFederationPeer `json:"peers"`
+
+	// Blocks are the most recent blocks that settled (the newest first)
+	Blocks []*apiFederationBlock `json:"blocks"`
+}
+
+type apiFederationPeer struct {
+	Connected bool   `json:"connected"`
+	Error     string `json:"error,omitempty"`
+}
+
+type apiFederationBlock struct {
+	Group       string `json:"group"`
+	Block       uint64 `json:"block"`
+	Hash        string `json:"hash"`
+	FirstRegion string `json:"first_region"`
+
+	// Latencies are the (clock-offset corrected) delays of the arrivals in
+	// each region after the first one
+	Latencies map[string]float64 `json:"latency_s"`
+}
+
+// federation exchanges the first arrivals of the blocks with the monitors in
+// the other regions, and derives the cross-region propagation latency once
+// the arrivals of the block settle.
+type federation struct {
+	region string
+	settle time.Duration
+	token  string
+	peers  map[string]*federationPeer // region -> peer
+
+	arrivals *eventHub // local arrivals for the peers to follow
+
+	blocks     map[federationBlockKey]*federationBlock
+	recent     []*apiFederationBlock
+	tombstones *utils.BlockRing[map[federationBlockKey]struct{}] // the settled blocks
+
+	mx sync.Mutex
+}
+
+type federationPeer struct {
+	region string
+	url    string
+
+	connected atomic.Bool
+	lastError atomic.Pointer[string]
+}
+
+type federationBlockKey struct {
+	group  string
+	number uint64
+	hash   string
+}
+
+type federationBlock struct {
+	received time.Time            // local receive time of the first arrival
+	arrivals map[string]time.Time // region -> corrected arrival time
+}
+
+func newFederation(cfg *config.Config) (*federation, error) {
+	if cfg.Federation.Region == "" {
+		return nil, nil
+	}
+	if cfg.Federation.Token == "" {
+		return nil, ErrFederationMissingToken
+	}
+
+	peers := make(map[string]*federationPeer, len(cfg.Federation.Peers))
+	for _, peer := range cfg.Federation.Peers {
+		region, uri, found := strings.Cut(peer, "=")
+		if !found || region == "" {
+			return nil, fmt.Errorf("%w: %s",
+				ErrFederationUnexpectedPeer, peer,
+			)
+		}
+		if _, err := url.ParseRequestURI(uri); err != nil {
+			return nil, fmt.Errorf("%w: %s: %w",
+				ErrFederationUnexpectedPeer, peer, err,
+			)
+		}
+		if _, exists := peers[region]; exists || region == cfg.Federation.Region {
+			return nil, fmt.Errorf("%w: %s",
+				ErrFederationDuplicatePeer, region,
+			)
+		}
+		peers[region] = &federationPeer{
+			region: region,
+			url:    strings.TrimSuffix(uri, "/") + "/api/v1/federation/arrivals",
+		}
+	}
+
+	return &federation{
+		region: cfg.Federation.Region,
+		settle: cfg.Federation.Settle,
+		token:  cfg.Federation.Token,
+		peers:  peers,
+
+		arrivals:   newEventHub(),
+		blocks:     make(map[federationBlockKey]*federationBlock),
+		tombstones: utils.NewBlockRing[map[federationBlockKey]struct{}](federationSettledBlocks),
+	}, nil
+}
+
+// record remembers the arrival (the earliest one per region counts).  The
+// arrivals of the blocks that settled already are too late to be accounted.
+func (f *federation) record(arrival *apiArrivalEvent, received time.Time) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	key := federationBlockKey{
+		group:  arrival.Group,
+		number: arrival.Block,
+		hash:   arrival.Hash,
+	}
+	if settled, exists := f.tombstones.Get(key.number); exists {
+		if _, late := settled[key]; late {
+			return
+		}
+	}
+	block, exists := f.blocks[key]
+	if !exists {
+		block = &federationBlock{
+			received: received,
+			arrivals: make(map[string]time.Time),
+		}
+		f.blocks[key] = block
+	}
+
+	corrected := arrival.Time.Add(time.Duration(arrival.ClockOffset * float64(time.Second)))
+	if prev, seen := block.arrivals[arrival.Region]; !seen || corrected.Before(prev) {
+		block.arrivals[arrival.Region] = corrected
+	}
+}
+
+// settled removes the blocks that were first received long enough ago and
+// returns them.
+func (f *federation) settled(now time.Time) []*apiFederationBlock {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	res := make([]*apiFederationBlock, 0)
+	for key, block := range f.blocks {
+		if now.Sub(block.received) < f.settle {
+			continue
+		}
+		delete(f.blocks, key)
+		tombstones, exists := f.tombstones.Get(key.number)
+		if !exists {
+			tombstones = make(map[federationBlockKey]struct{})
+			f.tombstones.Put(key.number, tombstones)
+		}
+		tombstones[key] = struct{}{}
+
+		settled := &apiFederationBlock{
+			Group:     key.group,
+			Block:     key.number,
+			Hash:      key.hash,
+			Latencies: make(map[string]float64, len(block.arrivals)),
+		}
+		var first time.Time
+		for region, ts := range block.arrivals {
+			if settled.FirstRegion == "" || ts.Before(first) {
+				settled.FirstRegion = region
+				first = ts
+			}
+		}
+		for region, ts := range block.arrivals {
+			settled.Latencies[region] = ts.Sub(first).Seconds()
+		}
+		res = append(res, settled)
+	}
+
+	slices.SortFunc(res, func(a, b *apiFederationBlock) int { // the newest first
+		if a.Block != b.Block {
+			return cmp.Compare(b.Block, a.Block)
+		}
+		return strings.Compare(a.Group, b.Group)
+	})
+	f.recent = append(res, f.recent...)
+	if len(f.recent) > federationRecentBlocks {
+		f.recent = f.recent[:federationRecentBlocks]
+	}
+
+	return res
+}
+
+// clockOffset is the median of the local clock's offsets as per the ntp
+// servers (zero if there are none).
+func (s *Server) clockOffset() time.Duration {
+	offsets := make([]time.Duration, 0)
+	s.state.IterateClockOffsetsRO(func(_ string, offset state.ClockOffset) {
+		if offset.Error == "" {
+			offsets = append(offsets, offset.Offset)
+		}
+	})
+	if len(offsets) == 0 {
+		return 0
+	}
+	slices.Sort(offsets)
+	return offsets[len(offsets)/2]
+}
+
+// publishFederationArrival shares the group's first arrival of the block with
+// the peers.
+func (s *Server) publishFederationArrival(
+	gname, ename string,
+	ts time.Time,
+	header *ethtypes.Header,
+) {
+	arrival := &apiArrivalEvent{
+		Region:   s.federation.region,
+		Group:    normalisedGroup(gname),
+		Endpoint: utils.MakeELEndpointID(gname, ename),
+
+		Block: header.Number.Uint64(),
+		Hash:  header.Hash().String(),
+		Time:  ts,
+
+		ClockOffset: s.clockOffset().Seconds(),
+	}
+
+	s.federation.record(arrival, ts)
+	s.federation.arrivals.publish(&apiEvent{
+		Type:  eventTypeArrival,
+		Data:  arrival,
+		group: arrival.Group,
+	})
+}
+
+// runFederation follows the peers and settles the blocks until the context is
+// done.
+func (s *Server) runFederation(ctx context.Context) {
+	for _, peer := range s.federation.peers {
+		go s.followFederationPeer(ctx, peer)
+	}
+
+	ticker := time.NewTicker(max(s.federation.settle/4, 10*time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			for _, block := range s.federation.settled(s.now()) {
+				attrs := []attribute.KeyValue{
+					attribute.String(keyTargetGroup, block.Group),
+					attribute.String(keyRegion, block.FirstRegion),
+				}
+				s.metrics.federationBlocksFirstSeen.Add(ctx, 1, metric.WithAttributes(attrs...))
+
+				for region, latency := range block.Latencies {
+					s.metrics.federationLatency.Record(ctx, latency, metric.WithAttributes(
+						attribute.String(keyTargetGroup, block.Group),
+						attribute.String(keyRegion, region),
+					))
+				}
+			}
+		}
+	}
+}
+
+// followFederationPeer streams the peer's arrivals (and reconnects whenever
+// the stream breaks) until the context is done.
+func (s *Server) followFederationPeer(ctx context.Context, peer *federationPeer) {
+	l := logutils.LoggerFromContext(ctx)
+
+	for {
+		err := s.streamFederationPeer(ctx, peer)
+		peer.connected.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+
+		msg := err.Error()
+		peer.lastError.Store(&msg)
+		l.Warn("Federation peer stream broke; reconnecting...",
+			zap.String("region", peer.region),
+			zap.Error(err),
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.cfg.Eth.ResubscribeInterval):
+		}
+	}
+}
+
+func (s *Server) streamFederationPeer(ctx context.Context, peer *federationPeer) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, peer.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.federation.token)
+	req.Header.Set("Accept", "text/event-stream")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %d",
+			ErrFederationUnexpectedStatus, res.StatusCode,
+		)
+	}
+
+	peer.connected.Store(true)
+	peer.lastError.Store(nil)
+
+	// the peer sends the keepalives, so the silence means it's gone
+	watchdog := time.AfterFunc(2*eventsKeepaliveInterval, func() {
+		cancel(ErrFederationPeerStreamStalled)
+	})
+	defer watchdog.Stop()
+
+	reader := bufio.NewReader(res.Body)
+	current := ""
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if cause := context.Cause(ctx); cause != nil {
+				return cause
+			}
+			return err
+		}
+		watchdog.Reset(2 * eventsKeepaliveInterval)
+
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			current = ""
+
+		case strings.HasPrefix(line, "event: "):
+			current = strings.TrimPrefix(line, "event: ")
+
+		case strings.HasPrefix(line, "data: ") && current == eventTypeArrival:
+			arrival := &apiArrivalEvent{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), arrival); err != nil {
+				return err
+			}
+			// the peer can't speak for the others
+			arrival.Region = peer.region
+			s.federation.record(arrival, s.now())
+		}
+	}
+}
+
+// handleFederationArrivals streams the local arrivals to the authenticated
+// peers.
+func (s *Server) handleFederationArrivals(w http.ResponseWriter, r *http.Request) {
+	expected := []byte("Bearer " + s.federation.token)
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	events, unsubscribe := s.federation.arrivals.subscribe()
+	defer unsubscribe()
+
+	streamEventsSSE(w, r, events, newEventFilter(r.URL.Query()))
+}
+
+func (s *Server) handleFederation(w http.ResponseWriter, r *http.Request) {
+	l := logutils.LoggerFromRequest(r)
+
+	res := &apiFederation{
+		Region:      s.federation.region,
+		ClockOffset: s.clockOffset().Seconds(),
+		Peers:       make(map[string]*apiFederationPeer, len(s.federation.peers)),
+	}
+	for region, peer := range s.federation.peers {
+		status := &apiFederationPeer{
+			Connected: peer.connected.Load(),
+		}
+		if err := peer.lastError.Load(); err != nil {
+			status.Error = *err
+		}
+		res.Peers[region] = status
+	}
+
+	s.federation.mx.Lock()
+	res.Blocks = slices.Clone(s.federation.recent)
+	s.federation.mx.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		l.Error("Failed to encode federation response",
+			zap.Error(err),
+		)
+	}
+}
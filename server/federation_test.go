@@ -0,0 +1,125 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/flashbots/node-monitor/config"
+	"github.com/flashbots/node-monitor/fakenode"
+	"gotest.tools/assert"
+)
+
+type federation struct {
+	Region string `json:"region"`
+	Peers  map[string]struct {
+		Connected bool `json:"connected"`
+	} `json:"peers"`
+	Blocks []struct {
+		Block       uint64             `json:"block"`
+		FirstRegion string             `json:"first_region"`
+		Latencies   map[string]float64 `json:"latency_s"`
+	} `json:"blocks"`
+}
+
+// startFederation starts the monitors of eu (following the node a) and us
+// (following the node b) peered with each other, and waits for them to
+// connect.
+func startFederation(t *testing.T, a, b *fakenode.Node, settle time.Duration) (eu, us *monitor) {
+	t.Helper()
+
+	cfgEU := newTestConfig(t, map[string]*fakenode.Node{"g:a": a})
+	cfgUS := newTestConfig(t, map[string]*fakenode.Node{"g:b": b})
+	cfgEU.Federation.Region = "eu"
+	cfgEU.Federation.Peers = []string{"us=http://" + cfgUS.Server.ListenAddress}
+	cfgUS.Federation.Region = "us"
+	cfgUS.Federation.Peers = []string{"eu=http://" + cfgEU.Server.ListenAddress}
+	for _, cfg := range []*config.Config{cfgEU, cfgUS} {
+		cfg.Federation.Settle = settle
+		cfg.Federation.Token = "secret"
+	}
+	eu, us = startMonitor(t, cfgEU), startMonitor(t, cfgUS)
+
+	eu.waitFor(regexp.MustCompile(`^node_monitor_federation_peer_up\{.*node_monitor_region="us".*\} 1$`))
+	us.waitFor(regexp.MustCompile(`^node_monitor_federation_peer_up\{.*node_monitor_region="eu".*\} 1$`))
+
+	return eu, us
+}
+
+func TestFederation(t *testing.T) {
+	a, b := fakenode.New(), fakenode.New()
+	defer a.Close()
+	defer b.Close()
+
+	eu, us := startFederation(t, a, b, 500*time.Millisecond)
+
+	// the block reaches eu first, and us ~200ms later
+	chain := fakenode.NewChain(100, time.Now(), 12*time.Second)
+	header := chain.Next()
+	play(t, a, fakenode.WaitForSubscribers(1), fakenode.AnnounceHeader(header))
+	play(t, b, fakenode.WaitForSubscribers(1), fakenode.Sleep(200*time.Millisecond), fakenode.AnnounceHeader(header))
+
+	for _, m := range []*monitor{eu, us} {
+		m.waitFor(regexp.MustCompile(
+			`^node_monitor_federation_blocks_first_seen_total\{.*node_monitor_region="eu".*\} 1$`,
+		))
+		m.waitFor(regexp.MustCompile(
+			`^node_monitor_federation_latency_seconds_bucket\{.*node_monitor_region="us".*le="0.1875".*\} 0$`,
+		))
+		m.waitFor(regexp.MustCompile(
+			`^node_monitor_federation_latency_seconds_bucket\{.*node_monitor_region="us".*le="0.75".*\} 1$`,
+		))
+	}
+
+	res, err := http.Get(eu.url + "/api/v1/federation")
+	assert.NilError(t, err)
+	defer res.Body.Close()
+	f := &federation{}
+	assert.NilError(t, json.NewDecoder(res.Body).Decode(f))
+	assert.Equal(t, "eu", f.Region)
+	assert.Assert(t, f.Peers["us"].Connected)
+	assert.Equal(t, 1, len(f.Blocks))
+	assert.Equal(t, uint64(101), f.Blocks[0].Block)
+	assert.Equal(t, "eu", f.Blocks[0].FirstRegion)
+	assert.Equal(t, float64(0), f.Blocks[0].Latencies["eu"])
+	assert.Assert(t, f.Blocks[0].Latencies["us"] >= 0.2, f.Blocks[0].Latencies)
+
+	// the arrivals are for the peers only
+	req, err := http.NewRequest(http.MethodGet, eu.url+"/api/v1/federation/arrivals", nil)
+	assert.NilError(t, err)
+	req.Header.Set("Authorization", "Bearer wrong")
+	res, err = http.DefaultClient.Do(req)
+	assert.NilError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
+
+func TestFederationLateArrival(t *testing.T) {
+	a, b := fakenode.New(), fakenode.New()
+	defer a.Close()
+	defer b.Close()
+
+	settle := 200 * time.Millisecond
+	eu, us := startFederation(t, a, b, settle)
+
+	// the block reaches us only after it settled
+	chain := fakenode.NewChain(100, time.Now(), 12*time.Second)
+	header := chain.Next()
+	play(t, a, fakenode.WaitForSubscribers(1), fakenode.AnnounceHeader(header))
+	for _, m := range []*monitor{eu, us} {
+		m.waitFor(regexp.MustCompile(
+			`^node_monitor_federation_blocks_first_seen_total\{.*node_monitor_region="eu".*\} 1$`,
+		))
+	}
+	play(t, b, fakenode.WaitForSubscribers(1), fakenode.AnnounceHeader(header))
+	us.waitFor(series("highest_block", "g", "b", "101"))
+
+	// the late arrival does not settle on its own as the one us saw first
+	time.Sleep(3 * settle)
+	lateFirstSeen := regexp.MustCompile(`node_monitor_federation_blocks_first_seen_total\{.*node_monitor_region="us"`)
+	for _, m := range []*monitor{eu, us} {
+		assert.Assert(t, !lateFirstSeen.MatchString(m.scrape()))
+	}
+}
@@ -2,15 +2,22 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/flashbots/node-monitor/logutils"
+	"github.com/flashbots/node-monitor/sntp"
 	"github.com/flashbots/node-monitor/state"
+	"github.com/flashbots/node-monitor/subscriber"
+	"github.com/flashbots/node-monitor/tracing"
 	"github.com/flashbots/node-monitor/utils"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -18,9 +25,20 @@ const (
 	defaultTargetGroup   = "__default"
 	groupVirtualEndpoint = "__group"
 
-	keyTargetName  = "node_monitor_target_name"
-	keyTargetGroup = "node_monitor_target_group"
-	keyTargetID    = "node_monitor_target_id"
+	groupVirtualEndpointKindPrefix = "__group_"
+
+	keyTargetName     = "node_monitor_target_name"
+	keyTargetGroup    = "node_monitor_target_group"
+	keyTargetID       = "node_monitor_target_id"
+	keyQuantile       = "node_monitor_quantile"
+	keyWindow         = "node_monitor_window"
+	keyEndpointKind   = "endpoint_kind"
+	keyDelayReference = "node_monitor_delay_reference"
+	keyFailureReason  = "node_monitor_failure_reason"
+	keyRPCMethod      = "node_monitor_rpc_method"
+	keyProxyTransport = "node_monitor_proxy_transport"
+	keyNTPServer      = "node_monitor_ntp_server"
+	keyRegion         = "node_monitor_region"
 )
 
 func (s *Server) handleEventEthNewHeader(
@@ -29,17 +47,61 @@ func (s *Server) handleEventEthNewHeader(
 	ts time.Time,
 	header *ethtypes.Header,
 ) {
-	l := logutils.LoggerFromContext(ctx)
-
 	block := header.Number
 	blockStr := block.String()
 
+	ctx, span := tracing.Tracer().Start(ctx, "server.handleEventEthNewHeader",
+		trace.WithTimestamp(ts),
+		trace.WithAttributes(
+			attribute.String("block", blockStr),
+			attribute.String("block_hash", header.Hash().String()),
+			attribute.String("endpoint_group", gname),
+			attribute.String("endpoint_name", ename),
+		),
+	)
+	defer span.End()
+
+	l := logutils.LoggerWithSpan(logutils.LoggerFromContext(ctx), span)
+	ctx = logutils.ContextWithLogger(ctx, l)
+
 	g := s.state.ExecutionGroup(gname)
 	e := g.Endpoint(ename)
 
-	e.RegisterBlock(block, ts)
-	latency := g.RegisterBlockAndGetLatency(block, ts)
+	if s.cfg.Eth.ValidateBlocks {
+		go s.fetchAndValidateBlock(ctx, gname, ename, ts, header)
+	}
+
+	kind := e.Kind()
+
+	if skipped := e.RegisterBlock(block, ts); skipped > 0 {
+		l.Info("Execution endpoint skipped some blocks",
+			zap.String("block", blockStr),
+			zap.Uint64("skipped", skipped),
+			zap.String("endpoint_group", gname),
+			zap.String("endpoint_name", ename),
+		)
+		if s.cfg.Eth.BackfillSkippedBlocks {
+			go s.backfillSkippedBlocks(ctx, gname, ename, block, skipped)
+		}
+	}
+	if block.IsUint64() {
+		if skew, turnedNegative := e.RecordHeaderTime(block.Uint64(), ts, header.Time); turnedNegative {
+			l.Warn("Blocks arrive before their header timestamps; the monitor's clock is likely behind",
+				zap.String("block", blockStr),
+				zap.Duration("header_time_skew", skew),
+				zap.String("endpoint_group", gname),
+				zap.String("endpoint_name", ename),
+			)
+		}
+	}
+	latency := g.RegisterBlockAndGetLatency(ename, block, ts)
 	latency_s := latency.Seconds()
+	span.SetAttributes(attribute.Float64("latency_s", latency_s))
+	groupHead := g.HighestBlock().Uint64()
+	findings := s.publishHeaderEvents(ctx, gname, ename, kind, ts, header, latency, groupHead)
+	if s.heads.update(ctx, gname, header, groupHead, findings.reorg > 0) && s.cfg.Eth.FetchTransactionCount {
+		go s.fetchTransactionCount(ctx, gname, ename, header)
+	}
 
 	switch latency {
 	case time.Duration(0):
@@ -67,34 +129,215 @@ func (s *Server) handleEventEthNewHeader(
 		return
 	}
 
-	attrs := []attribute.KeyValue{
-		{Key: keyTargetName, Value: attribute.StringValue(ename)},
-		{Key: keyTargetGroup, Value: attribute.StringValue(normalisedGroup(gname))},
-		{Key: keyTargetID, Value: attribute.StringValue(utils.MakeELEndpointID(gname, ename))},
-	}
 	s.metrics.newBlockLatency.Record(ctx,
 		latency_s,
+		metric.WithAttributes(s.endpointAttributes(gname, ename, kind)...),
+	)
+	e.RecordLatency(ts, latency)
+	if firstSeen, ok := g.FirstSeenByKind(block, kind); ok && firstSeen.Equal(ts) {
+		// the fastest endpoint of its kind
+		s.metrics.newBlockLatency.Record(ctx,
+			latency_s,
+			metric.WithAttributes(groupKindAttributes(gname, kind)...),
+		)
+	}
+
+	s.recordBlockProductionDelay(ctx, s.endpointAttributes(gname, ename, kind), ts, header)
+	if latency == 0 {
+		// the first arrival of the block is the group's production delay
+		s.recordBlockProductionDelay(ctx, groupAttributes(gname), ts, header)
+		if s.federation != nil {
+			s.publishFederationArrival(gname, ename, ts, header)
+		}
+	}
+}
+
+func (s *Server) handleEventEthRPCProbe(
+	ctx context.Context,
+	gname, ename, method string,
+	duration time.Duration,
+	err error,
+) {
+	attrs := append(s.endpointAttributes(gname, ename, s.endpointKind(gname, ename)),
+		attribute.String(keyRPCMethod, method),
+	)
+
+	if err != nil {
+		l := logutils.LoggerFromContext(ctx)
+		l.Debug("Synthetic rpc probe failed",
+			zap.String("endpoint_group", gname),
+			zap.String("endpoint_name", ename),
+			zap.String("method", method),
+			zap.Error(err),
+		)
+		s.metrics.rpcErrors.Add(ctx, 1, metric.WithAttributes(attrs...))
+		return
+	}
+
+	s.metrics.rpcLatency.Record(ctx,
+		duration.Seconds(),
 		metric.WithAttributes(attrs...),
 	)
 }
 
+func (s *Server) handleEventEngineProbe(
+	_ context.Context,
+	gname, ename string,
+	ts time.Time,
+	result *subscriber.EngineProbeResult,
+) {
+	status := state.EngineStatus{
+		Reachable:     result.Reachable,
+		Authenticated: result.Authenticated,
+		Capable:       result.Capable,
+		Syncing:       result.Syncing,
+
+		Capabilities: result.Capabilities,
+		Latency:      result.Duration,
+		Timestamp:    ts,
+	}
+	if result.Err != nil {
+		status.Error = result.Err.Error()
+	}
+
+	s.state.ExecutionGroup(gname).Endpoint(ename).RegisterEngineStatus(status)
+}
+
+func (s *Server) handleEventNTPProbe(
+	ctx context.Context,
+	server string,
+	ts time.Time,
+	res *sntp.Response,
+	err error,
+) {
+	offset := state.ClockOffset{
+		Timestamp: ts,
+	}
+	if err != nil {
+		offset.Error = err.Error()
+		s.state.RegisterClockOffset(server, offset)
+		return
+	}
+	offset.Offset = res.Offset
+	offset.RTT = res.RTT
+	offset.Stratum = res.Stratum
+	s.state.RegisterClockOffset(server, offset)
+
+	if max := s.cfg.Clock.MaxOffset; max > 0 && (res.Offset > max || res.Offset < -max) {
+		l := logutils.LoggerFromContext(ctx)
+		l.Warn("Local clock is off, check the time synchronisation",
+			zap.Duration("offset", res.Offset),
+			zap.Duration("max_offset", max),
+			zap.String("ntp_server", server),
+		)
+	}
+}
+
 func (s *Server) handleHealthcheck(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	l := logutils.LoggerFromRequest(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.status()); err != nil {
+		l.Error("Failed to encode status response",
+			zap.Error(err),
+		)
+	}
+}
+
+func (s *Server) handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	l := logutils.LoggerFromRequest(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.leaderboard(r.URL.Query().Get("group"))); err != nil {
+		l.Error("Failed to encode leaderboard response",
+			zap.Error(err),
+		)
+	}
+}
+
+func (s *Server) handleBest(w http.ResponseWriter, r *http.Request) {
+	l := logutils.LoggerFromRequest(r)
+
+	gname := r.PathValue("group")
+	if gname == defaultTargetGroup {
+		gname = ""
+	}
+	g := s.state.ExecutionGroup(gname)
+	if g == nil {
+		http.Error(w, "unknown group: "+r.PathValue("group"), http.StatusNotFound)
+		return
+	}
+
+	best := s.best(gname, g, s.now())
+
+	w.Header().Set("Content-Type", "application/json")
+	if best.Best == nil {
+		// let the load balancers know there's nothing to route to
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(best); err != nil {
+		l.Error("Failed to encode best endpoint response",
+			zap.Error(err),
+		)
+	}
+}
+
 func (s *Server) handleEventPrometheusObserve(_ context.Context, o metric.Observer) error {
+	now := s.now()
+
+	// local clock's offset as per the ntp servers
+	s.state.IterateClockOffsetsRO(func(server string, offset state.ClockOffset) {
+		if offset.Error != "" {
+			return
+		}
+		o.ObserveFloat64(s.metrics.clockOffset, offset.Offset.Seconds(), metric.WithAttributes(
+			attribute.String(keyNTPServer, server),
+		))
+	})
+
+	// federation peers' connectivity
+	if s.federation != nil {
+		for region, peer := range s.federation.peers {
+			up := int64(0)
+			if peer.connected.Load() {
+				up = 1
+			}
+			o.ObserveInt64(s.metrics.federationPeerUp, up, metric.WithAttributes(
+				attribute.String(keyRegion, region),
+			))
+		}
+	}
+
+	// endpoints' engine api status (regardless of whether the groups progressed,
+	// so that e.g. the wrong jwt secret shows up before the node syncs)
+	s.state.IterateELGroupsRO(func(gname string, g *state.ELGroup) {
+		g.IterateEndpointsRO(func(ename string, e *state.ELEndpoint) {
+			engine, ok := e.EngineStatus()
+			if !ok {
+				return
+			}
+			attrs := s.endpointAttributes(gname, ename, e.Kind())
+
+			o.ObserveInt64(s.metrics.engineAPIUp, bool2int64(engine.Reachable && engine.Authenticated), metric.WithAttributes(attrs...))
+			o.ObserveInt64(s.metrics.engineAPICapable, bool2int64(engine.Capable), metric.WithAttributes(attrs...))
+			o.ObserveInt64(s.metrics.engineAPISyncing, bool2int64(engine.Syncing), metric.WithAttributes(attrs...))
+			o.ObserveFloat64(s.metrics.engineAPILatency, engine.Latency.Seconds(), metric.WithAttributes(attrs...))
+		})
+	})
+
 	s.state.IterateELGroupsRO(func(gname string, g *state.ELGroup) {
 		// don't report groups that did't progress yet
 		if g.HighestBlock().Sign() == 0 {
 			return
 		}
 
-		attrs := []attribute.KeyValue{
-			{Key: keyTargetName, Value: attribute.StringValue(groupVirtualEndpoint)},
-			{Key: keyTargetGroup, Value: attribute.StringValue(normalisedGroup(gname))},
-		}
+		attrs := groupAttributes(gname)
 
-		blockGroup, tsBlockGroup := g.TimeSinceHighestBlock()
+		blockGroup, tsBlockGroup := g.TimeSinceHighestBlock(now)
 
 		// group's highest block
 		o.ObserveInt64(s.metrics.highestBlock, blockGroup, metric.WithAttributes(attrs...))
@@ -102,19 +345,62 @@ func (s *Server) handleEventPrometheusObserve(_ context.Context, o metric.Observ
 		// group's time since last block
 		o.ObserveFloat64(s.metrics.timeSinceLastBlock, tsBlockGroup.Seconds(), metric.WithAttributes(attrs...))
 
+		// group's internal endpoints lateness compared to the external ones
+		if _, delta, ok := g.InternalExternalDelta(); ok {
+			o.ObserveFloat64(s.metrics.internalExternalLatencyDelta, delta.Seconds(), metric.WithAttributes(attrs...))
+		}
+
+		// group's healthy endpoints and the best one of them
+		var best *apiBest
+		if s.bestPolicy != nil {
+			best = s.best(gname, g, now)
+			o.ObserveInt64(s.metrics.healthyEndpoints, int64(best.Healthy), metric.WithAttributes(attrs...))
+		}
+
 		g.IterateEndpointsRO(func(ename string, e *state.ELEndpoint) {
+			attrs := s.endpointAttributes(gname, ename, e.Kind())
+
+			// whether the endpoint is the group's best one
+			if best != nil {
+				isBest := best.Best != nil && best.Best.Endpoint == ename
+				o.ObserveInt64(s.metrics.bestEndpoint, bool2int64(isBest), metric.WithAttributes(attrs...))
+			}
+
+			// monitor's clock sanity check (as seen via the endpoint)
+			if skew, _, ok := e.HeaderTimeSkew(); ok {
+				o.ObserveFloat64(s.metrics.headerTimeSkew, skew.Seconds(), metric.WithAttributes(attrs...))
+			}
+
+			// endpoint's skipped blocks
+			o.ObserveInt64(s.metrics.blocksSkipped, int64(e.SkippedBlocks()), metric.WithAttributes(attrs...))
+
+			// endpoint's leaderboard counters
+			totals := e.LeaderboardTotals()
+			o.ObserveInt64(s.metrics.blocksFirstSeen, int64(totals.FirstSeen), metric.WithAttributes(attrs...))
+			o.ObserveInt64(s.metrics.blocksMissed, int64(totals.Missed), metric.WithAttributes(attrs...))
+			o.ObserveInt64(s.metrics.blocksSeenWithin, int64(totals.Within), metric.WithAttributes(attrs...))
+
+			// endpoint's latency quantiles over sliding windows
+			for _, lq := range e.LatencyQuantiles(now, s.cfg.Stats.Quantiles...) {
+				for idx, q := range lq.Quantiles {
+					if math.IsNaN(q) {
+						continue
+					}
+					o.ObserveFloat64(s.metrics.newBlockLatencyQuantile, q, metric.WithAttributes(
+						append(s.endpointAttributes(gname, ename, e.Kind()),
+							attribute.String(keyWindow, utils.FormatDuration(lq.Window)),
+							attribute.String(keyQuantile, formatQuantile(s.cfg.Stats.Quantiles[idx])),
+						)...,
+					))
+				}
+			}
+
 			// don't report endpoints that did't progress yet
 			if e.HighestBlock().Sign() == 0 {
 				return
 			}
 
-			attrs := []attribute.KeyValue{
-				{Key: keyTargetName, Value: attribute.StringValue(ename)},
-				{Key: keyTargetGroup, Value: attribute.StringValue(normalisedGroup(gname))},
-				{Key: keyTargetID, Value: attribute.StringValue(utils.MakeELEndpointID(gname, ename))},
-			}
-
-			blockEndpoint, tsBlockEndpoint := e.TimeSinceHighestBlock()
+			blockEndpoint, tsBlockEndpoint := e.TimeSinceHighestBlock(now)
 
 			// endpoint's highest block
 			o.ObserveInt64(s.metrics.highestBlock, blockEndpoint, metric.WithAttributes(attrs...))
@@ -134,6 +420,52 @@ func (s *Server) handleEventPrometheusObserve(_ context.Context, o metric.Observ
 	return nil
 }
 
+func groupAttributes(gname string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		{Key: keyTargetName, Value: attribute.StringValue(groupVirtualEndpoint)},
+		{Key: keyTargetGroup, Value: attribute.StringValue(normalisedGroup(gname))},
+	}
+}
+
+func groupKindAttributes(gname string, kind state.EndpointKind) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		{Key: keyTargetName, Value: attribute.StringValue(groupVirtualEndpointKindPrefix + string(kind))},
+		{Key: keyTargetGroup, Value: attribute.StringValue(normalisedGroup(gname))},
+		{Key: keyEndpointKind, Value: attribute.StringValue(string(kind))},
+	}
+}
+
+// endpointAttributes are the endpoint's identity along with its allowlisted
+// labels.
+func (s *Server) endpointAttributes(gname, ename string, kind state.EndpointKind) []attribute.KeyValue {
+	id := utils.MakeELEndpointID(gname, ename)
+	labels := s.labels[id]
+
+	attrs := make([]attribute.KeyValue, 0, 4+len(labels))
+	attrs = append(attrs,
+		attribute.KeyValue{Key: keyTargetName, Value: attribute.StringValue(ename)},
+		attribute.KeyValue{Key: keyTargetGroup, Value: attribute.StringValue(normalisedGroup(gname))},
+		attribute.KeyValue{Key: keyTargetID, Value: attribute.StringValue(id)},
+		attribute.KeyValue{Key: keyEndpointKind, Value: attribute.StringValue(string(kind))},
+	)
+	return append(attrs, labels...)
+}
+
+func (s *Server) endpointKind(gname, ename string) state.EndpointKind {
+	return s.state.ExecutionGroup(gname).Endpoint(ename).Kind()
+}
+
+func formatQuantile(q float64) string {
+	return strconv.FormatFloat(q, 'f', -1, 64)
+}
+
+func bool2int64(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 func normalisedGroup(gname string) string {
 	if gname == "" {
 		return defaultTargetGroup
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/flashbots/node-monitor/logutils"
+	"github.com/flashbots/node-monitor/utils"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// headContent is the group's head block whose content was recorded.
+type headContent struct {
+	number uint64
+	hash   common.Hash
+
+	transactions bool // whether the count of transactions was recorded
+}
+
+// headContents records the content of the groups' heads once per block
+// (regardless of how many endpoints report it), so that none of the blocks
+// is lost in between the scrapes.
+type headContents struct {
+	heads   map[string]*headContent // group -> head
+	metrics *metrics
+
+	mx sync.Mutex
+}
+
+func newHeadContents(m *metrics) *headContents {
+	return &headContents{
+		heads:   make(map[string]*headContent),
+		metrics: m,
+	}
+}
+
+// update records the content of the header if it is the one that moved the
+// group's head (or the one that replaced the head when the endpoint reorged),
+// and tells whether it did.
+func (h *headContents) update(
+	ctx context.Context,
+	gname string,
+	header *ethtypes.Header,
+	groupHead uint64,
+	reorg bool,
+) bool {
+	if !header.Number.IsUint64() || header.Number.Uint64() != groupHead {
+		return false
+	}
+	hash := header.Hash()
+
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	if prev, exists := h.heads[gname]; exists {
+		switch {
+		case prev.number < groupHead:
+			// the head moved on
+		case reorg && prev.hash != hash:
+			// the head was reorged out
+		default:
+			return false
+		}
+	}
+	h.heads[gname] = &headContent{
+		number: groupHead,
+		hash:   hash,
+	}
+
+	attrs := metric.WithAttributes(groupAttributes(gname)...)
+	if header.BaseFee != nil {
+		baseFee, _ := header.BaseFee.Float64()
+		h.metrics.headBaseFee.Record(ctx, baseFee, attrs)
+	}
+	if header.GasLimit > 0 {
+		h.metrics.headGasUsedRatio.Record(ctx, float64(header.GasUsed)/float64(header.GasLimit), attrs)
+	}
+	if header.BlobGasUsed != nil {
+		h.metrics.headBlobGasUsed.Record(ctx, int64(*header.BlobGasUsed), attrs)
+	}
+	if header.ExcessBlobGas != nil {
+		h.metrics.headExcessBlobGas.Record(ctx, int64(*header.ExcessBlobGas), attrs)
+	}
+
+	return true
+}
+
+// setTransactions records the count of the head's transactions, unless the
+// head moved on already.
+func (h *headContents) setTransactions(ctx context.Context, gname string, hash common.Hash, count uint64) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	head, exists := h.heads[gname]
+	if !exists || head.hash != hash || head.transactions {
+		return
+	}
+	head.transactions = true
+
+	h.metrics.headTransactions.Record(ctx, int64(count), metric.WithAttributes(
+		groupAttributes(gname)...,
+	))
+}
+
+// fetchTransactionCount asks the endpoint that moved the group's head for the
+// count of the block's transactions (the header doesn't tell it).
+func (s *Server) fetchTransactionCount(
+	ctx context.Context,
+	gname, ename string,
+	header *ethtypes.Header,
+) {
+	l := logutils.LoggerFromContext(ctx)
+
+	sub, exists := s.subs[utils.MakeELEndpointID(gname, ename)]
+	if !exists {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Eth.BlockFetchTimeout)
+	defer cancel()
+
+	var count *hexutil.Uint64
+	hash := header.Hash()
+	if err := sub.CallContext(ctx, &count, "eth_getBlockTransactionCountByHash", hash); err != nil || count == nil {
+		l.Debug("Failed to fetch the transaction count of the group's head",
+			zap.String("block", header.Number.String()),
+			zap.String("block_hash", hash.String()),
+			zap.String("endpoint_group", gname),
+			zap.String("endpoint_name", ename),
+			zap.Error(err),
+		)
+		return
+	}
+
+	s.heads.setTransactions(ctx, gname, hash, uint64(*count))
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/flashbots/node-monitor/config"
+	"github.com/flashbots/node-monitor/state"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	keyLabelPrefix = "node_monitor_label_"
+)
+
+var (
+	ErrExecutionEndpointDuplicateLabel  = errors.New("duplicate execution endpoint label")
+	ErrExecutionEndpointUnexpectedLabel = errors.New("unexpected execution endpoint label (must look like `id=key=value`, with the key made of letters, digits and underscores)")
+
+	labelKeyRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+)
+
+// newEndpointLabels maps the endpoint ids onto the attributes of their
+// allowlisted labels.  Every endpoint carries all of the keys that any of them
+// has a label for (with empty values where not set), so that the series of
+// the same metric always come with the same attributes.
+func newEndpointLabels(
+	l *zap.Logger,
+	cfg *config.Config,
+	kinds map[string]state.EndpointKind,
+) (map[string][]attribute.KeyValue, error) {
+	allowed := make(map[string]struct{}, len(cfg.Eth.ExecutionEndpointLabelKeys))
+	for _, key := range cfg.Eth.ExecutionEndpointLabelKeys {
+		if !labelKeyRegexp.MatchString(key) {
+			return nil, fmt.Errorf("%w: %s",
+				ErrExecutionEndpointUnexpectedLabel, key,
+			)
+		}
+		allowed[key] = struct{}{}
+	}
+
+	labels := make(map[string]map[string]string, len(kinds))
+	used := make(map[string]struct{})
+	for _, label := range cfg.Eth.ExecutionEndpointLabels {
+		id, kv, found := strings.Cut(label, "=")
+		if !found {
+			return nil, fmt.Errorf("%w: %s",
+				ErrExecutionEndpointUnexpectedLabel, label,
+			)
+		}
+		key, value, found := strings.Cut(kv, "=")
+		id, key, value = strings.TrimSpace(id), strings.TrimSpace(key), strings.TrimSpace(value)
+		if !found || !labelKeyRegexp.MatchString(key) {
+			return nil, fmt.Errorf("%w: %s",
+				ErrExecutionEndpointUnexpectedLabel, label,
+			)
+		}
+		if _, exists := kinds[id]; !exists {
+			return nil, fmt.Errorf("%w: %s",
+				ErrExecutionEndpointUnknownId, id,
+			)
+		}
+		if _, exists := labels[id][key]; exists {
+			return nil, fmt.Errorf("%w: %s: %s",
+				ErrExecutionEndpointDuplicateLabel, id, key,
+			)
+		}
+		if _, exists := allowed[key]; !exists {
+			l.Warn("Execution endpoint label is not allowlisted, it will not be exported",
+				zap.String("endpoint_id", id),
+				zap.String("label", key),
+			)
+			continue
+		}
+		if labels[id] == nil {
+			labels[id] = make(map[string]string)
+		}
+		labels[id][key] = value
+		used[key] = struct{}{}
+	}
+
+	if len(used) == 0 {
+		return map[string][]attribute.KeyValue{}, nil
+	}
+
+	keys := make([]string, 0, len(used))
+	for key := range used {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	res := make(map[string][]attribute.KeyValue, len(kinds))
+	for id := range kinds {
+		attrs := make([]attribute.KeyValue, 0, len(keys))
+		for _, key := range keys {
+			attrs = append(attrs, attribute.String(keyLabelPrefix+key, labels[id][key]))
+		}
+		res[id] = attrs
+	}
+
+	return res, nil
+}
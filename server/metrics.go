@@ -10,18 +10,188 @@ import (
 )
 
 const (
-	metricHighestBlock       = "highest_block"
-	metricHighestBlockLag    = "highest_block_lag"
-	metricNewBlockLatency    = "new_block_latency"
-	metricTimeSinceLastBlock = "time_since_last_block"
+	metricBestEndpoint              = "best_endpoint"
+	metricBlockFetchLatency         = "block_fetch_latency"
+	metricBlockProductionDelay      = "block_production_delay"
+	metricBlockValidationFailures   = "block_validation_failures"
+	metricBlocksFirstSeen           = "blocks_first_seen"
+	metricBlocksMissed              = "blocks_missed"
+	metricBlocksSeenWithin          = "blocks_seen_within"
+	metricBlocksSkipped             = "blocks_skipped"
+	metricClockOffset               = "clock_offset"
+	metricEngineAPICapable          = "engine_api_capable"
+	metricEngineAPILatency          = "engine_api_latency"
+	metricEngineAPISyncing          = "engine_api_syncing"
+	metricEngineAPIUp               = "engine_api_up"
+	metricFederationBlocksFirstSeen = "federation_blocks_first_seen"
+	metricFederationLatency         = "federation_latency"
+	metricFederationPeerUp          = "federation_peer_up"
+	metricHeadBaseFee               = "head_base_fee"
+	metricHeadBlobGasUsed           = "head_blob_gas_used"
+	metricHeadExcessBlobGas         = "head_excess_blob_gas"
+	metricHeadGasUsedRatio          = "head_gas_used_ratio"
+	metricHeadTransactions          = "head_transactions"
+	metricHeaderTimeSkew            = "header_time_skew"
+	metricHealthyEndpoints          = "healthy_endpoints"
+	metricHighestBlock              = "highest_block"
+	metricHighestBlockLag           = "highest_block_lag"
+	metricInternalExternalDelta     = "internal_external_latency_delta"
+	metricNewBlockLatency           = "new_block_latency"
+	metricNewBlockLatencyQuantile   = "new_block_latency_quantile"
+	metricProxyFailovers            = "proxy_failovers"
+	metricProxyRequests             = "proxy_requests"
+	metricRPCErrors                 = "rpc_errors"
+	metricRPCLatency                = "rpc_latency"
+	metricTimeSinceLastBlock        = "time_since_last_block"
 )
 
 var (
 	metricDescriptions = map[string]string{
-		metricHighestBlock:       "The highest known block",
-		metricHighestBlockLag:    "The distance between endpoint's highest known block and its group's one",
-		metricNewBlockLatency:    "Statistics on how late a node receives blocks compared to the earliest observed ones",
-		metricTimeSinceLastBlock: "Time passed since last block was received",
+		metricBestEndpoint:              "Whether the endpoint is currently the best one in its group (1) or not (0)",
+		metricBlockFetchLatency:         "Statistics on how long it takes for the block body to become available after its header was received",
+		metricBlockProductionDelay:      "Statistics on how late a node receives blocks compared to their timestamps (or the starts of their slots)",
+		metricBlockValidationFailures:   "Count of the blocks that could not be fetched or failed the consistency validation",
+		metricBlocksFirstSeen:           "Count of the blocks that the endpoint was the first in its group to see",
+		metricBlocksMissed:              "Count of the blocks that the endpoint did not report until its group's head moved far enough ahead",
+		metricBlocksSeenWithin:          "Count of the blocks that the endpoint saw within the leaderboard threshold after the first one in its group",
+		metricBlocksSkipped:             "Count of the blocks that the endpoint jumped over without reporting their headers",
+		metricClockOffset:               "How much the local clock must be adjusted by to match the ntp server's one (positive when the local clock is behind)",
+		metricEngineAPICapable:          "Whether the engine api supports all of the required capabilities (1) or not (0)",
+		metricEngineAPILatency:          "Time it took to complete the latest engine api probe",
+		metricEngineAPISyncing:          "Whether the execution client reports to be syncing (1) or not (0)",
+		metricEngineAPIUp:               "Whether the engine api is reachable and accepts our jwt (1) or not (0)",
+		metricFederationBlocksFirstSeen: "Count of the blocks that the region was the first in the federation to see",
+		metricFederationLatency:         "Statistics on how late the region's monitor sees the blocks compared to the earliest region in the federation (corrected by the clock offsets)",
+		metricFederationPeerUp:          "Whether the arrivals stream of the federation peer is connected (1) or not (0)",
+		metricHeadBaseFee:               "Base fees (in wei) of the group's head blocks",
+		metricHeadBlobGasUsed:           "Blob gas used by the group's head blocks",
+		metricHeadExcessBlobGas:         "Excess blob gas of the group's head blocks",
+		metricHeadGasUsedRatio:          "Shares of the gas limit used by the group's head blocks",
+		metricHeadTransactions:          "Counts of the transactions in the group's head blocks",
+		metricHeaderTimeSkew:            "Smallest difference between the receive times of the recent blocks and their header timestamps, i.e. the fastest propagation to the endpoint plus the monitor's clock error (negative when the monitor's clock is behind)",
+		metricHealthyEndpoints:          "Count of the group's endpoints that are healthy enough to be picked as the best one",
+		metricHighestBlock:              "The highest known block",
+		metricHighestBlockLag:           "The distance between endpoint's highest known block and its group's one",
+		metricInternalExternalDelta:     "How late (positive) or early (negative) the fastest internal endpoint received the latest block compared to the fastest external one",
+		metricNewBlockLatency:           "Statistics on how late a node receives blocks compared to the earliest observed ones",
+		metricNewBlockLatencyQuantile:   "Estimated quantiles of the new block latency over sliding time windows",
+		metricProxyFailovers:            "Count of the proxied requests that failed over from the endpoint to the next healthy one",
+		metricProxyRequests:             "Count of the json-rpc requests (and websocket connections) that the proxy forwarded to the endpoint",
+		metricRPCErrors:                 "Count of the failed synthetic rpc probes",
+		metricRPCLatency:                "Statistics on how long it takes for a node to respond to synthetic rpc probes",
+		metricTimeSinceLastBlock:        "Time passed since last block was received",
+	}
+)
+
+var (
+	baseFeeBuckets = []float64{
+		1e8, // 0.1 gwei
+		2.5e8,
+		5e8,
+		1e9, // 1 gwei
+		2.5e9,
+		5e9,
+		1e10, // 10 gwei
+		2.5e10,
+		5e10,
+		1e11, // 100 gwei
+		2.5e11,
+		5e11,
+		1e12, // 1000 gwei
+	}
+
+	blobGasBuckets = []float64{
+		0,
+		131072,  // 1 blob
+		262144,  // 2 blobs
+		393216,  // 3 blobs
+		524288,  // 4 blobs
+		655360,  // 5 blobs
+		786432,  // 6 blobs
+		917504,  // 7 blobs
+		1048576, // 8 blobs
+		1179648, // 9 blobs
+	}
+
+	excessBlobGasBuckets = []float64{
+		0,
+		131072,   // 1 blob
+		262144,   // 2 blobs
+		524288,   // 4 blobs
+		1048576,  // 8 blobs
+		2097152,  // 16 blobs
+		4194304,  // 32 blobs
+		8388608,  // 64 blobs
+		16777216, // 128 blobs
+		33554432, // 256 blobs
+	}
+
+	gasUsedRatioBuckets = []float64{
+		0.1,
+		0.2,
+		0.3,
+		0.4,
+		0.5, // target
+		0.6,
+		0.7,
+		0.8,
+		0.9,
+		1,
+	}
+
+	latencyBuckets = []float64{
+		0.01171875, // 1/1024
+		0.0234375,  // 1/512
+		0.046875,   // 1/256
+		0.09375,    // 1/128
+		0.1875,     // 1/64
+		0.375,      // 1/32
+		0.75,       // 1/16
+		1.5,        // 1/8
+		3,          // 1/4
+		6,          // 1/2
+		12,         // 1      slot
+		24,         // 2x
+		48,         // 4x
+		96,         // 8x
+		192,        // 16x
+		384,        // 32x
+		768,        // 64x
+		1536,       // 128x
+		3072,       // 256x
+		6144,       // 512x
+		12288,      // 1024x
+	}
+
+	rpcLatencyBuckets = []float64{
+		0.0005,
+		0.001,
+		0.0025,
+		0.005,
+		0.01,
+		0.025,
+		0.05,
+		0.1,
+		0.25,
+		0.5,
+		1,
+		2.5,
+		5,
+		10,
+	}
+
+	transactionsBuckets = []float64{
+		0,
+		25,
+		50,
+		100,
+		150,
+		200,
+		300,
+		400,
+		600,
+		800,
+		1000,
 	}
 )
 
@@ -30,13 +200,309 @@ var (
 )
 
 type metrics struct {
-	highestBlock       otelapi.Int64ObservableGauge
-	highestBlockLag    otelapi.Int64ObservableGauge
-	newBlockLatency    otelapi.Float64Histogram
-	timeSinceLastBlock otelapi.Float64Observable
+	bestEndpoint                 otelapi.Int64ObservableGauge
+	blockFetchLatency            otelapi.Float64Histogram
+	blockProductionDelay         otelapi.Float64Histogram
+	blockValidationFailures      otelapi.Int64Counter
+	blocksFirstSeen              otelapi.Int64ObservableCounter
+	blocksMissed                 otelapi.Int64ObservableCounter
+	blocksSeenWithin             otelapi.Int64ObservableCounter
+	blocksSkipped                otelapi.Int64ObservableCounter
+	clockOffset                  otelapi.Float64ObservableGauge
+	engineAPICapable             otelapi.Int64ObservableGauge
+	engineAPILatency             otelapi.Float64ObservableGauge
+	engineAPISyncing             otelapi.Int64ObservableGauge
+	engineAPIUp                  otelapi.Int64ObservableGauge
+	federationBlocksFirstSeen    otelapi.Int64Counter
+	federationLatency            otelapi.Float64Histogram
+	federationPeerUp             otelapi.Int64ObservableGauge
+	headBaseFee                  otelapi.Float64Histogram
+	headBlobGasUsed              otelapi.Int64Histogram
+	headExcessBlobGas            otelapi.Int64Histogram
+	headGasUsedRatio             otelapi.Float64Histogram
+	headTransactions             otelapi.Int64Histogram
+	headerTimeSkew               otelapi.Float64ObservableGauge
+	healthyEndpoints             otelapi.Int64ObservableGauge
+	highestBlock                 otelapi.Int64ObservableGauge
+	highestBlockLag              otelapi.Int64ObservableGauge
+	internalExternalLatencyDelta otelapi.Float64ObservableGauge
+	newBlockLatency              otelapi.Float64Histogram
+	newBlockLatencyQuantile      otelapi.Float64ObservableGauge
+	proxyFailovers               otelapi.Int64Counter
+	proxyRequests                otelapi.Int64Counter
+	rpcErrors                    otelapi.Int64Counter
+	rpcLatency                   otelapi.Float64Histogram
+	timeSinceLastBlock           otelapi.Float64Observable
 }
 
 func (m *metrics) setup(meter otelapi.Meter, observe func(ctx context.Context, o metric.Observer) error) error {
+	// best endpoint
+	bestEndpoint, err := meter.Int64ObservableGauge(metricBestEndpoint,
+		otelapi.WithDescription(metricDescriptions[metricBestEndpoint]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricBestEndpoint,
+		)
+	}
+	m.bestEndpoint = bestEndpoint
+
+	// block fetch latency
+	blockFetchLatency, err := meter.Float64Histogram(metricBlockFetchLatency,
+		metric.WithExplicitBucketBoundaries(latencyBuckets...),
+		otelapi.WithDescription(metricDescriptions[metricBlockFetchLatency]),
+		otelapi.WithUnit("s"),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricBlockFetchLatency,
+		)
+	}
+	m.blockFetchLatency = blockFetchLatency
+
+	// block production delay
+	blockProductionDelay, err := meter.Float64Histogram(metricBlockProductionDelay,
+		metric.WithExplicitBucketBoundaries(latencyBuckets...),
+		otelapi.WithDescription(metricDescriptions[metricBlockProductionDelay]),
+		otelapi.WithUnit("s"),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricBlockProductionDelay,
+		)
+	}
+	m.blockProductionDelay = blockProductionDelay
+
+	// block validation failures
+	blockValidationFailures, err := meter.Int64Counter(metricBlockValidationFailures,
+		otelapi.WithDescription(metricDescriptions[metricBlockValidationFailures]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricBlockValidationFailures,
+		)
+	}
+	m.blockValidationFailures = blockValidationFailures
+
+	// blocks first seen
+	blocksFirstSeen, err := meter.Int64ObservableCounter(metricBlocksFirstSeen,
+		otelapi.WithDescription(metricDescriptions[metricBlocksFirstSeen]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricBlocksFirstSeen,
+		)
+	}
+	m.blocksFirstSeen = blocksFirstSeen
+
+	// blocks missed
+	blocksMissed, err := meter.Int64ObservableCounter(metricBlocksMissed,
+		otelapi.WithDescription(metricDescriptions[metricBlocksMissed]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricBlocksMissed,
+		)
+	}
+	m.blocksMissed = blocksMissed
+
+	// blocks seen within threshold
+	blocksSeenWithin, err := meter.Int64ObservableCounter(metricBlocksSeenWithin,
+		otelapi.WithDescription(metricDescriptions[metricBlocksSeenWithin]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricBlocksSeenWithin,
+		)
+	}
+	m.blocksSeenWithin = blocksSeenWithin
+
+	// blocks skipped
+	blocksSkipped, err := meter.Int64ObservableCounter(metricBlocksSkipped,
+		otelapi.WithDescription(metricDescriptions[metricBlocksSkipped]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricBlocksSkipped,
+		)
+	}
+	m.blocksSkipped = blocksSkipped
+
+	// clock offset
+	clockOffset, err := meter.Float64ObservableGauge(metricClockOffset,
+		otelapi.WithDescription(metricDescriptions[metricClockOffset]),
+		otelapi.WithUnit("s"),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricClockOffset,
+		)
+	}
+	m.clockOffset = clockOffset
+
+	// engine api capable
+	engineAPICapable, err := meter.Int64ObservableGauge(metricEngineAPICapable,
+		otelapi.WithDescription(metricDescriptions[metricEngineAPICapable]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricEngineAPICapable,
+		)
+	}
+	m.engineAPICapable = engineAPICapable
+
+	// engine api latency
+	engineAPILatency, err := meter.Float64ObservableGauge(metricEngineAPILatency,
+		otelapi.WithDescription(metricDescriptions[metricEngineAPILatency]),
+		otelapi.WithUnit("s"),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricEngineAPILatency,
+		)
+	}
+	m.engineAPILatency = engineAPILatency
+
+	// engine api syncing
+	engineAPISyncing, err := meter.Int64ObservableGauge(metricEngineAPISyncing,
+		otelapi.WithDescription(metricDescriptions[metricEngineAPISyncing]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricEngineAPISyncing,
+		)
+	}
+	m.engineAPISyncing = engineAPISyncing
+
+	// engine api up
+	engineAPIUp, err := meter.Int64ObservableGauge(metricEngineAPIUp,
+		otelapi.WithDescription(metricDescriptions[metricEngineAPIUp]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricEngineAPIUp,
+		)
+	}
+	m.engineAPIUp = engineAPIUp
+
+	// federation blocks first seen
+	federationBlocksFirstSeen, err := meter.Int64Counter(metricFederationBlocksFirstSeen,
+		otelapi.WithDescription(metricDescriptions[metricFederationBlocksFirstSeen]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricFederationBlocksFirstSeen,
+		)
+	}
+	m.federationBlocksFirstSeen = federationBlocksFirstSeen
+
+	// federation latency
+	federationLatency, err := meter.Float64Histogram(metricFederationLatency,
+		metric.WithExplicitBucketBoundaries(latencyBuckets...),
+		otelapi.WithDescription(metricDescriptions[metricFederationLatency]),
+		otelapi.WithUnit("s"),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricFederationLatency,
+		)
+	}
+	m.federationLatency = federationLatency
+
+	// federation peer up
+	federationPeerUp, err := meter.Int64ObservableGauge(metricFederationPeerUp,
+		otelapi.WithDescription(metricDescriptions[metricFederationPeerUp]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricFederationPeerUp,
+		)
+	}
+	m.federationPeerUp = federationPeerUp
+
+	// head base fee
+	headBaseFee, err := meter.Float64Histogram(metricHeadBaseFee,
+		metric.WithExplicitBucketBoundaries(baseFeeBuckets...),
+		otelapi.WithDescription(metricDescriptions[metricHeadBaseFee]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricHeadBaseFee,
+		)
+	}
+	m.headBaseFee = headBaseFee
+
+	// head blob gas used
+	headBlobGasUsed, err := meter.Int64Histogram(metricHeadBlobGasUsed,
+		metric.WithExplicitBucketBoundaries(blobGasBuckets...),
+		otelapi.WithDescription(metricDescriptions[metricHeadBlobGasUsed]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricHeadBlobGasUsed,
+		)
+	}
+	m.headBlobGasUsed = headBlobGasUsed
+
+	// head excess blob gas
+	headExcessBlobGas, err := meter.Int64Histogram(metricHeadExcessBlobGas,
+		metric.WithExplicitBucketBoundaries(excessBlobGasBuckets...),
+		otelapi.WithDescription(metricDescriptions[metricHeadExcessBlobGas]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricHeadExcessBlobGas,
+		)
+	}
+	m.headExcessBlobGas = headExcessBlobGas
+
+	// head gas used ratio
+	headGasUsedRatio, err := meter.Float64Histogram(metricHeadGasUsedRatio,
+		metric.WithExplicitBucketBoundaries(gasUsedRatioBuckets...),
+		otelapi.WithDescription(metricDescriptions[metricHeadGasUsedRatio]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricHeadGasUsedRatio,
+		)
+	}
+	m.headGasUsedRatio = headGasUsedRatio
+
+	// head transactions
+	headTransactions, err := meter.Int64Histogram(metricHeadTransactions,
+		metric.WithExplicitBucketBoundaries(transactionsBuckets...),
+		otelapi.WithDescription(metricDescriptions[metricHeadTransactions]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricHeadTransactions,
+		)
+	}
+	m.headTransactions = headTransactions
+
+	// header time skew
+	headerTimeSkew, err := meter.Float64ObservableGauge(metricHeaderTimeSkew,
+		otelapi.WithDescription(metricDescriptions[metricHeaderTimeSkew]),
+		otelapi.WithUnit("s"),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricHeaderTimeSkew,
+		)
+	}
+	m.headerTimeSkew = headerTimeSkew
+
+	// healthy endpoints
+	healthyEndpoints, err := meter.Int64ObservableGauge(metricHealthyEndpoints,
+		otelapi.WithDescription(metricDescriptions[metricHealthyEndpoints]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricHealthyEndpoints,
+		)
+	}
+	m.healthyEndpoints = healthyEndpoints
+
 	// highest block
 	highestBlock, err := meter.Int64ObservableGauge(metricHighestBlock,
 		otelapi.WithDescription(metricDescriptions[metricHighestBlock]),
@@ -59,31 +525,21 @@ func (m *metrics) setup(meter otelapi.Meter, observe func(ctx context.Context, o
 	}
 	m.highestBlockLag = highestBlockLag
 
+	// internal vs. external latency delta
+	internalExternalLatencyDelta, err := meter.Float64ObservableGauge(metricInternalExternalDelta,
+		otelapi.WithDescription(metricDescriptions[metricInternalExternalDelta]),
+		otelapi.WithUnit("s"),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricInternalExternalDelta,
+		)
+	}
+	m.internalExternalLatencyDelta = internalExternalLatencyDelta
+
 	// new block latency
 	newBlockLatency, err := meter.Float64Histogram(metricNewBlockLatency,
-		metric.WithExplicitBucketBoundaries(
-			0.01171875, // 1/1024
-			0.0234375,  // 1/512
-			0.046875,   // 1/256
-			0.09375,    // 1/128
-			0.1875,     // 1/64
-			0.375,      // 1/32
-			0.75,       // 1/16
-			1.5,        // 1/8
-			3,          // 1/4
-			6,          // 1/2
-			12,         // 1      slot
-			24,         // 2x
-			48,         // 4x
-			96,         // 8x
-			192,        // 16x
-			384,        // 32x
-			768,        // 64x
-			1536,       // 128x
-			3072,       // 256x
-			6144,       // 512x
-			12288,      // 1024x
-		),
+		metric.WithExplicitBucketBoundaries(latencyBuckets...),
 		otelapi.WithDescription(metricDescriptions[metricNewBlockLatency]),
 		otelapi.WithUnit("s"),
 	)
@@ -94,6 +550,64 @@ func (m *metrics) setup(meter otelapi.Meter, observe func(ctx context.Context, o
 	}
 	m.newBlockLatency = newBlockLatency
 
+	// new block latency quantile
+	newBlockLatencyQuantile, err := meter.Float64ObservableGauge(metricNewBlockLatencyQuantile,
+		otelapi.WithDescription(metricDescriptions[metricNewBlockLatencyQuantile]),
+		otelapi.WithUnit("s"),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricNewBlockLatencyQuantile,
+		)
+	}
+	m.newBlockLatencyQuantile = newBlockLatencyQuantile
+
+	// proxy failovers
+	proxyFailovers, err := meter.Int64Counter(metricProxyFailovers,
+		otelapi.WithDescription(metricDescriptions[metricProxyFailovers]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricProxyFailovers,
+		)
+	}
+	m.proxyFailovers = proxyFailovers
+
+	// proxy requests
+	proxyRequests, err := meter.Int64Counter(metricProxyRequests,
+		otelapi.WithDescription(metricDescriptions[metricProxyRequests]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricProxyRequests,
+		)
+	}
+	m.proxyRequests = proxyRequests
+
+	// rpc errors
+	rpcErrors, err := meter.Int64Counter(metricRPCErrors,
+		otelapi.WithDescription(metricDescriptions[metricRPCErrors]),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricRPCErrors,
+		)
+	}
+	m.rpcErrors = rpcErrors
+
+	// rpc latency
+	rpcLatency, err := meter.Float64Histogram(metricRPCLatency,
+		metric.WithExplicitBucketBoundaries(rpcLatencyBuckets...),
+		otelapi.WithDescription(metricDescriptions[metricRPCLatency]),
+		otelapi.WithUnit("s"),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w: %s",
+			ErrSetupMetricsFailed, err, metricRPCLatency,
+		)
+	}
+	m.rpcLatency = rpcLatency
+
 	// time since last block
 	timeSinceLastBlock, err := meter.Float64ObservableGauge(metricTimeSinceLastBlock,
 		otelapi.WithDescription(metricDescriptions[metricTimeSinceLastBlock]),
@@ -108,8 +622,23 @@ func (m *metrics) setup(meter otelapi.Meter, observe func(ctx context.Context, o
 
 	// observables
 	if _, err := meter.RegisterCallback(observe,
+		m.bestEndpoint,
+		m.blocksFirstSeen,
+		m.blocksMissed,
+		m.blocksSeenWithin,
+		m.blocksSkipped,
+		m.clockOffset,
+		m.engineAPICapable,
+		m.engineAPILatency,
+		m.engineAPISyncing,
+		m.engineAPIUp,
+		m.federationPeerUp,
+		m.headerTimeSkew,
+		m.healthyEndpoints,
 		m.highestBlock,
 		m.highestBlockLag,
+		m.internalExternalLatencyDelta,
+		m.newBlockLatencyQuantile,
 		m.timeSinceLastBlock,
 	); err != nil {
 		return err
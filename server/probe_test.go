@@ -0,0 +1,48 @@
+package server_test
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/flashbots/node-monitor/fakenode"
+	"github.com/flashbots/node-monitor/server"
+	"github.com/flashbots/node-monitor/subscriber"
+	"gotest.tools/assert"
+)
+
+func TestRPCProbe(t *testing.T) {
+	node := fakenode.New()
+	defer node.Close()
+
+	cfg := newTestConfig(t, map[string]*fakenode.Node{"g:a": node})
+	cfg.Probe.Interval = 100 * time.Millisecond
+	cfg.Probe.Methods = []string{subscriber.ProbeMethodBlockNumber, subscriber.ProbeMethodGetBalance}
+	node.Fail(fakenode.MethodGetBalance, errors.New("balance is unavailable"))
+	m := startMonitor(t, cfg)
+
+	// the successful probes are timed, the failed ones are counted
+	m.waitFor(regexp.MustCompile(
+		`^node_monitor_rpc_latency_seconds_count\{.*node_monitor_rpc_method="eth_blockNumber".*node_monitor_target_id="g:a".*\} [1-9][0-9]*$`,
+	))
+	m.waitFor(regexp.MustCompile(
+		`^node_monitor_rpc_errors_total\{.*node_monitor_rpc_method="eth_getBalance".*node_monitor_target_id="g:a".*\} [1-9][0-9]*$`,
+	))
+	metrics := m.scrape()
+	assert.Assert(t, !regexp.MustCompile(`node_monitor_rpc_errors_total\{[^}]*eth_blockNumber`).MatchString(metrics))
+	assert.Assert(t, !regexp.MustCompile(`node_monitor_rpc_latency_seconds_count\{[^}]*eth_getBalance`).MatchString(metrics))
+
+	// once the node recovers, its probes are timed too
+	node.Fail(fakenode.MethodGetBalance, nil)
+	m.waitFor(regexp.MustCompile(
+		`^node_monitor_rpc_latency_seconds_count\{.*node_monitor_rpc_method="eth_getBalance".*node_monitor_target_id="g:a".*\} [1-9][0-9]*$`,
+	))
+
+	// the unsupported methods are rejected upfront
+	cfg = newTestConfig(t, map[string]*fakenode.Node{"g:a": node})
+	cfg.Probe.Interval = 100 * time.Millisecond
+	cfg.Probe.Methods = []string{"eth_syncing"}
+	_, err := server.New(cfg)
+	assert.Assert(t, errors.Is(err, subscriber.ErrProbeMethodUnsupported), err)
+}
@@ -0,0 +1,586 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/flashbots/node-monitor/httplogger"
+	"github.com/flashbots/node-monitor/logutils"
+	"github.com/flashbots/node-monitor/state"
+	"github.com/flashbots/node-monitor/subscriber"
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+const (
+	proxyHealthCheckInterval = time.Second
+	proxyMaxRequestSize      = 16 * 1024 * 1024
+
+	proxyTransportHTTP      = "http"
+	proxyTransportWebsocket = "websocket"
+
+	// headerProxyEndpoint tells which endpoint served the proxied request
+	headerProxyEndpoint = "X-Node-Monitor-Endpoint"
+
+	// json-rpc error codes
+	rpcCodeParseError     = -32700
+	rpcCodeInvalidRequest = -32600
+	rpcCodeMethodNotFound = -32601
+	rpcCodeInvalidParams  = -32602
+	rpcCodeInternalError  = -32603
+	rpcCodeUnavailable    = -32000
+)
+
+var (
+	ErrProxyEndpointUnreachable = errors.New("execution endpoint is unreachable")
+	ErrProxyMethodNotAllowed    = errors.New("method is not allowed by the proxy")
+	ErrProxyNamedParams         = errors.New("named params are not supported")
+	ErrProxyNoHealthyEndpoint   = errors.New("no healthy endpoint in the group")
+	ErrProxyStatefulMethod      = errors.New("method is only available over websocket")
+)
+
+// proxyStatefulMethods are the methods that leave the subscription or the
+// filter behind on the endpoint.  Over http they would be installed on the
+// monitor's own connection (where nothing ever polls or removes them), so
+// these are only proxied over websocket.
+var proxyStatefulMethods = []string{
+	"*_subscribe",
+	"*_unsubscribe",
+	"eth_newBlockFilter",
+	"eth_newFilter",
+	"eth_newPendingTransactionFilter",
+}
+
+// rpcProxy forwards the json-rpc requests to the best endpoint of the group
+// (as per the best endpoint policy) and fails over to the next healthy one
+// when the endpoint can not be reached.  The requests that might have been
+// sent out already (e.g. the ones that timed out) are never retried, as the
+// methods are not necessarily idempotent.  Only the allowlisted methods are
+// forwarded.
+//
+// The http requests are sent over the monitor's own connections to the
+// endpoints.  Each websocket client gets the dedicated connection to the
+// endpoint (so that its subscriptions work), which is closed once the endpoint
+// becomes unhealthy (for the client to reconnect to the next best one).
+type rpcProxy struct {
+	server  *Server
+	closing chan struct{} // closed when the proxy shuts down
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcErrorObject `json:"error,omitempty"`
+}
+
+type rpcErrorObject struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// newProxyServer returns the http server of the proxy.
+func (s *Server) newProxyServer(l *zap.Logger) *http.Server {
+	proxy := &rpcProxy{
+		server:  s,
+		closing: make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/{$}", proxy.handle)
+	mux.HandleFunc("/{group}", proxy.handle)
+
+	srv := &http.Server{
+		Addr:              s.cfg.Proxy.ListenAddress,
+		Handler:           httplogger.Middleware(l, mux),
+		ReadHeaderTimeout: 30 * time.Second,
+		ReadTimeout:       30 * time.Second,
+	}
+	// the hijacked websocket connections are not tracked by the server
+	srv.RegisterOnShutdown(func() {
+		close(proxy.closing)
+	})
+
+	return srv
+}
+
+func (p *rpcProxy) handle(w http.ResponseWriter, r *http.Request) {
+	gname := r.PathValue("group")
+	if gname == defaultTargetGroup {
+		gname = ""
+	}
+	g := p.server.state.ExecutionGroup(gname)
+	if g == nil {
+		http.Error(w, "unknown group: "+r.PathValue("group"), http.StatusNotFound)
+		return
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		p.proxyWebsocket(w, r, gname, g)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	p.proxyHTTP(w, r, gname, g)
+}
+
+// candidates returns the healthy endpoints of the group from the best to the
+// worst.
+func (p *rpcProxy) candidates(gname string, g *state.ELGroup) []*apiBestCandidate {
+	best := p.server.best(gname, g, p.server.now())
+	return best.Candidates[:best.Healthy]
+}
+
+// allowed tells whether the method matches any of the allowlisted methods
+// (the ones ending with `*` match the prefix).
+func (p *rpcProxy) allowed(method string) bool {
+	for _, allowed := range p.server.cfg.Proxy.Methods {
+		if prefix, found := strings.CutSuffix(allowed, "*"); found {
+			if strings.HasPrefix(method, prefix) {
+				return true
+			}
+		} else if method == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// stateful tells whether the method installs (or removes) the subscription or
+// the filter on the endpoint (the ones starting with `*` match the suffix).
+func (p *rpcProxy) stateful(method string) bool {
+	for _, stateful := range proxyStatefulMethods {
+		if suffix, found := strings.CutPrefix(stateful, "*"); found {
+			if strings.HasSuffix(method, suffix) {
+				return true
+			}
+		} else if method == stateful {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *rpcProxy) healthy(gname string, g *state.ELGroup, id string) bool {
+	for _, c := range p.candidates(gname, g) {
+		if c.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// forward calls the healthy endpoints in order until the request reaches one
+// of them (the call must wrap the errors that happened before the request was
+// sent out with ErrProxyEndpointUnreachable).
+func (p *rpcProxy) forward(
+	ctx context.Context,
+	gname string,
+	g *state.ELGroup,
+	transport string,
+	call func(ctx context.Context, sub *subscriber.ELEndpoint) error,
+) (*apiBestCandidate, error) {
+	l := logutils.LoggerFromContext(ctx)
+
+	candidates := p.candidates(gname, g)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: %s",
+			ErrProxyNoHealthyEndpoint, normalisedGroup(gname),
+		)
+	}
+
+	var err error
+	for _, c := range candidates {
+		attrs := p.server.endpointAttributes(gname, c.Endpoint, state.EndpointKind(c.Kind))
+		p.server.metrics.proxyRequests.Add(ctx, 1, metric.WithAttributes(
+			append(attrs, attribute.String(keyProxyTransport, transport))...,
+		))
+
+		err = func() error {
+			ctx, cancel := context.WithTimeout(ctx, p.server.cfg.Proxy.Timeout)
+			defer cancel()
+			return call(ctx, p.server.subs[c.ID])
+		}()
+		if !errors.Is(err, ErrProxyEndpointUnreachable) || ctx.Err() != nil {
+			return c, err
+		}
+
+		l.Warn("Failed to proxy request to execution endpoint; failing over...",
+			zap.String("endpoint_group", gname),
+			zap.String("endpoint_name", c.Endpoint),
+			zap.Error(err),
+		)
+		p.server.metrics.proxyFailovers.Add(ctx, 1, metric.WithAttributes(attrs...))
+	}
+
+	return nil, err
+}
+
+func (p *rpcProxy) proxyHTTP(w http.ResponseWriter, r *http.Request, gname string, g *state.ELGroup) {
+	l := logutils.LoggerFromRequest(r)
+	ctx := logutils.ContextWithLogger(r.Context(), l)
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, proxyMaxRequestSize))
+	if err != nil {
+		writeRPCResponse(w, l, http.StatusBadRequest,
+			rpcErrorResponse(nil, rpcCodeParseError, err),
+		)
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	batch := len(body) > 0 && body[0] == '['
+
+	requests := make([]*rpcRequest, 0, 1)
+	if batch {
+		err = json.Unmarshal(body, &requests)
+	} else {
+		request := &rpcRequest{}
+		err = json.Unmarshal(body, request)
+		requests = append(requests, request)
+	}
+	if err != nil {
+		writeRPCResponse(w, l, http.StatusBadRequest,
+			rpcErrorResponse(nil, rpcCodeParseError, err),
+		)
+		return
+	}
+	if len(requests) == 0 {
+		writeRPCResponse(w, l, http.StatusBadRequest,
+			rpcErrorResponse(nil, rpcCodeInvalidRequest, errors.New("empty batch")),
+		)
+		return
+	}
+
+	// the malformed requests are responded to without bothering the endpoints
+	responses := make([]*rpcResponse, len(requests))
+	calls := make([]rpc.BatchElem, 0, len(requests))
+	forwarded := make([]int, 0, len(requests))
+	for idx, request := range requests {
+		if request.Method == "" {
+			responses[idx] = rpcErrorResponse(request.ID, rpcCodeInvalidRequest, errors.New("missing method"))
+			continue
+		}
+		if !p.allowed(request.Method) {
+			responses[idx] = rpcErrorResponse(request.ID, rpcCodeMethodNotFound,
+				fmt.Errorf("%w: %s", ErrProxyMethodNotAllowed, request.Method),
+			)
+			continue
+		}
+		if p.stateful(request.Method) {
+			responses[idx] = rpcErrorResponse(request.ID, rpcCodeMethodNotFound,
+				fmt.Errorf("%w: %s", ErrProxyStatefulMethod, request.Method),
+			)
+			continue
+		}
+		args, err := rpcArgs(request.Params)
+		if err != nil {
+			responses[idx] = rpcErrorResponse(request.ID, rpcCodeInvalidParams, err)
+			continue
+		}
+		calls = append(calls, rpc.BatchElem{
+			Method: request.Method,
+			Args:   args,
+			Result: &json.RawMessage{},
+		})
+		forwarded = append(forwarded, idx)
+	}
+
+	status := http.StatusOK
+	if len(calls) > 0 {
+		endpoint, err := p.forward(ctx, gname, g, proxyTransportHTTP, func(ctx context.Context, sub *subscriber.ELEndpoint) error {
+			if !batch {
+				return unreachable(sub.CallContext(ctx, calls[0].Result, calls[0].Method, calls[0].Args...))
+			}
+			for idx := range calls {
+				calls[idx].Error = nil
+			}
+			return unreachable(sub.BatchCallContext(ctx, calls))
+		})
+		if endpoint != nil {
+			w.Header().Set(headerProxyEndpoint, endpoint.ID)
+		}
+
+		switch {
+		case errors.Is(err, ErrProxyNoHealthyEndpoint):
+			status = http.StatusServiceUnavailable
+		case errors.Is(err, context.DeadlineExceeded):
+			status = http.StatusGatewayTimeout
+		case err != nil && !isRPCError(err):
+			status = http.StatusBadGateway
+		case !batch:
+			calls[0].Error = err
+		}
+
+		for cidx, idx := range forwarded {
+			call := calls[cidx]
+			switch {
+			case status != http.StatusOK:
+				responses[idx] = rpcErrorResponse(requests[idx].ID, rpcCodeUnavailable, err)
+			case call.Error != nil:
+				responses[idx] = rpcErrorResponse(requests[idx].ID, rpcCodeInternalError, call.Error)
+			default:
+				responses[idx] = rpcResultResponse(requests[idx].ID, *call.Result.(*json.RawMessage))
+			}
+		}
+	}
+
+	if batch {
+		writeRPCResponse(w, l, status, responses)
+	} else {
+		writeRPCResponse(w, l, status, responses[0])
+	}
+}
+
+func (p *rpcProxy) proxyWebsocket(w http.ResponseWriter, r *http.Request, gname string, g *state.ELGroup) {
+	l := logutils.LoggerFromRequest(r)
+	ctx := logutils.ContextWithLogger(r.Context(), l)
+
+	var upstream *websocket.Conn
+	endpoint, err := p.forward(ctx, gname, g, proxyTransportWebsocket, func(ctx context.Context, sub *subscriber.ELEndpoint) error {
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, sub.URI(), nil)
+		if err != nil {
+			// nothing was sent out yet
+			return fmt.Errorf("%w: %w", ErrProxyEndpointUnreachable, err)
+		}
+		upstream = conn
+		return nil
+	})
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, ErrProxyNoHealthyEndpoint) {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer upstream.Close()
+
+	client, err := proxyUpgrader.Upgrade(w, r, http.Header{headerProxyEndpoint: []string{endpoint.ID}})
+	if err != nil {
+		// the upgrader has already responded with the error
+		l.Warn("Failed to upgrade proxy connection to websocket",
+			zap.Error(err),
+		)
+		return
+	}
+	defer client.Close()
+
+	done := make(chan error, 2)
+	go pipeWebsocket(client, upstream, nil, done)
+	go pipeWebsocket(upstream, client, p.allowed, done)
+
+	health := time.NewTicker(proxyHealthCheckInterval)
+	defer health.Stop()
+
+	closeClient := func(code int, reason string) {
+		_ = client.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(code, reason),
+			time.Now().Add(time.Second),
+		)
+	}
+
+	for {
+		select {
+		case <-p.closing:
+			closeClient(websocket.CloseGoingAway, "proxy is shutting down")
+			return
+
+		case err := <-done:
+			var closeErr *websocket.CloseError
+			switch {
+			case errors.As(err, &closeErr):
+				closeClient(closeErr.Code, closeErr.Text)
+			case errors.Is(err, ErrProxyMethodNotAllowed):
+				closeClient(websocket.ClosePolicyViolation, err.Error())
+			default:
+				closeClient(websocket.CloseGoingAway, "")
+			}
+			return
+
+		case <-health.C:
+			if p.healthy(gname, g, endpoint.ID) {
+				continue
+			}
+			l.Info("Execution endpoint became unhealthy; closing proxied websocket connection...",
+				zap.String("endpoint_group", gname),
+				zap.String("endpoint_name", endpoint.Endpoint),
+			)
+			p.server.metrics.proxyFailovers.Add(ctx, 1, metric.WithAttributes(
+				p.server.endpointAttributes(gname, endpoint.Endpoint, state.EndpointKind(endpoint.Kind))...,
+			))
+			closeClient(websocket.CloseTryAgainLater, "endpoint is unhealthy")
+			return
+		}
+	}
+}
+
+var proxyUpgrader = websocket.Upgrader{}
+
+// pipeWebsocket copies the messages from src to dst until either of them
+// fails (or, if the allowlist is given, until the message calls the method
+// that is not allowed).
+func pipeWebsocket(dst, src *websocket.Conn, allowed func(method string) bool, done chan<- error) {
+	for {
+		typ, data, err := src.ReadMessage()
+		if err != nil {
+			done <- err
+			return
+		}
+		if allowed != nil {
+			if method, ok := rpcMethodsAllowed(data, allowed); !ok {
+				done <- fmt.Errorf("%w: %s", ErrProxyMethodNotAllowed, method)
+				return
+			}
+		}
+		if err := dst.WriteMessage(typ, data); err != nil {
+			done <- err
+			return
+		}
+	}
+}
+
+// rpcMethodsAllowed checks the methods of the json-rpc request (or of the
+// batch) against the allowlist, and returns the first one that is not allowed.
+// The messages that can not be parsed are let through for the endpoint to
+// reject them.
+func rpcMethodsAllowed(data []byte, allowed func(method string) bool) (string, bool) {
+	data = bytes.TrimSpace(data)
+	requests := make([]*rpcRequest, 0, 1)
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &requests); err != nil {
+			return "", true
+		}
+	} else {
+		request := &rpcRequest{}
+		if err := json.Unmarshal(data, request); err != nil {
+			return "", true
+		}
+		requests = append(requests, request)
+	}
+
+	for _, request := range requests {
+		if request.Method != "" && !allowed(request.Method) {
+			return request.Method, false
+		}
+	}
+	return "", true
+}
+
+// unreachable marks the errors that happened before the request was sent out
+// to the endpoint (so that it is safe to retry it with the next one).
+func unreachable(err error) error {
+	var opErr *net.OpError
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, subscriber.ErrNotConnected),
+		errors.Is(err, rpc.ErrClientQuit),
+		errors.As(err, &opErr) && opErr.Op == "dial":
+		return fmt.Errorf("%w: %w", ErrProxyEndpointUnreachable, err)
+	default:
+		return err
+	}
+}
+
+// rpcArgs turns the positional params into the call's args.
+func rpcArgs(params json.RawMessage) ([]interface{}, error) {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		return nil, nil
+	}
+	if params[0] != '[' {
+		return nil, ErrProxyNamedParams
+	}
+
+	raw := make([]json.RawMessage, 0)
+	if err := json.Unmarshal(params, &raw); err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, len(raw))
+	for idx, arg := range raw {
+		args[idx] = arg
+	}
+	return args, nil
+}
+
+// isRPCError tells whether the error came from the endpoint (as opposed to
+// the failure to reach it).
+func isRPCError(err error) bool {
+	var rpcErr rpc.Error
+	return errors.As(err, &rpcErr)
+}
+
+func rpcResultResponse(id, result json.RawMessage) *rpcResponse {
+	if len(result) == 0 {
+		result = json.RawMessage("null")
+	}
+	return &rpcResponse{
+		JSONRPC: "2.0",
+		ID:      rpcID(id),
+		Result:  result,
+	}
+}
+
+// rpcErrorResponse passes the endpoint's json-rpc error through as is, and
+// wraps any other error with the given code.
+func rpcErrorResponse(id json.RawMessage, code int, err error) *rpcResponse {
+	res := &rpcResponse{
+		JSONRPC: "2.0",
+		ID:      rpcID(id),
+		Error: &rpcErrorObject{
+			Code:    code,
+			Message: err.Error(),
+		},
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		res.Error.Code = rpcErr.ErrorCode()
+	}
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		res.Error.Data = dataErr.ErrorData()
+	}
+	return res
+}
+
+func rpcID(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+func writeRPCResponse(w http.ResponseWriter, l *zap.Logger, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		l.Error("Failed to encode proxy response",
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,186 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flashbots/node-monitor/fakenode"
+	"github.com/flashbots/node-monitor/server"
+	"github.com/gorilla/websocket"
+	"gotest.tools/assert"
+)
+
+type rpcResponse struct {
+	ID     int    `json:"id"`
+	Result string `json:"result"`
+	Error  *struct {
+		Code int `json:"code"`
+	} `json:"error"`
+}
+
+func proxyCall(t *testing.T, url, body string) (*http.Response, []byte) {
+	t.Helper()
+
+	res, err := http.Post(url, "application/json", strings.NewReader(body))
+	assert.NilError(t, err)
+	defer res.Body.Close()
+	raw := json.RawMessage{}
+	assert.NilError(t, json.NewDecoder(res.Body).Decode(&raw))
+	return res, raw
+}
+
+func TestProxy(t *testing.T) {
+	a, b := fakenode.New(), fakenode.New()
+	defer a.Close()
+	defer b.Close()
+
+	cfg := newTestConfig(t, map[string]*fakenode.Node{"g:a": a, "g:b": b})
+	cfg.Best.MaxLag = 2
+	cfg.Proxy.ListenAddress = freeAddress(t)
+	cfg.Proxy.Methods = []string{"eth_*"}
+	cfg.Proxy.Timeout = 500 * time.Millisecond
+	m := startMonitor(t, cfg)
+	url := "http://" + cfg.Proxy.ListenAddress + "/g"
+
+	chain := fakenode.NewChain(100, time.Now(), 12*time.Second)
+	header := chain.Next()
+	play(t, a, fakenode.WaitForSubscribers(1), fakenode.AnnounceHeader(header))
+	m.waitFor(series("highest_block", "g", "a", "101"))
+	play(t, b, fakenode.WaitForSubscribers(1), fakenode.Sleep(100*time.Millisecond), fakenode.AnnounceHeader(header))
+	m.waitFor(series("highest_block", "g", "b", "101"))
+
+	// a is the best one
+	res, raw := proxyCall(t, url, `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "g:a", res.Header.Get("X-Node-Monitor-Endpoint"))
+	single := rpcResponse{}
+	assert.NilError(t, json.Unmarshal(raw, &single))
+	assert.Equal(t, 1, single.ID)
+	assert.Equal(t, "0x65", single.Result)
+
+	// the batches are forwarded as a whole, the malformed calls and the ones
+	// that are not allowlisted are not
+	res, raw = proxyCall(t, url, `[
+		{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]},
+		{"jsonrpc":"2.0","id":2,"method":"eth_getBalance","params":{"address":"0x0"}},
+		{"jsonrpc":"2.0","id":3,"method":"eth_unknown"},
+		{"jsonrpc":"2.0","id":4,"method":"admin_nodeInfo"}
+	]`)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	batch := []rpcResponse{}
+	assert.NilError(t, json.Unmarshal(raw, &batch))
+	assert.Equal(t, 4, len(batch))
+	assert.Equal(t, "0x1", batch[0].Result)
+	assert.Equal(t, -32602, batch[1].Error.Code)
+	assert.Equal(t, -32601, batch[2].Error.Code)
+	assert.Equal(t, -32601, batch[3].Error.Code)
+
+	// the subscriptions and the filters would leak on the monitor's own
+	// connection, so they are refused over http
+	for _, method := range []string{
+		"eth_subscribe",
+		"eth_unsubscribe",
+		"eth_newFilter",
+		"eth_newBlockFilter",
+		"eth_newPendingTransactionFilter",
+	} {
+		res, raw = proxyCall(t, url, `{"jsonrpc":"2.0","id":5,"method":"`+method+`","params":[]}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode, method)
+		assert.Equal(t, "", res.Header.Get("X-Node-Monitor-Endpoint"), method)
+		refused := rpcResponse{}
+		assert.NilError(t, json.Unmarshal(raw, &refused))
+		assert.Assert(t, refused.Error != nil, method)
+		assert.Equal(t, -32601, refused.Error.Code, method)
+	}
+
+	// the websocket clients get their own connection to the best endpoint
+	conn, wsRes, err := websocket.DefaultDialer.Dial("ws://"+cfg.Proxy.ListenAddress+"/g", nil)
+	assert.NilError(t, err)
+	defer conn.Close()
+	assert.Equal(t, "g:a", wsRes.Header.Get("X-Node-Monitor-Endpoint"))
+	assert.NilError(t, conn.WriteJSON(map[string]interface{}{
+		"jsonrpc": "2.0", "id": 7, "method": "eth_blockNumber",
+	}))
+	assert.NilError(t, conn.SetReadDeadline(time.Now().Add(testTimeout)))
+	assert.NilError(t, conn.ReadJSON(&single))
+	assert.Equal(t, 7, single.ID)
+	assert.Equal(t, "0x65", single.Result)
+
+	// a falls behind, so the requests go to b...
+	h102, h103, h104 := chain.Next(), chain.Next(), chain.Next()
+	play(t, b, fakenode.AnnounceHeader(h102), fakenode.AnnounceHeader(h103), fakenode.AnnounceHeader(h104))
+	m.waitFor(series("highest_block", "g", "b", "104"))
+
+	res, raw = proxyCall(t, url, `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "g:b", res.Header.Get("X-Node-Monitor-Endpoint"))
+	assert.NilError(t, json.Unmarshal(raw, &single))
+	assert.Equal(t, "0x68", single.Result)
+
+	// ...and the websocket clients are told to reconnect
+	_, _, err = conn.ReadMessage()
+	assert.Assert(t, websocket.IsCloseError(err, websocket.CloseTryAgainLater), "%v", err)
+
+	// the websocket clients can't call the methods that are not allowlisted
+	conn, _, err = websocket.DefaultDialer.Dial("ws://"+cfg.Proxy.ListenAddress+"/g", nil)
+	assert.NilError(t, err)
+	defer conn.Close()
+	assert.NilError(t, conn.WriteJSON(map[string]interface{}{
+		"jsonrpc": "2.0", "id": 8, "method": "debug_traceBlockByNumber",
+	}))
+	assert.NilError(t, conn.SetReadDeadline(time.Now().Add(testTimeout)))
+	_, _, err = conn.ReadMessage()
+	assert.Assert(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), "%v", err)
+
+	// a is within the allowed lag again, and once b is gone the requests go
+	// back to a
+	play(t, a, fakenode.AnnounceHeader(h102))
+	m.waitFor(series("highest_block", "g", "a", "102"))
+	b.Close()
+	deadline := time.Now().Add(testTimeout)
+	for {
+		res, _ = proxyCall(t, url, `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`)
+		if res.StatusCode == http.StatusOK && res.Header.Get("X-Node-Monitor-Endpoint") == "g:a" {
+			break
+		}
+		assert.Assert(t, time.Now().Before(deadline), "the requests still don't go to a")
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// the requests that time out are not retried (they might have been
+	// executed already)
+	a.Delay(fakenode.MethodBlockNumber, 2*cfg.Proxy.Timeout)
+	res, raw = proxyCall(t, url, `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`)
+	assert.Equal(t, http.StatusGatewayTimeout, res.StatusCode)
+	assert.Equal(t, "g:a", res.Header.Get("X-Node-Monitor-Endpoint"))
+	assert.NilError(t, json.Unmarshal(raw, &single))
+	assert.Assert(t, single.Error != nil)
+	a.Delay(fakenode.MethodBlockNumber, 0)
+
+	res, err = http.Post("http://"+cfg.Proxy.ListenAddress+"/unknown", "application/json", strings.NewReader(`{}`))
+	assert.NilError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestProxyListenFailure(t *testing.T) {
+	node := fakenode.New()
+	defer node.Close()
+
+	// the address is taken already
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer l.Close()
+
+	cfg := newTestConfig(t, map[string]*fakenode.Node{"g:a": node})
+	cfg.Proxy.ListenAddress = l.Addr().String()
+	s, err := server.New(cfg)
+	assert.NilError(t, err)
+	assert.Assert(t, errors.Is(s.RunContext(context.Background()), server.ErrProxyFailedToListen))
+}
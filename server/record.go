@@ -0,0 +1,120 @@
+package server
+
+import (
+	"errors"
+	"time"
+
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/flashbots/node-monitor/eventlog"
+	"github.com/flashbots/node-monitor/state"
+	"github.com/flashbots/node-monitor/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	recorderBufferSize    = 1024
+	recorderFlushInterval = time.Second
+)
+
+var (
+	ErrRecorderFallingBehind = errors.New("header recorder is falling behind, the header is not recorded")
+)
+
+// headerRecorder writes the headers received by the subscribers into the
+// event log (that can be replayed later on).  The writing happens in the
+// background, off the subscribers' hot path, and the log is flushed
+// periodically (so that not much is lost if the process crashes).
+type headerRecorder struct {
+	kinds  map[string]state.EndpointKind
+	writer *eventlog.RotatingWriter
+
+	events chan *eventlog.Event
+	stop   chan struct{}
+	done   chan error
+}
+
+func newHeaderRecorder(
+	l *zap.Logger,
+	writer *eventlog.RotatingWriter,
+	kinds map[string]state.EndpointKind,
+) *headerRecorder {
+	r := &headerRecorder{
+		kinds:  kinds,
+		writer: writer,
+
+		events: make(chan *eventlog.Event, recorderBufferSize),
+		stop:   make(chan struct{}),
+		done:   make(chan error, 1),
+	}
+	go r.run(l)
+
+	return r
+}
+
+func (r *headerRecorder) RecordHeader(
+	group, name string,
+	ts time.Time,
+	header *ethtypes.Header,
+) error {
+	id := utils.MakeELEndpointID(group, name)
+
+	select {
+	case r.events <- &eventlog.Event{
+		Version:  eventlog.Version,
+		Endpoint: id,
+		Kind:     string(r.kinds[id]),
+		Time:     ts,
+		Header:   header,
+	}:
+		return nil
+	default:
+		return ErrRecorderFallingBehind
+	}
+}
+
+// Close writes out the headers that are still in the buffer, and finalises
+// the log.
+func (r *headerRecorder) Close() error {
+	close(r.stop)
+	return <-r.done
+}
+
+func (r *headerRecorder) run(l *zap.Logger) {
+	ticker := time.NewTicker(recorderFlushInterval)
+	defer ticker.Stop()
+
+	write := func(event *eventlog.Event) {
+		if err := r.writer.Write(event); err != nil {
+			l.Error("Failed to record the header",
+				zap.String("endpoint_id", event.Endpoint),
+				zap.String("block", event.Header.Number.String()),
+				zap.Error(err),
+			)
+		}
+	}
+
+	for {
+		select {
+		case event := <-r.events:
+			write(event)
+
+		case <-ticker.C:
+			if err := r.writer.Flush(); err != nil {
+				l.Error("Failed to flush the header recorder",
+					zap.Error(err),
+				)
+			}
+
+		case <-r.stop:
+			for {
+				select {
+				case event := <-r.events:
+					write(event)
+				default:
+					r.done <- r.writer.Close()
+					return
+				}
+			}
+		}
+	}
+}
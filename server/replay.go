@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/flashbots/node-monitor/config"
+	"github.com/flashbots/node-monitor/eventlog"
+	"github.com/flashbots/node-monitor/logutils"
+	"github.com/flashbots/node-monitor/state"
+	"github.com/flashbots/node-monitor/subscriber"
+	"github.com/flashbots/node-monitor/utils"
+	"github.com/prometheus/common/expfmt"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrReplayUnknownEndpointKind = errors.New("unknown endpoint kind in the event log")
+)
+
+// virtualClock follows the timestamps of the replayed events.
+type virtualClock struct {
+	ts atomic.Int64
+}
+
+func (c *virtualClock) now() time.Time {
+	return time.Unix(0, c.ts.Load())
+}
+
+func (c *virtualClock) set(ts time.Time) {
+	c.ts.Store(ts.UnixNano())
+}
+
+// NewReplay creates the server that is fed with the recorded header arrivals
+// instead of the live subscriptions.  The endpoints are taken from the events.
+func NewReplay(cfg *config.Config, events []*eventlog.Event) (*Server, error) {
+	l := zap.L()
+	ctx := logutils.ContextWithLogger(context.Background(), l)
+
+	// there's nothing to fetch the blocks from
+	replayCfg := *cfg
+	replayCfg.Eth.BackfillSkippedBlocks = false
+	replayCfg.Eth.FetchTransactionCount = false
+	replayCfg.Eth.ValidateBlocks = false
+	cfg = &replayCfg
+
+	kinds := make(map[string]state.EndpointKind)
+	for _, event := range events {
+		kind := state.EndpointKind(event.Kind)
+		switch kind {
+		case "":
+			kind = state.EndpointKindInternal
+		case state.EndpointKindInternal, state.EndpointKindExternal:
+		default:
+			return nil, fmt.Errorf("%w: %s: %s",
+				ErrReplayUnknownEndpointKind, event.Endpoint, event.Kind,
+			)
+		}
+		if _, _, err := utils.ParseELEndpointID(event.Endpoint); err != nil {
+			return nil, err
+		}
+		kinds[event.Endpoint] = kind
+	}
+
+	meter, registry, err := newMeter(ctx, cfg, false)
+	if err != nil {
+		return nil, err
+	}
+
+	labels, err := newEndpointLabels(l, cfg, kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := newState(cfg, kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	clock := &virtualClock{}
+	m := &metrics{}
+	s := &Server{
+		cfg:      cfg,
+		log:      l,
+		clock:    clock,
+		now:      clock.now,
+		meter:    meter,
+		registry: registry,
+
+		chain:   newChainTracker(),
+		events:  newEventHub(),
+		heads:   newHeadContents(m),
+		labels:  labels,
+		metrics: m,
+		state:   state,
+
+		engines: map[string]*subscriber.ELEngineEndpoint{},
+		subs:    map[string]*subscriber.ELEndpoint{},
+	}
+	if err := s.metrics.setup(s.meter, s.handleEventPrometheusObserve); err != nil {
+		return nil, fmt.Errorf("%w: %w",
+			ErrPrometheusFailedToSetupMetrics, err,
+		)
+	}
+
+	return s, nil
+}
+
+// Replay drives the events through the same pipeline as the live headers go,
+// in the order of their timestamps, while the server's clock follows them.
+func (s *Server) Replay(ctx context.Context, events []*eventlog.Event) error {
+	ctx = logutils.ContextWithLogger(ctx, s.log)
+
+	events = slices.Clone(events)
+	slices.SortStableFunc(events, func(a, b *eventlog.Event) int {
+		return a.Time.Compare(b.Time)
+	})
+
+	for _, event := range events {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		group, name, err := utils.ParseELEndpointID(event.Endpoint)
+		if err != nil {
+			return err
+		}
+		s.clock.set(event.Time)
+		s.handleEventEthNewHeader(ctx, group, name, event.Time, event.Header)
+	}
+
+	return nil
+}
+
+// WriteMetrics renders the current metrics in prometheus text format.
+func (s *Server) WriteMetrics(w io.Writer) error {
+	families, err := s.registry.Gather()
+	if err != nil {
+		return err
+	}
+
+	encoder := expfmt.NewEncoder(w, expfmt.FmtText)
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteReport renders the current status and leaderboard as json.
+func (s *Server) WriteReport(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(struct {
+		Status      *apiStatus      `json:"status"`
+		Leaderboard *apiLeaderboard `json:"leaderboard"`
+	}{
+		Status:      s.status(),
+		Leaderboard: s.leaderboard(""),
+	})
+}
@@ -0,0 +1,71 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flashbots/node-monitor/eventlog"
+	"github.com/flashbots/node-monitor/fakenode"
+	"github.com/flashbots/node-monitor/server"
+	"gotest.tools/assert"
+)
+
+func TestReplay(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	chain := fakenode.NewChain(100, start, 12*time.Second)
+
+	// a is always first, b is 80ms late and misses every 5th block
+	log := &bytes.Buffer{}
+	encoder := json.NewEncoder(log)
+	for i := 0; i < 10; i++ {
+		header := chain.Next()
+		ts := time.Unix(int64(header.Time), 0).Add(300 * time.Millisecond)
+		assert.NilError(t, encoder.Encode(&eventlog.Event{
+			Version: eventlog.Version, Endpoint: "g:a", Kind: "internal", Time: ts, Header: header,
+		}))
+		if i%5 != 4 {
+			assert.NilError(t, encoder.Encode(&eventlog.Event{
+				Version: eventlog.Version, Endpoint: "g:b", Kind: "external", Time: ts.Add(80 * time.Millisecond), Header: header,
+			}))
+		}
+	}
+
+	reader, err := eventlog.NewReader(log)
+	assert.NilError(t, err)
+	events, err := reader.ReadAll()
+	assert.NilError(t, err)
+	assert.Equal(t, 18, len(events))
+
+	cfg := newTestConfig(t, nil)
+	s, err := server.NewReplay(cfg, events)
+	assert.NilError(t, err)
+	assert.NilError(t, s.Replay(context.Background(), events))
+
+	metrics := &strings.Builder{}
+	assert.NilError(t, s.WriteMetrics(metrics))
+	for _, pattern := range []string{
+		`node_monitor_highest_block{node_monitor_target_group="g",node_monitor_target_name="__group",`,
+		`node_monitor_blocks_first_seen_total{endpoint_kind="internal",node_monitor_target_group="g",node_monitor_target_id="g:a",node_monitor_target_name="a",otel_scope_name="node-monitor-test",otel_scope_version=""} 10`,
+		`node_monitor_blocks_missed_total{endpoint_kind="external",node_monitor_target_group="g",node_monitor_target_id="g:b",node_monitor_target_name="b",otel_scope_name="node-monitor-test",otel_scope_version=""} 1`,
+		`node_monitor_internal_external_latency_delta_seconds{node_monitor_target_group="g",node_monitor_target_name="__group",otel_scope_name="node-monitor-test",otel_scope_version=""} -0.08`,
+	} {
+		assert.Assert(t, strings.Contains(metrics.String(), pattern), pattern)
+	}
+
+	// the clock is virtual (the real one would be years ahead)
+	report := &struct {
+		Status struct {
+			Groups map[string]struct {
+				TimeSinceLastBlock float64 `json:"time_since_last_block_s"`
+			} `json:"groups"`
+		} `json:"status"`
+	}{}
+	buf := &bytes.Buffer{}
+	assert.NilError(t, s.WriteReport(buf))
+	assert.NilError(t, json.Unmarshal(buf.Bytes(), report))
+	assert.Assert(t, report.Status.Groups["g"].TimeSinceLastBlock < 1)
+}
@@ -4,98 +4,368 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/flashbots/node-monitor/config"
+	"github.com/flashbots/node-monitor/eventlog"
 	"github.com/flashbots/node-monitor/httplogger"
 	"github.com/flashbots/node-monitor/logutils"
 	"github.com/flashbots/node-monitor/prometheus"
 	"github.com/flashbots/node-monitor/state"
 	"github.com/flashbots/node-monitor/subscriber"
+	"github.com/flashbots/node-monitor/tracing"
 	"github.com/flashbots/node-monitor/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	otelapi "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.uber.org/zap"
 
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type Server struct {
-	cfg   *config.Config
-	log   *zap.Logger
-	meter otelapi.Meter
+	cfg      *config.Config
+	log      *zap.Logger
+	clock    *virtualClock    // replay mode only
+	now      func() time.Time // virtual in replay mode
+	meter    otelapi.Meter
+	registry *prom.Registry
+	tracer   *sdktrace.TracerProvider
 
-	metrics *metrics
-	state   *state.State
+	bestPolicy state.BestPolicy // live mode only
+	chain      *chainTracker
+	events     *eventHub
+	federation *federation // live mode only
+	heads      *headContents
+	labels     map[string][]attribute.KeyValue // endpoint id -> label attributes
+	metrics    *metrics
+	recorder   *headerRecorder
+	state      *state.State
 
-	subs map[string]*subscriber.ELEndpoint
+	engines map[string]*subscriber.ELEngineEndpoint
+	ntps    map[string]*subscriber.NTPServer
+	subs    map[string]*subscriber.ELEndpoint
 }
 
 var (
+	ErrEngineEndpointDuplicateId          = errors.New("duplicate engine endpoint id")
+	ErrEngineEndpointFailedToSetup        = errors.New("failed to setup engine endpoint")
+	ErrEngineEndpointMissingJWTSecret     = errors.New("no jwt secret configured for engine endpoint")
+	ErrEngineEndpointUnknownId            = errors.New("engine endpoint id does not match any execution endpoint")
 	ErrExecutionEndpointDuplicateId       = errors.New("duplicate execution endpoint id")
 	ErrExecutionEndpointFailedToSubscribe = errors.New("failed to subscribe to execution endpoint ws rpc")
 	ErrExecutionEndpointFailedToRegister  = errors.New("failed to register execution endpoint")
+	ErrExecutionEndpointUnknownId         = errors.New("unknown execution endpoint id")
+	ErrNTPServerDuplicateAddress          = errors.New("duplicate ntp server address")
 	ErrPrometheusFailedToCreateMeter      = errors.New("failed to create prometheus meter")
 	ErrPrometheusFailedToSetupMetrics     = errors.New("failed to setup prometheus metrics")
+	ErrProxyFailedToListen                = errors.New("failed to listen for proxy connections")
+	ErrRecorderFailedToSetup              = errors.New("failed to setup header recorder")
+	ErrTracingFailedToCreateProvider      = errors.New("failed to create tracer provider")
 )
 
 func New(cfg *config.Config) (*Server, error) {
 	l := zap.L()
 	ctx := logutils.ContextWithLogger(context.Background(), l)
 
-	meter, err := prometheus.NewMeter(ctx, cfg.Server.Name)
+	meter, registry, err := newMeter(ctx, cfg, true)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %w",
-			ErrPrometheusFailedToCreateMeter, err,
-		)
+		return nil, err
 	}
 
-	state := state.New()
-	subs := make(map[string]*subscriber.ELEndpoint, len(cfg.Eth.ExecutionEndpoints))
-	for _, rpc := range cfg.Eth.ExecutionEndpoints {
+	var tracer *sdktrace.TracerProvider
+	if cfg.Tracing.Endpoint != "" {
+		tracer, err = tracing.NewTracerProvider(ctx, cfg.Server.Name, &cfg.Tracing)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w",
+				ErrTracingFailedToCreateProvider, err,
+			)
+		}
+		otel.SetTracerProvider(tracer)
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	}
+
+	subs := make(map[string]*subscriber.ELEndpoint,
+		len(cfg.Eth.ExecutionEndpoints)+len(cfg.Eth.ExternalExecutionEndpoints),
+	)
+	kinds := make(map[string]state.EndpointKind, len(subs))
+	for kind, rpcs := range executionEndpointsByKind(cfg) {
+		for _, rpc := range rpcs {
+			parts := strings.Split(rpc, "=")
+			id := parts[0]
+			uri := parts[1]
+			if _, exists := subs[id]; exists {
+				return nil, fmt.Errorf("%w: %s",
+					ErrExecutionEndpointDuplicateId, id,
+				)
+			}
+			group, name, err := utils.ParseELEndpointID(id)
+			if err != nil {
+				return nil, err
+			}
+			sub, err := subscriber.NewELEndpoint(cfg, group, name, uri)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w",
+					ErrExecutionEndpointFailedToSubscribe, err,
+				)
+			}
+			subs[id] = sub
+			kinds[id] = kind
+		}
+	}
+
+	labels, err := newEndpointLabels(l, cfg, kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	bestPolicy, err := state.NewBestPolicy(
+		cfg.Best.Policy,
+		cfg.Best.LagWeight,
+		cfg.Best.StalenessWeight,
+		cfg.Best.LatencyWeight,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := newState(cfg, kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	var recorder *headerRecorder
+	if cfg.Record.Path != "" {
+		writer, err := eventlog.NewRotatingWriter(cfg.Record.Path, cfg.Record.MaxSize, cfg.Record.MaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w",
+				ErrRecorderFailedToSetup, err,
+			)
+		}
+		recorder = newHeaderRecorder(l, writer, kinds)
+		for _, sub := range subs {
+			sub.RecordTo(recorder)
+		}
+	}
+
+	jwtSecrets := make(map[string]string, len(cfg.Eth.EngineJWTSecrets))
+	for _, secret := range cfg.Eth.EngineJWTSecrets {
+		if id, path, found := strings.Cut(secret, "="); found {
+			jwtSecrets[id] = path
+		} else {
+			jwtSecrets[""] = secret
+		}
+	}
+
+	engines := make(map[string]*subscriber.ELEngineEndpoint, len(cfg.Eth.EngineEndpoints))
+	for _, rpc := range cfg.Eth.EngineEndpoints {
 		parts := strings.Split(rpc, "=")
 		id := parts[0]
 		uri := parts[1]
-		if _, exists := subs[id]; exists {
+		if _, exists := engines[id]; exists {
 			return nil, fmt.Errorf("%w: %s",
-				ErrExecutionEndpointDuplicateId, id,
+				ErrEngineEndpointDuplicateId, id,
+			)
+		}
+		if _, exists := subs[id]; !exists {
+			return nil, fmt.Errorf("%w: %s",
+				ErrEngineEndpointUnknownId, id,
+			)
+		}
+		secret, exists := jwtSecrets[id]
+		if !exists {
+			secret, exists = jwtSecrets[""]
+		}
+		if !exists {
+			return nil, fmt.Errorf("%w: %s",
+				ErrEngineEndpointMissingJWTSecret, id,
 			)
 		}
 		group, name, err := utils.ParseELEndpointID(id)
 		if err != nil {
 			return nil, err
 		}
-		sub, err := subscriber.NewELEndpoint(cfg, group, name, uri)
+		engine, err := subscriber.NewELEngineEndpoint(cfg, group, name, uri, secret)
 		if err != nil {
-			return nil, fmt.Errorf("%w: %w",
-				ErrExecutionEndpointFailedToSubscribe, err,
+			return nil, fmt.Errorf("%w: %s: %w",
+				ErrEngineEndpointFailedToSetup, id, err,
 			)
 		}
-		subs[id] = sub
-		if err := state.RegisterExecutionEndpoint(group, name); err != nil {
-			return nil, fmt.Errorf("%w: %w",
-				ErrExecutionEndpointFailedToRegister, err,
+		engines[id] = engine
+	}
+
+	ntps := make(map[string]*subscriber.NTPServer, len(cfg.Clock.NTPServers))
+	for _, address := range cfg.Clock.NTPServers {
+		if _, exists := ntps[address]; exists {
+			return nil, fmt.Errorf("%w: %s",
+				ErrNTPServerDuplicateAddress, address,
 			)
 		}
+		ntps[address] = subscriber.NewNTPServer(cfg, address)
 	}
 
+	federation, err := newFederation(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &metrics{}
+
 	return &Server{
-		cfg:   cfg,
-		log:   l,
-		meter: meter,
+		cfg:      cfg,
+		log:      l,
+		now:      time.Now,
+		meter:    meter,
+		registry: registry,
+		tracer:   tracer,
 
-		metrics: &metrics{},
-		state:   state,
+		bestPolicy: bestPolicy,
+		chain:      newChainTracker(),
+		events:     newEventHub(),
+		federation: federation,
+		heads:      newHeadContents(m),
+		labels:     labels,
+		metrics:    m,
+		recorder:   recorder,
+		state:      state,
 
-		subs: subs,
+		engines: engines,
+		ntps:    ntps,
+		subs:    subs,
 	}, nil
 }
 
+// newMeter sets up the meter that exports into its own registry (instead of
+// the global one), which lets several servers co-exist within the same
+// process (e.g. in tests).
+func newMeter(ctx context.Context, cfg *config.Config, runtimeMetrics bool) (
+	otelapi.Meter, *prom.Registry, error,
+) {
+	registry := prom.NewRegistry()
+	if runtimeMetrics {
+		registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	}
+
+	meter, err := prometheus.NewMeter(ctx, cfg.Server.Name, registry)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w",
+			ErrPrometheusFailedToCreateMeter, err,
+		)
+	}
+
+	return meter, registry, nil
+}
+
+// newState registers the execution endpoints (by their ids) in the new state.
+func newState(cfg *config.Config, kinds map[string]state.EndpointKind) (
+	*state.State, error,
+) {
+	weights := make(map[string]float64, len(cfg.Eth.ExecutionEndpointWeights))
+	for _, w := range cfg.Eth.ExecutionEndpointWeights {
+		id, weight, _ := strings.Cut(w, "=")
+		var err error
+		if weights[id], err = strconv.ParseFloat(weight, 64); err != nil {
+			return nil, err
+		}
+	}
+	references := make(map[string]struct{}, len(cfg.Eth.ReferenceEndpoints))
+	for _, id := range cfg.Eth.ReferenceEndpoints {
+		references[id] = struct{}{}
+	}
+
+	for id := range weights {
+		if _, exists := kinds[id]; !exists {
+			return nil, fmt.Errorf("%w: %s",
+				ErrExecutionEndpointUnknownId, id,
+			)
+		}
+	}
+	for id := range references {
+		if _, exists := kinds[id]; !exists {
+			return nil, fmt.Errorf("%w: %s",
+				ErrExecutionEndpointUnknownId, id,
+			)
+		}
+	}
+
+	st, err := state.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for id, kind := range kinds {
+		group, name, err := utils.ParseELEndpointID(id)
+		if err != nil {
+			return nil, err
+		}
+		opts := stateEndpointOptions(id, kind, weights, references)
+		if err := st.RegisterExecutionEndpoint(group, name, opts); err != nil {
+			return nil, fmt.Errorf("%w: %w",
+				ErrExecutionEndpointFailedToRegister, err,
+			)
+		}
+	}
+
+	return st, nil
+}
+
+func executionEndpointsByKind(cfg *config.Config) map[state.EndpointKind][]string {
+	return map[state.EndpointKind][]string{
+		state.EndpointKindInternal: cfg.Eth.ExecutionEndpoints,
+		state.EndpointKindExternal: cfg.Eth.ExternalExecutionEndpoints,
+	}
+}
+
+func stateEndpointOptions(
+	id string,
+	kind state.EndpointKind,
+	weights map[string]float64,
+	references map[string]struct{},
+) state.ELEndpointOptions {
+	weight, exists := weights[id]
+	if !exists {
+		weight = 1
+	}
+	_, reference := references[id]
+
+	return state.ELEndpointOptions{
+		Kind:          kind,
+		ReferenceOnly: reference,
+		Weight:        weight,
+	}
+}
+
 func (s *Server) Run() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		terminator := make(chan os.Signal, 1)
+		signal.Notify(terminator, os.Interrupt, syscall.SIGTERM)
+		stop := <-terminator
+
+		s.log.Info("Stop signal received; shutting down...", zap.String("signal", stop.String()))
+		cancel()
+	}()
+
+	return s.RunContext(ctx)
+}
+
+// RunContext runs the monitor until the context is done.
+func (s *Server) RunContext(stopCtx context.Context) error {
 	l := s.log
 	ctx := logutils.ContextWithLogger(context.Background(), l)
 
@@ -107,7 +377,18 @@ func (s *Server) Run() error {
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.handleHealthcheck)
-	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/api/v1/events", s.handleEvents)
+	if s.federation != nil {
+		mux.HandleFunc("/api/v1/federation", s.handleFederation)
+		mux.HandleFunc("/api/v1/federation/arrivals", s.handleFederationArrivals)
+	}
+	mux.HandleFunc("/api/v1/groups/{group}/best", s.handleBest)
+	mux.HandleFunc("/api/v1/leaderboard", s.handleLeaderboard)
+	mux.HandleFunc("/api/v1/status", s.handleStatus)
+	mux.Handle("/ui/", http.StripPrefix("/ui/", http.FileServerFS(uiFS)))
+	mux.Handle("/metrics", promhttp.InstrumentMetricHandler(
+		s.registry, promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{}),
+	))
 	handler := httplogger.Middleware(l, mux)
 
 	srv := &http.Server{
@@ -119,16 +400,49 @@ func (s *Server) Run() error {
 		WriteTimeout:      30 * time.Second,
 	}
 
-	go func() {
-		terminator := make(chan os.Signal, 1)
-		signal.Notify(terminator, os.Interrupt, syscall.SIGTERM)
-		stop := <-terminator
+	var (
+		proxy         *http.Server
+		proxyListener net.Listener
+	)
+	if s.cfg.Proxy.ListenAddress != "" {
+		proxy = s.newProxyServer(l)
+		// the operator asked for the proxy, so there's no point in running
+		// without it
+		var err error
+		if proxyListener, err = net.Listen("tcp", s.cfg.Proxy.ListenAddress); err != nil {
+			return fmt.Errorf("%w: %w",
+				ErrProxyFailedToListen, err,
+			)
+		}
+	}
 
-		l.Info("Stop signal received; shutting down...", zap.String("signal", stop.String()))
+	federationCtx, stopFederation := context.WithCancel(ctx)
+	defer stopFederation()
 
+	go func() {
+		<-stopCtx.Done()
+
+		stopFederation()
 		for _, sub := range s.subs {
 			sub.Unsubscribe()
 		}
+		for _, engine := range s.engines {
+			engine.Stop()
+		}
+		for _, ntp := range s.ntps {
+			ntp.Stop()
+		}
+		s.events.close()
+		if s.federation != nil {
+			s.federation.arrivals.close()
+		}
+		if s.recorder != nil {
+			if err := s.recorder.Close(); err != nil {
+				l.Error("Header recorder shutdown failed",
+					zap.Error(err),
+				)
+			}
+		}
 
 		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 		defer cancel()
@@ -137,6 +451,20 @@ func (s *Server) Run() error {
 				zap.Error(err),
 			)
 		}
+		if proxy != nil {
+			if err := proxy.Shutdown(ctx); err != nil {
+				l.Error("Proxy server shutdown failed",
+					zap.Error(err),
+				)
+			}
+		}
+		if s.tracer != nil {
+			if err := s.tracer.Shutdown(ctx); err != nil {
+				l.Error("Tracer provider shutdown failed",
+					zap.Error(err),
+				)
+			}
+		}
 	}()
 
 	l.Info("Starting up the monitor server...",
@@ -144,7 +472,34 @@ func (s *Server) Run() error {
 	)
 
 	for _, sub := range s.subs {
+		sub.WatchSubscription(s.handleEventEthSubscription)
 		sub.Subscribe(ctx, s.handleEventEthNewHeader)
+		sub.Probe(ctx, s.handleEventEthRPCProbe)
+	}
+	for _, engine := range s.engines {
+		engine.Probe(ctx, s.handleEventEngineProbe)
+	}
+	for _, ntp := range s.ntps {
+		ntp.Probe(ctx, s.handleEventNTPProbe)
+	}
+
+	if s.federation != nil {
+		l.Info("Joining the federation...",
+			zap.String("federation_region", s.federation.region),
+			zap.Int("federation_peers", len(s.federation.peers)),
+		)
+		go s.runFederation(federationCtx)
+	}
+
+	if proxy != nil {
+		l.Info("Starting up the proxy server...",
+			zap.String("proxy_listen_address", s.cfg.Proxy.ListenAddress),
+		)
+		go func() {
+			if err := proxy.Serve(proxyListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				l.Error("Proxy server failed", zap.Error(err))
+			}
+		}()
 	}
 
 	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -0,0 +1,463 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flashbots/node-monitor/config"
+	"github.com/flashbots/node-monitor/eventlog"
+	"github.com/flashbots/node-monitor/fakenode"
+	"github.com/flashbots/node-monitor/server"
+	"github.com/flashbots/node-monitor/state"
+	"gotest.tools/assert"
+)
+
+const (
+	testTimeout = 10 * time.Second
+)
+
+// monitor is the node-monitor server that runs against the fake nodes.
+type monitor struct {
+	t    *testing.T
+	url  string
+	stop func()
+}
+
+// freeAddress picks a free local port.
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	addr := l.Addr().String()
+	assert.NilError(t, l.Close())
+	return addr
+}
+
+func newTestConfig(t *testing.T, nodes map[string]*fakenode.Node) *config.Config {
+	endpoints := make([]string, 0, len(nodes))
+	for id, node := range nodes {
+		endpoints = append(endpoints, id+"="+node.URL())
+	}
+
+	return &config.Config{
+		Best: config.Best{
+			MaxLag:       1,
+			MaxStaleness: time.Minute,
+			Policy:       state.BestPolicyLag,
+		},
+		Eth: config.Eth{
+			BlockFetchTimeout:   time.Second,
+			ExecutionEndpoints:  endpoints,
+			GroupHeadPolicy:     state.HeadPolicyMax,
+			ResubscribeInterval: 100 * time.Millisecond,
+			SlotDuration:        12 * time.Second,
+		},
+		Leaderboard: config.Leaderboard{
+			Horizon:   2,
+			Threshold: 50 * time.Millisecond,
+			Window:    time.Hour,
+		},
+		Server: config.Server{
+			ListenAddress: freeAddress(t),
+			Name:          "node-monitor-test",
+		},
+		Stats: config.Stats{
+			Quantiles: []float64{0.5},
+			Windows:   []time.Duration{5 * time.Minute},
+		},
+	}
+}
+
+func startMonitor(t *testing.T, cfg *config.Config) *monitor {
+	s, err := server.New(cfg)
+	assert.NilError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.Check(t, s.RunContext(ctx))
+	}()
+	stop := sync.OnceFunc(func() {
+		cancel()
+		<-done
+	})
+	t.Cleanup(stop)
+
+	m := &monitor{
+		t:    t,
+		url:  "http://" + cfg.Server.ListenAddress,
+		stop: stop,
+	}
+	m.waitFor(regexp.MustCompile(`^# HELP`))
+
+	return m
+}
+
+func (m *monitor) scrape() string {
+	res, err := http.Get(m.url + "/metrics")
+	if err != nil {
+		return ""
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return ""
+	}
+	return string(body)
+}
+
+// waitFor scrapes the metrics until some line matches the pattern and returns
+// that line.
+func (m *monitor) waitFor(pattern *regexp.Regexp) string {
+	m.t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	var metrics string
+	for time.Now().Before(deadline) {
+		metrics = m.scrape()
+		for _, line := range strings.Split(metrics, "\n") {
+			if pattern.MatchString(line) {
+				return line
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	m.t.Fatalf("no metric matches %s in:\n%s", pattern, metrics)
+	return ""
+}
+
+// series returns the pattern of the endpoint's metric with the given value.
+func series(metric, group, name, value string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf(
+		`^node_monitor_%s\{.*node_monitor_target_id="%s:%s".*\} %s$`,
+		metric, group, name, regexp.QuoteMeta(value),
+	))
+}
+
+func play(t *testing.T, node *fakenode.Node, steps ...fakenode.Step) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	assert.NilError(t, node.Play(ctx, steps...))
+}
+
+func TestFirstSeenAndLatency(t *testing.T) {
+	a, b := fakenode.New(), fakenode.New()
+	defer a.Close()
+	defer b.Close()
+
+	m := startMonitor(t, newTestConfig(t, map[string]*fakenode.Node{"g:a": a, "g:b": b}))
+
+	chain := fakenode.NewChain(100, time.Now(), 12*time.Second)
+	header := chain.Next()
+	play(t, a, fakenode.WaitForSubscribers(1), fakenode.AnnounceHeader(header))
+	m.waitFor(series("highest_block", "g", "a", "101"))
+	play(t, b, fakenode.WaitForSubscribers(1), fakenode.Sleep(100*time.Millisecond), fakenode.AnnounceHeader(header))
+	m.waitFor(series("highest_block", "g", "b", "101"))
+
+	m.waitFor(series("blocks_first_seen_total", "g", "a", "1"))
+	m.waitFor(series("blocks_first_seen_total", "g", "b", "0"))
+	m.waitFor(series("blocks_seen_within_total", "g", "a", "1"))
+	m.waitFor(series("blocks_seen_within_total", "g", "b", "0"))
+	m.waitFor(series("new_block_latency_seconds_count", "g", "b", "1"))
+
+	// b was late by >100ms, which falls into the (0.09375, 0.1875] bucket
+	m.waitFor(regexp.MustCompile(
+		`^node_monitor_new_block_latency_seconds_bucket\{.*node_monitor_target_id="g:b".*le="0.09375"\} 0$`,
+	))
+	m.waitFor(regexp.MustCompile(
+		`^node_monitor_new_block_latency_seconds_bucket\{.*node_monitor_target_id="g:b".*le="0.1875"\} 1$`,
+	))
+
+	// the status tells the connection state and the latest latency
+	res, err := http.Get(m.url + "/api/v1/status")
+	assert.NilError(t, err)
+	defer res.Body.Close()
+	status := struct {
+		Groups map[string]struct {
+			Endpoints map[string]struct {
+				Subscribed  *bool    `json:"subscribed"`
+				LastLatency *float64 `json:"last_latency_s"`
+			} `json:"endpoints"`
+		} `json:"groups"`
+	}{}
+	assert.NilError(t, json.NewDecoder(res.Body).Decode(&status))
+	b0 := status.Groups["g"].Endpoints["b"]
+	assert.Assert(t, b0.Subscribed != nil && *b0.Subscribed)
+	assert.Assert(t, b0.LastLatency != nil && *b0.LastLatency >= 0.1)
+}
+
+func TestMissedBlocks(t *testing.T) {
+	a, b := fakenode.New(), fakenode.New()
+	defer a.Close()
+	defer b.Close()
+
+	m := startMonitor(t, newTestConfig(t, map[string]*fakenode.Node{"g:a": a, "g:b": b}))
+
+	chain := fakenode.NewChain(100, time.Now(), 12*time.Second)
+	header := chain.Next()
+	play(t, a, fakenode.WaitForSubscribers(1), fakenode.AnnounceHeader(header))
+	play(t, b, fakenode.WaitForSubscribers(1), fakenode.AnnounceHeader(header))
+	m.waitFor(series("highest_block", "g", "b", "101"))
+
+	// b doesn't see 102 before it falls behind the horizon (of 2 blocks)
+	play(t, a,
+		fakenode.AnnounceHeader(chain.Next()),
+		fakenode.AnnounceHeader(chain.Next()),
+		fakenode.AnnounceHeader(chain.Next()),
+	)
+	m.waitFor(series("highest_block", "g", "a", "104"))
+	m.waitFor(series("blocks_missed_total", "g", "a", "0"))
+	m.waitFor(series("blocks_missed_total", "g", "b", "1"))
+}
+
+func TestSkippedBlocks(t *testing.T) {
+	node := fakenode.New()
+	defer node.Close()
+
+	m := startMonitor(t, newTestConfig(t, map[string]*fakenode.Node{"g:a": node}))
+
+	chain := fakenode.NewChain(100, time.Now(), 12*time.Second)
+	play(t, node, fakenode.WaitForSubscribers(1), fakenode.AnnounceHeader(chain.Next()))
+	m.waitFor(series("highest_block", "g", "a", "101"))
+
+	chain.Next()
+	chain.Next()
+	chain.Next()
+	play(t, node, fakenode.AnnounceHeader(chain.Next()))
+	m.waitFor(series("highest_block", "g", "a", "105"))
+	m.waitFor(series("blocks_skipped_total", "g", "a", "3"))
+}
+
+func TestReorg(t *testing.T) {
+	node := fakenode.New()
+	defer node.Close()
+
+	m := startMonitor(t, newTestConfig(t, map[string]*fakenode.Node{"g:a": node}))
+
+	chain := fakenode.NewChain(100, time.Now(), 12*time.Second)
+	play(t, node,
+		fakenode.WaitForSubscribers(1),
+		fakenode.AnnounceHeader(chain.Next()),
+		fakenode.AnnounceHeader(chain.Next()),
+		fakenode.AnnounceHeader(chain.Reorg(1)),
+		fakenode.AnnounceHeader(chain.Next()),
+	)
+	m.waitFor(series("highest_block", "g", "a", "103"))
+	m.waitFor(series("blocks_skipped_total", "g", "a", "0"))
+
+	// the endpoint measures every header it got, while the group measures the
+	// first arrival of each block number only
+	m.waitFor(series("new_block_latency_seconds_count", "g", "a", "4"))
+	m.waitFor(regexp.MustCompile(
+		`^node_monitor_new_block_latency_seconds_count\{.*node_monitor_target_name="__group_internal".*\} 3$`,
+	))
+}
+
+func TestResubscribeAfterDisconnect(t *testing.T) {
+	node := fakenode.New()
+	defer node.Close()
+
+	m := startMonitor(t, newTestConfig(t, map[string]*fakenode.Node{"g:a": node}))
+
+	chain := fakenode.NewChain(100, time.Now(), 12*time.Second)
+	play(t, node, fakenode.WaitForSubscribers(1), fakenode.AnnounceHeader(chain.Next()))
+	m.waitFor(series("highest_block", "g", "a", "101"))
+
+	play(t, node,
+		fakenode.DropConnections(),
+		fakenode.WaitForSubscribers(1),
+		fakenode.AnnounceHeader(chain.Next()),
+	)
+	m.waitFor(series("highest_block", "g", "a", "102"))
+}
+
+func TestSubscriptionFailures(t *testing.T) {
+	node := fakenode.New()
+	defer node.Close()
+	node.Fail(fakenode.MethodSubscribeNewHeads, errors.New("not now"))
+
+	m := startMonitor(t, newTestConfig(t, map[string]*fakenode.Node{"g:a": node}))
+
+	// a few re-subscription attempts fail
+	play(t, node, fakenode.Sleep(300*time.Millisecond))
+	assert.Equal(t, 0, node.Subscribers())
+
+	chain := fakenode.NewChain(100, time.Now(), 12*time.Second)
+	play(t, node,
+		fakenode.FailMethod(fakenode.MethodSubscribeNewHeads, nil),
+		fakenode.WaitForSubscribers(1),
+		fakenode.AnnounceHeader(chain.Next()),
+	)
+	m.waitFor(series("highest_block", "g", "a", "101"))
+}
+
+func TestRecord(t *testing.T) {
+	node := fakenode.New()
+	defer node.Close()
+
+	cfg := newTestConfig(t, map[string]*fakenode.Node{"g:a": node})
+	cfg.Record.Path = filepath.Join(t.TempDir(), "headers")
+	m := startMonitor(t, cfg)
+
+	chain := fakenode.NewChain(100, time.Now(), 12*time.Second)
+	play(t, node,
+		fakenode.WaitForSubscribers(1),
+		fakenode.AnnounceHeader(chain.Next()),
+		fakenode.AnnounceHeader(chain.Next()),
+	)
+	m.waitFor(series("highest_block", "g", "a", "102"))
+	m.stop()
+
+	files, err := filepath.Glob(cfg.Record.Path + ".*.jsonl.gz")
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(files))
+
+	f, err := os.Open(files[0])
+	assert.NilError(t, err)
+	defer f.Close()
+	reader, err := eventlog.NewReader(f)
+	assert.NilError(t, err)
+	events, err := reader.ReadAll()
+	assert.NilError(t, err)
+
+	assert.Equal(t, 2, len(events))
+	for idx, event := range events {
+		assert.Equal(t, "g:a", event.Endpoint)
+		assert.Equal(t, "internal", event.Kind)
+		assert.Equal(t, chain.Head().Number.Uint64()-1+uint64(idx), event.Header.Number.Uint64())
+	}
+	assert.Equal(t, chain.Head().Hash(), events[1].Header.Hash())
+}
+
+func TestHeaderTimeSkew(t *testing.T) {
+	node := fakenode.New()
+	defer node.Close()
+
+	cfg := newTestConfig(t, map[string]*fakenode.Node{"g:a": node})
+	cfg.Clock.SkewBlocks = 8
+	cfg.Eth.MaxClockSkew = 10 * time.Second
+	m := startMonitor(t, cfg)
+
+	// the blocks arrive ~3s before their timestamps (the local clock is behind);
+	// the header timestamps are whole seconds, so the chain starts at one too
+	chain := fakenode.NewChain(100, time.Unix(time.Now().Unix()+3-12, 0), 12*time.Second)
+	play(t, node, fakenode.WaitForSubscribers(1), fakenode.AnnounceHeader(chain.Next()))
+
+	line := m.waitFor(regexp.MustCompile(
+		`^node_monitor_header_time_skew_seconds\{.*node_monitor_target_id="g:a".*\} `,
+	))
+	skew, err := strconv.ParseFloat(line[strings.LastIndex(line, " ")+1:], 64)
+	assert.NilError(t, err)
+	assert.Assert(t, skew > -3.1 && skew < -1.5, skew)
+}
+
+func TestEndpointLabels(t *testing.T) {
+	a, b := fakenode.New(), fakenode.New()
+	defer a.Close()
+	defer b.Close()
+
+	cfg := newTestConfig(t, map[string]*fakenode.Node{"g:a": a, "g:b": b})
+	cfg.Eth.ExecutionEndpointLabelKeys = []string{"client", "region"}
+	cfg.Eth.ExecutionEndpointLabels = []string{
+		"g:a=region=eu", "g:a = client = geth", "g:a=rack=r1",
+		"g:b=region=us",
+	}
+	m := startMonitor(t, cfg)
+
+	chain := fakenode.NewChain(100, time.Now(), 12*time.Second)
+	header := chain.Next()
+	play(t, a, fakenode.WaitForSubscribers(1), fakenode.AnnounceHeader(header))
+	play(t, b, fakenode.WaitForSubscribers(1), fakenode.AnnounceHeader(header))
+
+	line := m.waitFor(series("highest_block", "g", "a", "101"))
+	assert.Assert(t, strings.Contains(line, `node_monitor_label_region="eu"`), line)
+	assert.Assert(t, strings.Contains(line, `node_monitor_label_client="geth"`), line)
+	assert.Assert(t, !strings.Contains(line, "rack"), line)
+
+	line = m.waitFor(regexp.MustCompile(
+		`^node_monitor_new_block_latency_seconds_count\{.*node_monitor_target_id="g:b".*\} 1$`,
+	))
+	assert.Assert(t, strings.Contains(line, `node_monitor_label_region="us"`), line)
+
+	// the group-level series are not labelled
+	line = m.waitFor(regexp.MustCompile(
+		`^node_monitor_highest_block\{.*node_monitor_target_name="__group".*\} 101$`,
+	))
+	assert.Assert(t, !strings.Contains(line, "node_monitor_label_"), line)
+
+	cfg = newTestConfig(t, map[string]*fakenode.Node{"g:a": a})
+	cfg.Eth.ExecutionEndpointLabelKeys = []string{"region"}
+	cfg.Eth.ExecutionEndpointLabels = []string{"g:unknown=region=eu"}
+	_, err := server.New(cfg)
+	assert.Assert(t, errors.Is(err, server.ErrExecutionEndpointUnknownId), err)
+
+	for _, label := range []string{"g:a", "g:a=region", "g:a=1region=eu"} {
+		cfg.Eth.ExecutionEndpointLabels = []string{label}
+		_, err = server.New(cfg)
+		assert.Assert(t, errors.Is(err, server.ErrExecutionEndpointUnexpectedLabel), err)
+	}
+}
+
+func TestHeadContent(t *testing.T) {
+	a, b := fakenode.New(), fakenode.New()
+	defer a.Close()
+	defer b.Close()
+
+	cfg := newTestConfig(t, map[string]*fakenode.Node{"g:a": a, "g:b": b})
+	cfg.Eth.FetchTransactionCount = true
+	m := startMonitor(t, cfg)
+
+	blobGasUsed, excessBlobGas := uint64(262144), uint64(0)
+	chain := fakenode.NewChain(100, time.Now(), 12*time.Second)
+	header := chain.Next()
+	header.BaseFee = big.NewInt(7)
+	header.GasUsed = 15_000_000
+	header.BlobGasUsed = &blobGasUsed
+	header.ExcessBlobGas = &excessBlobGas
+
+	// the competing block of the same height doesn't replace the head's one,
+	// unless the endpoint reorgs into it
+	competing := *header
+	competing.BaseFee = big.NewInt(8)
+	competing.Extra = []byte{1}
+
+	group := func(metric, value string) *regexp.Regexp {
+		return regexp.MustCompile(fmt.Sprintf(
+			`^node_monitor_%s\{.*node_monitor_target_group="g".*\} %s$`,
+			metric, regexp.QuoteMeta(value),
+		))
+	}
+
+	play(t, a, fakenode.WaitForSubscribers(1), fakenode.AnnounceHeader(header))
+	m.waitFor(group("head_base_fee_sum", "7"))
+	m.waitFor(group("head_transactions_count", "1"))
+	play(t, b, fakenode.WaitForSubscribers(1), fakenode.AnnounceHeader(&competing))
+	m.waitFor(series("highest_block", "g", "b", "101"))
+	play(t, a, fakenode.AnnounceHeader(&competing))
+
+	// both heads are recorded, and only once
+	m.waitFor(group("head_transactions_count", "2"))
+	m.waitFor(group("head_base_fee_sum", "15"))
+	m.waitFor(group("head_base_fee_count", "2"))
+	m.waitFor(regexp.MustCompile(`^node_monitor_head_gas_used_ratio_bucket\{.*le="0.5".*\} 2$`))
+	m.waitFor(regexp.MustCompile(`^node_monitor_head_blob_gas_used_bucket\{.*le="262144".*\} 2$`))
+	m.waitFor(regexp.MustCompile(`^node_monitor_head_blob_gas_used_bucket\{.*le="131072".*\} 0$`))
+	m.waitFor(regexp.MustCompile(`^node_monitor_head_excess_blob_gas_bucket\{.*le="0".*\} 2$`))
+	m.waitFor(regexp.MustCompile(`^node_monitor_head_transactions_bucket\{.*le="0".*\} 2$`))
+}
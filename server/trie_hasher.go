@@ -0,0 +1,144 @@
+package server
+
+import (
+	"bytes"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// trieHasher computes the root of the merkle-patricia trie over the key/value
+// pairs (the way ethtypes.DeriveSha needs it).  Unlike go-ethereum's stack
+// trie it doesn't link in the node's database layer (and, with it, pebble,
+// leveldb and zstd) that the monitor has no use for.  It keeps the pairs in
+// memory until the hash is requested, which is fine for the size of a block.
+type trieHasher struct {
+	pairs []trieHasherPair
+}
+
+type trieHasherPair struct {
+	key   []byte // nibbles
+	value []byte
+}
+
+func newTrieHasher() *trieHasher {
+	return &trieHasher{}
+}
+
+func (h *trieHasher) Reset() {
+	h.pairs = h.pairs[:0]
+}
+
+// Update remembers the pair (the caller may reuse the slices afterwards).
+func (h *trieHasher) Update(key, value []byte) error {
+	nibbles := make([]byte, 0, 2*len(key))
+	for _, b := range key {
+		nibbles = append(nibbles, b>>4, b&0x0f)
+	}
+	h.pairs = append(h.pairs, trieHasherPair{
+		key:   nibbles,
+		value: bytes.Clone(value),
+	})
+	return nil
+}
+
+func (h *trieHasher) Hash() common.Hash {
+	if len(h.pairs) == 0 {
+		return crypto.Keccak256Hash(rlp.EmptyString)
+	}
+
+	slices.SortStableFunc(h.pairs, func(a, b trieHasherPair) int {
+		return bytes.Compare(a.key, b.key)
+	})
+
+	// the last update of the key wins
+	pairs := h.pairs[:0]
+	for idx, pair := range h.pairs {
+		if idx+1 < len(h.pairs) && bytes.Equal(pair.key, h.pairs[idx+1].key) {
+			continue
+		}
+		pairs = append(pairs, pair)
+	}
+	h.pairs = pairs
+
+	return crypto.Keccak256Hash(encodeTrieNode(pairs, 0))
+}
+
+// encodeTrieNode returns the rlp of the node that holds the sorted pairs whose
+// keys share the first depth nibbles.
+func encodeTrieNode(pairs []trieHasherPair, depth int) []byte {
+	w := rlp.NewEncoderBuffer(nil)
+	node := w.List()
+
+	first, last := pairs[0].key[depth:], pairs[len(pairs)-1].key[depth:]
+	prefix := 0
+	for prefix < len(first) && prefix < len(last) && first[prefix] == last[prefix] {
+		prefix++
+	}
+
+	switch {
+	case len(pairs) == 1: // leaf
+		w.WriteBytes(hexPrefix(first, true))
+		w.WriteBytes(pairs[0].value)
+
+	case prefix > 0: // extension
+		w.WriteBytes(hexPrefix(first[:prefix], false))
+		writeTrieChild(w, encodeTrieNode(pairs, depth+prefix))
+
+	default: // branch
+		var value []byte
+		if len(first) == 0 {
+			value = pairs[0].value
+			pairs = pairs[1:]
+		}
+		for nibble := byte(0); nibble < 16; nibble++ {
+			end := 0
+			for end < len(pairs) && pairs[end].key[depth] == nibble {
+				end++
+			}
+			if end == 0 {
+				w.WriteBytes(nil)
+				continue
+			}
+			writeTrieChild(w, encodeTrieNode(pairs[:end], depth+1))
+			pairs = pairs[end:]
+		}
+		w.WriteBytes(value)
+	}
+
+	w.ListEnd(node)
+	return w.ToBytes()
+}
+
+// writeTrieChild embeds the small nodes into their parent as is, and refers to
+// the rest of them by their hashes.
+func writeTrieChild(w rlp.EncoderBuffer, child []byte) {
+	if len(child) < 32 {
+		_, _ = w.Write(child)
+		return
+	}
+	w.WriteBytes(crypto.Keccak256(child))
+}
+
+// hexPrefix packs the nibbles into bytes, flagging whether the node is a leaf
+// and whether the count of nibbles is odd.
+func hexPrefix(nibbles []byte, leaf bool) []byte {
+	flag := byte(0)
+	if leaf {
+		flag = 2
+	}
+
+	packed := make([]byte, 0, len(nibbles)/2+1)
+	if len(nibbles)%2 == 1 {
+		packed = append(packed, (flag+1)<<4|nibbles[0])
+		nibbles = nibbles[1:]
+	} else {
+		packed = append(packed, flag<<4)
+	}
+	for idx := 0; idx < len(nibbles); idx += 2 {
+		packed = append(packed, nibbles[idx]<<4|nibbles[idx+1])
+	}
+	return packed
+}
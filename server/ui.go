@@ -0,0 +1,18 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed ui
+var uiFiles embed.FS
+
+// uiFS is the static web ui (served under /ui/).
+var uiFS = func() fs.FS {
+	sub, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}()
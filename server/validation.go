@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/flashbots/node-monitor/logutils"
+	"github.com/flashbots/node-monitor/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+const (
+	blockFetchRetryInterval = 100 * time.Millisecond
+
+	failureReasonUnavailable      = "unavailable"
+	failureReasonBlockHash        = "block_hash"
+	failureReasonTransactionsRoot = "transactions_root"
+	failureReasonReceiptsCount    = "receipts_count"
+	failureReasonReceiptsRoot     = "receipts_root"
+	failureReasonGasUsed          = "gas_used"
+)
+
+var (
+	ErrBlockHashMismatch        = errors.New("block hash does not match the header")
+	ErrBlockTransactionsRoot    = errors.New("transactions root does not match the header")
+	ErrBlockReceiptsRoot        = errors.New("receipts root does not match the header")
+	ErrBlockReceiptsCount       = errors.New("receipts count does not match transactions count")
+	ErrBlockGasUsedMismatch     = errors.New("gas used does not match the header")
+	ErrBlockUnknownSubscription = errors.New("unknown execution endpoint subscription")
+)
+
+func (s *Server) fetchAndValidateBlock(
+	ctx context.Context,
+	gname, ename string,
+	ts time.Time,
+	header *ethtypes.Header,
+) {
+	l := logutils.LoggerFromContext(ctx)
+
+	id := utils.MakeELEndpointID(gname, ename)
+	attrs := s.endpointAttributes(gname, ename, s.endpointKind(gname, ename))
+
+	block, receipts, err := s.fetchBlock(ctx, id, header)
+	if err != nil {
+		l.Warn("Failed to fetch the block announced by execution endpoint",
+			zap.String("block", header.Number.String()),
+			zap.String("block_hash", header.Hash().String()),
+			zap.String("endpoint_group", gname),
+			zap.String("endpoint_name", ename),
+			zap.Error(err),
+		)
+		s.metrics.blockValidationFailures.Add(ctx, 1, metric.WithAttributes(
+			append(attrs, attribute.String(keyFailureReason, failureReasonUnavailable))...,
+		))
+		return
+	}
+
+	s.metrics.blockFetchLatency.Record(ctx,
+		s.now().Sub(ts).Seconds(),
+		metric.WithAttributes(attrs...),
+	)
+
+	if reason, err := validateBlock(header, block, receipts); err != nil {
+		l.Warn("Block served by execution endpoint failed validation",
+			zap.String("block", header.Number.String()),
+			zap.String("block_hash", header.Hash().String()),
+			zap.String("endpoint_group", gname),
+			zap.String("endpoint_name", ename),
+			zap.Error(err),
+		)
+		s.metrics.blockValidationFailures.Add(ctx, 1, metric.WithAttributes(
+			append(attrs, attribute.String(keyFailureReason, reason))...,
+		))
+	}
+}
+
+func (s *Server) fetchBlock(
+	ctx context.Context,
+	id string,
+	header *ethtypes.Header,
+) (*ethtypes.Block, ethtypes.Receipts, error) {
+	sub, exists := s.subs[id]
+	if !exists {
+		return nil, nil, fmt.Errorf("%w: %s",
+			ErrBlockUnknownSubscription, id,
+		)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Eth.BlockFetchTimeout)
+	defer cancel()
+
+	hash := header.Hash()
+	for {
+		block, receipts, err := sub.FetchBlock(ctx, hash)
+		if !errors.Is(err, ethereum.NotFound) {
+			return block, receipts, err
+		}
+
+		// the node announced the header, but can't serve the body (yet)
+		select {
+		case <-ctx.Done():
+			return nil, nil, fmt.Errorf("%w: %w", err, ctx.Err())
+		case <-time.After(blockFetchRetryInterval):
+		}
+	}
+}
+
+func validateBlock(
+	header *ethtypes.Header,
+	block *ethtypes.Block,
+	receipts ethtypes.Receipts,
+) (reason string, err error) {
+	if hash := block.Hash(); hash != header.Hash() {
+		return failureReasonBlockHash, fmt.Errorf("%w: %s != %s",
+			ErrBlockHashMismatch, hash, header.Hash(),
+		)
+	}
+
+	if root := ethtypes.DeriveSha(block.Transactions(), newTrieHasher()); root != header.TxHash {
+		return failureReasonTransactionsRoot, fmt.Errorf("%w: %s != %s",
+			ErrBlockTransactionsRoot, root, header.TxHash,
+		)
+	}
+
+	if len(receipts) != len(block.Transactions()) {
+		return failureReasonReceiptsCount, fmt.Errorf("%w: %d != %d",
+			ErrBlockReceiptsCount, len(receipts), len(block.Transactions()),
+		)
+	}
+
+	if root := ethtypes.DeriveSha(receipts, newTrieHasher()); root != header.ReceiptHash {
+		return failureReasonReceiptsRoot, fmt.Errorf("%w: %s != %s",
+			ErrBlockReceiptsRoot, root, header.ReceiptHash,
+		)
+	}
+
+	var gasUsed uint64
+	for _, r := range receipts {
+		gasUsed += r.GasUsed
+	}
+	if gasUsed != header.GasUsed {
+		return failureReasonGasUsed, fmt.Errorf("%w: %d != %d",
+			ErrBlockGasUsedMismatch, gasUsed, header.GasUsed,
+		)
+	}
+	if len(receipts) > 0 {
+		if cumulative := receipts[len(receipts)-1].CumulativeGasUsed; cumulative != header.GasUsed {
+			return failureReasonGasUsed, fmt.Errorf("%w: %d != %d",
+				ErrBlockGasUsedMismatch, cumulative, header.GasUsed,
+			)
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,143 @@
+package server_test
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/flashbots/node-monitor/server"
+	"gotest.tools/assert"
+)
+
+// validBlock returns the header of the block with two transfers, along with
+// the block's body and receipts that match it.
+func validBlock() (*ethtypes.Header, ethtypes.Transactions, ethtypes.Receipts) {
+	txs := ethtypes.Transactions{
+		ethtypes.NewTx(&ethtypes.LegacyTx{Nonce: 0, Gas: 21000, GasPrice: big.NewInt(1), Value: big.NewInt(1)}),
+		ethtypes.NewTx(&ethtypes.LegacyTx{Nonce: 1, Gas: 21000, GasPrice: big.NewInt(1), Value: big.NewInt(1)}),
+	}
+	receipts := ethtypes.Receipts{
+		{Status: ethtypes.ReceiptStatusSuccessful, GasUsed: 21000, CumulativeGasUsed: 21000, Logs: []*ethtypes.Log{}},
+		{Status: ethtypes.ReceiptStatusSuccessful, GasUsed: 21000, CumulativeGasUsed: 42000, Logs: []*ethtypes.Log{}},
+	}
+	header := &ethtypes.Header{
+		Number:      big.NewInt(101),
+		GasLimit:    30_000_000,
+		GasUsed:     42000,
+		TxHash:      ethtypes.DeriveSha(txs, trie.NewStackTrie(nil)),
+		ReceiptHash: ethtypes.DeriveSha(receipts, trie.NewStackTrie(nil)),
+	}
+	return header, txs, receipts
+}
+
+func TestValidateBlock(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		tamper func(header, served *ethtypes.Header, txs *ethtypes.Transactions, receipts *ethtypes.Receipts)
+		reason string
+		err    error
+	}{
+		{
+			name:   "valid",
+			tamper: func(_, _ *ethtypes.Header, _ *ethtypes.Transactions, _ *ethtypes.Receipts) {},
+		},
+		{
+			name: "block hash",
+			tamper: func(_, served *ethtypes.Header, _ *ethtypes.Transactions, _ *ethtypes.Receipts) {
+				served.Extra = []byte("other")
+			},
+			reason: "block_hash",
+			err:    server.ErrBlockHashMismatch,
+		},
+		{
+			name: "transactions root",
+			tamper: func(_, _ *ethtypes.Header, txs *ethtypes.Transactions, _ *ethtypes.Receipts) {
+				*txs = (*txs)[:1]
+			},
+			reason: "transactions_root",
+			err:    server.ErrBlockTransactionsRoot,
+		},
+		{
+			name: "receipts count",
+			tamper: func(_, _ *ethtypes.Header, _ *ethtypes.Transactions, receipts *ethtypes.Receipts) {
+				*receipts = (*receipts)[:1]
+			},
+			reason: "receipts_count",
+			err:    server.ErrBlockReceiptsCount,
+		},
+		{
+			name: "receipts root",
+			tamper: func(_, _ *ethtypes.Header, _ *ethtypes.Transactions, receipts *ethtypes.Receipts) {
+				(*receipts)[1].Status = ethtypes.ReceiptStatusFailed
+			},
+			reason: "receipts_root",
+			err:    server.ErrBlockReceiptsRoot,
+		},
+		{
+			name: "gas used",
+			tamper: func(_, _ *ethtypes.Header, _ *ethtypes.Transactions, receipts *ethtypes.Receipts) {
+				// the gas used by the transaction is not a part of the receipts root
+				(*receipts)[1].GasUsed = 20000
+			},
+			reason: "gas_used",
+			err:    server.ErrBlockGasUsedMismatch,
+		},
+		{
+			name: "cumulative gas used",
+			tamper: func(header, served *ethtypes.Header, _ *ethtypes.Transactions, receipts *ethtypes.Receipts) {
+				(*receipts)[1].CumulativeGasUsed = 40000
+				header.ReceiptHash = ethtypes.DeriveSha(*receipts, trie.NewStackTrie(nil))
+				served.ReceiptHash = header.ReceiptHash
+			},
+			reason: "gas_used",
+			err:    server.ErrBlockGasUsedMismatch,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			header, txs, receipts := validBlock()
+			served := ethtypes.CopyHeader(header)
+			tc.tamper(header, served, &txs, &receipts)
+			block := ethtypes.NewBlockWithHeader(served).WithBody(txs, nil)
+
+			reason, err := server.ValidateBlock(header, block, receipts)
+			assert.Equal(t, tc.reason, reason)
+			if tc.err == nil {
+				assert.NilError(t, err)
+			} else {
+				assert.Assert(t, errors.Is(err, tc.err), "%v", err)
+			}
+		})
+	}
+}
+
+func TestTrieHasher(t *testing.T) {
+	// the roots must match the ones of go-ethereum's trie (that the monitor
+	// doesn't link in), including the ones of the blocks with more than 128
+	// transactions (where the keys' lengths differ)
+	for _, count := range []int{0, 1, 2, 16, 127, 128, 129, 300} {
+		txs := make(ethtypes.Transactions, count)
+		for idx := range txs {
+			txs[idx] = ethtypes.NewTx(&ethtypes.LegacyTx{
+				Nonce:    uint64(idx),
+				Gas:      21000,
+				GasPrice: big.NewInt(1),
+				Value:    big.NewInt(int64(idx)),
+			})
+		}
+		assert.Equal(t,
+			ethtypes.DeriveSha(txs, trie.NewStackTrie(nil)),
+			ethtypes.DeriveSha(txs, server.NewTrieHasher()),
+			"%d transactions", count,
+		)
+	}
+
+	// the small nodes are embedded into their parents
+	hasher, reference := server.NewTrieHasher(), trie.NewStackTrie(nil)
+	for _, key := range []string{"aa", "ab", "ba", "ca"} {
+		assert.NilError(t, hasher.Update([]byte(key), []byte{1}))
+		assert.NilError(t, reference.Update([]byte(key), []byte{1}))
+	}
+	assert.Equal(t, reference.Hash(), hasher.Hash())
+}
@@ -0,0 +1,134 @@
+// Package sntp implements the client side of the simple network time protocol
+// (rfc 4330), just enough to learn the offset of the local clock.
+package sntp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	DefaultPort = "123"
+
+	packetSize = 48
+
+	// ntpEpochOffset is the count of seconds between the ntp epoch (1900) and
+	// the unix one (1970)
+	ntpEpochOffset = 2208988800
+
+	modeClient = 3
+	modeServer = 4
+	version    = 4
+)
+
+var (
+	ErrKissOfDeath         = errors.New("sntp server sent kiss-of-death")
+	ErrUnexpectedMode      = errors.New("unexpected sntp response mode")
+	ErrUnexpectedOriginate = errors.New("sntp response does not match the request")
+	ErrUnexpectedSize      = errors.New("unexpected sntp response size")
+	ErrUnsynchronised      = errors.New("sntp server clock is not synchronised")
+)
+
+// Response is what the server told about its time.
+type Response struct {
+	// Offset is how much the local clock must be adjusted by to match the
+	// server's one (i.e. positive when the local clock is behind).
+	Offset time.Duration
+
+	// RTT is the round-trip time to the server (without its processing).
+	RTT time.Duration
+
+	Stratum uint8
+	Time    time.Time // server's transmit time
+}
+
+// Query asks the server at the address (`host[:port]`) for its time.
+func Query(ctx context.Context, address string) (*Response, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, DefaultPort)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	request := make([]byte, packetSize)
+	request[0] = version<<3 | modeClient
+	t1 := time.Now()
+	originate := toNTPTime(t1)
+	binary.BigEndian.PutUint64(request[40:], originate)
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	response := make([]byte, packetSize+1)
+	n, err := conn.Read(response)
+	t4 := time.Now()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseResponse(response[:n], originate, t1, t4)
+}
+
+func parseResponse(response []byte, originate uint64, t1, t4 time.Time) (*Response, error) {
+	if len(response) != packetSize {
+		return nil, fmt.Errorf("%w: %d",
+			ErrUnexpectedSize, len(response),
+		)
+	}
+	if mode := response[0] & 0x07; mode != modeServer {
+		return nil, fmt.Errorf("%w: %d",
+			ErrUnexpectedMode, mode,
+		)
+	}
+	stratum := response[1]
+	if stratum == 0 {
+		return nil, fmt.Errorf("%w: %s",
+			ErrKissOfDeath, string(response[12:16]),
+		)
+	}
+	if leap := response[0] >> 6; leap == 3 {
+		return nil, ErrUnsynchronised
+	}
+	if binary.BigEndian.Uint64(response[24:]) != originate {
+		return nil, ErrUnexpectedOriginate
+	}
+
+	t2 := fromNTPTime(binary.BigEndian.Uint64(response[32:]))
+	t3 := fromNTPTime(binary.BigEndian.Uint64(response[40:]))
+
+	return &Response{
+		Offset:  (t2.Sub(t1) + t3.Sub(t4)) / 2,
+		RTT:     t4.Sub(t1) - t3.Sub(t2),
+		Stratum: stratum,
+		Time:    t3,
+	}, nil
+}
+
+// toNTPTime encodes the time as the 32.32 fixed-point seconds since 1900.
+func toNTPTime(t time.Time) uint64 {
+	nanos := uint64(t.UnixNano()) + ntpEpochOffset*uint64(time.Second)
+	seconds := nanos / uint64(time.Second)
+	fraction := (nanos % uint64(time.Second)) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+func fromNTPTime(ntp uint64) time.Time {
+	seconds := ntp >> 32
+	fraction := ntp & 0xffffffff
+	nanos := fraction * uint64(time.Second) >> 32
+	return time.Unix(int64(seconds)-ntpEpochOffset, int64(nanos))
+}
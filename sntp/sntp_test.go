@@ -0,0 +1,67 @@
+package sntp_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/flashbots/node-monitor/sntp"
+	"gotest.tools/assert"
+)
+
+// serve answers the sntp requests with the time shifted by the offset (or with
+// the kiss-of-death if stratum is 0).
+func serve(t *testing.T, offset time.Duration, stratum uint8) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	ntp := func(ts time.Time) uint64 {
+		nanos := uint64(ts.UnixNano()) + 2208988800*uint64(time.Second)
+		return nanos/uint64(time.Second)<<32 |
+			(nanos%uint64(time.Second))<<32/uint64(time.Second)
+	}
+
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			received := time.Now().Add(offset)
+
+			response := make([]byte, 48)
+			response[0] = 4<<3 | 4
+			response[1] = stratum
+			copy(response[12:16], "RATE")
+			copy(response[24:32], buf[40:48])
+			binary.BigEndian.PutUint64(response[32:], ntp(received))
+			binary.BigEndian.PutUint64(response[40:], ntp(time.Now().Add(offset)))
+			_, _ = conn.WriteTo(response, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestQuery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	res, err := sntp.Query(ctx, serve(t, 2*time.Second, 1))
+	assert.NilError(t, err)
+	assert.Assert(t, res.Offset > 1990*time.Millisecond && res.Offset < 2010*time.Millisecond, res.Offset)
+	assert.Assert(t, res.RTT >= 0 && res.RTT < 10*time.Millisecond, res.RTT)
+	assert.Equal(t, uint8(1), res.Stratum)
+
+	res, err = sntp.Query(ctx, serve(t, -time.Second, 2))
+	assert.NilError(t, err)
+	assert.Assert(t, res.Offset < -990*time.Millisecond && res.Offset > -1010*time.Millisecond, res.Offset)
+
+	_, err = sntp.Query(ctx, serve(t, 0, 0))
+	assert.Assert(t, errors.Is(err, sntp.ErrKissOfDeath))
+}
@@ -0,0 +1,84 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	BestPolicyLag      = "lag"
+	BestPolicyLatency  = "latency"
+	BestPolicyWeighted = "weighted"
+
+	// maxScoredLatency caps the latency that the best endpoint policies take
+	// into account (the unknown latency counts as the cap as well)
+	maxScoredLatency = time.Minute
+)
+
+var (
+	ErrBestPolicyUnknown        = errors.New("unknown best endpoint policy")
+	ErrBestPolicyInvalidWeights = errors.New("best endpoint policy weights must not be negative (and not all zero)")
+)
+
+// BestCandidate is the health of one of the group's (healthy) endpoints.
+type BestCandidate struct {
+	Lag       uint64
+	Staleness time.Duration
+	Latency   time.Duration // Infinity if not known
+}
+
+// BestPolicy scores the candidate for the group's best endpoint (the lower
+// score the better).
+type BestPolicy func(c BestCandidate) float64
+
+// NewBestPolicy returns the policy by its name:
+//
+//   - lag: the least lag, with the ties broken by the least latency;
+//   - latency: the least latency (of the endpoints that are not too far
+//     behind anyway);
+//   - weighted: the least weighted sum of the lag (in blocks), staleness and
+//     latency (in seconds).
+func NewBestPolicy(name string, lagWeight, stalenessWeight, latencyWeight float64) (BestPolicy, error) {
+	switch name {
+	case BestPolicyLag:
+		return func(c BestCandidate) float64 {
+			// one block of lag outweighs any latency
+			return float64(c.Lag)*maxScoredLatency.Seconds() + scoredLatency(c)
+		}, nil
+
+	case BestPolicyLatency:
+		return scoredLatency, nil
+
+	case BestPolicyWeighted:
+		weights := []float64{lagWeight, stalenessWeight, latencyWeight}
+		var total float64
+		for _, w := range weights {
+			if w < 0 || math.IsNaN(w) {
+				return nil, fmt.Errorf("%w: %v",
+					ErrBestPolicyInvalidWeights, weights,
+				)
+			}
+			total += w
+		}
+		if total == 0 {
+			return nil, fmt.Errorf("%w: %v",
+				ErrBestPolicyInvalidWeights, weights,
+			)
+		}
+		return func(c BestCandidate) float64 {
+			return lagWeight*float64(c.Lag) +
+				stalenessWeight*c.Staleness.Seconds() +
+				latencyWeight*scoredLatency(c)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s",
+		ErrBestPolicyUnknown, name,
+	)
+}
+
+func scoredLatency(c BestCandidate) float64 {
+	return min(c.Latency, maxScoredLatency).Seconds()
+}
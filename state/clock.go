@@ -0,0 +1,106 @@
+package state
+
+import (
+	"sync"
+	"time"
+
+	"github.com/flashbots/node-monitor/utils"
+)
+
+// ClockOffset is the latest outcome of the (s)ntp probe.
+type ClockOffset struct {
+	Offset    time.Duration
+	RTT       time.Duration
+	Stratum   uint8
+	Error     string
+	Timestamp time.Time
+}
+
+// RegisterClockOffset records the latest outcome of the (s)ntp probe against
+// the server.
+func (s *State) RegisterClockOffset(server string, offset ClockOffset) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.clockOffsets[server] = &offset
+}
+
+func (s *State) IterateClockOffsetsRO(
+	do func(server string, offset ClockOffset),
+) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	for server, offset := range s.clockOffsets {
+		do(server, *offset)
+	}
+}
+
+// headerTimeSkew tracks how early (or late) the recent blocks arrive compared
+// to their header timestamps.
+type headerTimeSkew struct {
+	deltas   *utils.BlockRing[time.Duration]
+	negative bool // whether the skew was negative the last time around
+
+	mx sync.Mutex
+}
+
+func newHeaderTimeSkew(blocks int) *headerTimeSkew {
+	if blocks <= 0 {
+		return nil
+	}
+	return &headerTimeSkew{
+		deltas: utils.NewBlockRing[time.Duration](blocks),
+	}
+}
+
+// RecordHeaderTime remembers the difference between the block's receive time
+// and its header timestamp (only the first arrival of the block counts).  It
+// tells when the skew has just turned negative (see HeaderTimeSkew).
+func (e *ELEndpoint) RecordHeaderTime(block uint64, ts time.Time, headerTime uint64) (
+	skew time.Duration, turnedNegative bool,
+) {
+	if e.skew == nil {
+		return 0, false
+	}
+
+	e.skew.mx.Lock()
+	defer e.skew.mx.Unlock()
+
+	e.skew.deltas.Put(block, ts.Sub(time.Unix(int64(headerTime), 0)))
+
+	skew, _ = e.skew.min()
+	turnedNegative = skew < 0 && !e.skew.negative
+	e.skew.negative = skew < 0
+	return skew, turnedNegative
+}
+
+// HeaderTimeSkew returns the smallest difference between the receive times of
+// the recent blocks and their header timestamps.  This is the monitor's clock
+// sanity check rather than the endpoint's property: the skew is the fastest
+// propagation of the block to the endpoint plus the error of the monitor's
+// clock, and the former can't be told apart from the latter.  Since the blocks
+// can not arrive before they were produced though, a negative skew means that
+// the monitor's clock is behind (or the block producers' ones are ahead).
+// Header timestamps have a resolution of one second, so are the smaller skews.
+func (e *ELEndpoint) HeaderTimeSkew() (skew time.Duration, blocks int, ok bool) {
+	if e.skew == nil {
+		return 0, 0, false
+	}
+
+	e.skew.mx.Lock()
+	defer e.skew.mx.Unlock()
+
+	skew, blocks = e.skew.min()
+	return skew, blocks, blocks > 0
+}
+
+func (s *headerTimeSkew) min() (skew time.Duration, blocks int) {
+	s.deltas.Range(func(_ uint64, delta time.Duration) {
+		if blocks == 0 || delta < skew {
+			skew = delta
+		}
+		blocks++
+	})
+	return skew, blocks
+}
@@ -0,0 +1,56 @@
+package state_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/flashbots/node-monitor/config"
+	"github.com/flashbots/node-monitor/state"
+	"gotest.tools/assert"
+)
+
+func TestHeaderTimeSkew(t *testing.T) {
+	s, err := state.New(&config.Config{
+		Clock: config.Clock{
+			SkewBlocks: 2,
+		},
+		Eth: config.Eth{
+			GroupHeadPolicy: state.HeadPolicyMax,
+		},
+	})
+	assert.NilError(t, err)
+	assert.NilError(t, s.RegisterExecutionEndpoint("g", "a", state.ELEndpointOptions{
+		Kind:   state.EndpointKindInternal,
+		Weight: 1,
+	}))
+	e := s.ExecutionGroup("g").Endpoint("a")
+	header := uint64(1700000000)
+	at := func(offset time.Duration) time.Time {
+		return time.Unix(int64(header), 0).Add(offset)
+	}
+
+	skew, turnedNegative := e.RecordHeaderTime(100, at(time.Second), header)
+	assert.Equal(t, time.Second, skew)
+	assert.Assert(t, !turnedNegative)
+
+	// the monitor's clock falls behind, which is only reported once...
+	skew, turnedNegative = e.RecordHeaderTime(101, at(-2*time.Second), header)
+	assert.Equal(t, -2*time.Second, skew)
+	assert.Assert(t, turnedNegative)
+	_, turnedNegative = e.RecordHeaderTime(102, at(-time.Second), header)
+	assert.Assert(t, !turnedNegative)
+
+	// ...until the clock recovers (and the early blocks fall out of the window)
+	_, turnedNegative = e.RecordHeaderTime(103, at(time.Second), header)
+	assert.Assert(t, !turnedNegative)
+	skew, turnedNegative = e.RecordHeaderTime(104, at(time.Second), header)
+	assert.Equal(t, time.Second, skew)
+	assert.Assert(t, !turnedNegative)
+	_, turnedNegative = e.RecordHeaderTime(105, at(-time.Second), header)
+	assert.Assert(t, turnedNegative)
+
+	skew, blocks, ok := e.HeaderTimeSkew()
+	assert.Assert(t, ok)
+	assert.Equal(t, 2, blocks)
+	assert.Equal(t, -time.Second, skew)
+}
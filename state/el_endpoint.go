@@ -2,60 +2,128 @@ package state
 
 import (
 	"math/big"
-	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/flashbots/node-monitor/config"
+	"github.com/flashbots/node-monitor/utils"
+)
+
+type EndpointKind string
+
+const (
+	EndpointKindInternal EndpointKind = "internal"
+	EndpointKindExternal EndpointKind = "external"
 )
 
+// ELEndpointOptions are the static properties of an execution endpoint.
+type ELEndpointOptions struct {
+	// Kind tells whether the endpoint is operated by us or by a provider.
+	Kind EndpointKind
+
+	// ReferenceOnly endpoints contribute to the latency statistics, but are
+	// not taken into account when deciding on the group's head.
+	ReferenceOnly bool
+
+	// Weight is the endpoint's trust when the group uses weighted head policy.
+	Weight float64
+}
+
 type ELEndpoint struct {
 	name string
 
-	highestBlock     *big.Int
-	highestBlockTime time.Time
+	kind          EndpointKind
+	referenceOnly bool
+	weight        float64
+
+	// all the mutable state is updated atomically, so that concurrent
+	// subscribers and readers never block each other
+	head          atomic.Pointer[endpointHead]
+	lastLatency   atomic.Pointer[time.Duration]
+	skippedBlocks atomic.Uint64
+	engineStatus  atomic.Pointer[EngineStatus]
+
+	latencies   []*utils.WindowedQuantileSketch
+	leaderboard *leaderboard
+	skew        *headerTimeSkew // nil if not tracked
+}
 
-	mx sync.RWMutex
+// endpointHead is the highest block of the endpoint and the time when it was
+// received (they are swapped together).
+type endpointHead struct {
+	number uint64
+	ts     time.Time
 }
 
-func newELEndpoint(name string) *ELEndpoint {
-	return &ELEndpoint{
+func newELEndpoint(cfg *config.Config, name string, opts ELEndpointOptions) *ELEndpoint {
+	e := &ELEndpoint{
 		name: name,
 
-		highestBlock:     big.NewInt(0),
-		highestBlockTime: time.Time{},
+		kind:          opts.Kind,
+		referenceOnly: opts.ReferenceOnly,
+		weight:        opts.Weight,
+
+		latencies:   newLatencySketches(cfg.Stats.Windows),
+		leaderboard: newLeaderboard(cfg.Leaderboard.Window),
+		skew:        newHeaderTimeSkew(cfg.Clock.SkewBlocks),
 	}
+	e.head.Store(&endpointHead{})
+
+	return e
+}
+
+func (e *ELEndpoint) Kind() EndpointKind {
+	return e.kind
 }
 
 func (e *ELEndpoint) HighestBlock() *big.Int {
-	e.mx.RLock()
-	defer e.mx.RUnlock()
+	return big.NewInt(0).SetUint64(e.head.Load().number)
+}
 
-	return big.NewInt(0).Set(e.highestBlock)
+func (e *ELEndpoint) highestBlockNumber() uint64 {
+	return e.head.Load().number
 }
 
+// RegisterBlock updates the endpoint's highest block and returns the count of
+// the blocks that it skipped (i.e. jumped over without reporting them).
 func (e *ELEndpoint) RegisterBlock(
 	block *big.Int,
 	ts time.Time,
-) {
-	e.mx.RLock()
-	defer e.mx.RUnlock()
-
-	if cmp := e.highestBlock.Cmp(block); cmp == -1 {
-		e.mx.RUnlock()
-		e.mx.Lock()
-		if cmp := e.highestBlock.Cmp(block); cmp == -1 {
-			e.highestBlock = new(big.Int).Set(block)
-			e.highestBlockTime = ts
+) (skipped uint64) {
+	if !block.IsUint64() {
+		return 0
+	}
+	next := &endpointHead{
+		number: block.Uint64(),
+		ts:     ts,
+	}
+
+	for {
+		prev := e.head.Load()
+		if next.number <= prev.number {
+			return 0
+		}
+		if e.head.CompareAndSwap(prev, next) {
+			if prev.number != 0 {
+				skipped = next.number - prev.number - 1
+				e.skippedBlocks.Add(skipped)
+			}
+			return skipped
 		}
-		e.mx.Unlock()
-		e.mx.RLock()
 	}
 }
 
-func (e *ELEndpoint) TimeSinceHighestBlock() (block int64, timeSince time.Duration) {
-	e.mx.RLock()
-	defer e.mx.RUnlock()
+// SkippedBlocks returns the total count of the blocks that the endpoint jumped
+// over without reporting them.
+func (e *ELEndpoint) SkippedBlocks() uint64 {
+	return e.skippedBlocks.Load()
+}
+
+func (e *ELEndpoint) TimeSinceHighestBlock(now time.Time) (block int64, timeSince time.Duration) {
+	head := e.head.Load()
 
-	b := e.highestBlock.Int64()
-	t := time.Since(e.highestBlockTime)
+	b := int64(head.number)
+	t := now.Sub(head.ts)
 
 	return b, t
 }
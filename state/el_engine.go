@@ -0,0 +1,38 @@
+package state
+
+import (
+	"slices"
+	"time"
+)
+
+// EngineStatus is the latest known state of the endpoint's engine api.
+type EngineStatus struct {
+	Reachable     bool
+	Authenticated bool
+	Capable       bool
+	Syncing       bool
+
+	Capabilities []string
+	Error        string
+	Latency      time.Duration
+	Timestamp    time.Time
+}
+
+func (e *ELEndpoint) RegisterEngineStatus(status EngineStatus) {
+	status.Capabilities = slices.Clone(status.Capabilities)
+	e.engineStatus.Store(&status)
+}
+
+// EngineStatus returns the latest engine api status (if the endpoint's engine
+// api is being probed).
+func (e *ELEndpoint) EngineStatus() (status EngineStatus, ok bool) {
+	latest := e.engineStatus.Load()
+	if latest == nil {
+		return EngineStatus{}, false
+	}
+
+	status = *latest
+	status.Capabilities = slices.Clone(status.Capabilities)
+
+	return status, true
+}
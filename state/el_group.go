@@ -2,10 +2,13 @@ package state
 
 import (
 	"fmt"
+	"maps"
 	"math/big"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/flashbots/node-monitor/config"
 	"github.com/flashbots/node-monitor/utils"
 )
 
@@ -18,51 +21,66 @@ const (
 )
 
 type ELGroup struct {
-	name string
+	cfg    *config.Config
+	name   string
+	policy HeadPolicy
 
-	endpoints map[string]*ELEndpoint
+	endpoints  map[string]*ELEndpoint
+	candidates []HeadCandidate // reused by updateHead (the policy reorders them)
 
-	blocks     *utils.SortedStringQueue
-	blockTimes map[string]time.Time
+	history *utils.BlockRing[*blockRecord]
 
-	highestBlock    *big.Int
-	highestBlockStr string
+	highestBlock   atomic.Uint64 // readable without taking the lock
+	finalisedBlock uint64
+
+	kindsDeltaBlock uint64
+	kindsDelta      time.Duration
+	kindsMx         sync.Mutex // the delta is updated under the read lock
 
 	mx sync.RWMutex
 }
 
-func newELGroup(name string) *ELGroup {
-	return &ELGroup{
-		name: name,
+// blockRecord keeps track of when the block was seen by the group for the
+// first time, as well as by the endpoints of each kind, and which endpoints
+// have seen it at all.
+type blockRecord struct {
+	firstSeen       time.Time
+	firstSeenByKind map[EndpointKind]time.Time
+	seenBy          map[string]struct{}
 
-		blocks:     utils.NewSortedStringQueue(maxHistoryBlocks),
-		blockTimes: make(map[string]time.Time, maxHistoryBlocks+1),
+	mx sync.Mutex // the maps are updated under the group's read lock
+}
+
+func newELGroup(cfg *config.Config, name string, policy HeadPolicy) *ELGroup {
+	return &ELGroup{
+		cfg:    cfg,
+		name:   name,
+		policy: policy,
 
-		highestBlock:    big.NewInt(0),
-		highestBlockStr: utils.Bigint2string(big.NewInt(0)),
+		history: utils.NewBlockRing[*blockRecord](maxHistoryBlocks),
 
 		endpoints: make(map[string]*ELEndpoint),
 	}
 }
 
-func (g *ELGroup) registerEndpoint(name string) error {
+func (g *ELGroup) registerEndpoint(name string, opts ELEndpointOptions) error {
 	id := utils.MakeELEndpointID(g.name, name)
 
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
 	if _, exists := g.endpoints[name]; exists {
 		return fmt.Errorf("%w: %s",
 			ErrExecutionEndpointDuplicateID, id,
 		)
 	}
-	g.endpoints[name] = newELEndpoint(id)
+	g.endpoints[name] = newELEndpoint(g.cfg, id, opts)
 
 	return nil
 }
 
 func (g *ELGroup) HighestBlock() *big.Int {
-	g.mx.RLock()
-	defer g.mx.RUnlock()
-
-	return big.NewInt(0).Set(g.highestBlock)
+	return big.NewInt(0).SetUint64(g.highestBlock.Load())
 }
 
 func (g *ELGroup) Endpoint(name string) *ELEndpoint {
@@ -72,52 +90,211 @@ func (g *ELGroup) Endpoint(name string) *ELEndpoint {
 	return g.endpoints[name]
 }
 
-func (g *ELGroup) RegisterBlockAndGetLatency(block *big.Int, ts time.Time) time.Duration {
-	g.mx.RLock()
-	defer g.mx.RUnlock()
-
-	blockStr := utils.Bigint2string(block)
+func (g *ELGroup) RegisterBlockAndGetLatency(
+	ename string,
+	block *big.Int,
+	ts time.Time,
+) time.Duration {
+	if !block.IsUint64() {
+		return Infinity
+	}
+	number := block.Uint64()
 
-	// update the highest block (if needed)
-	if blockStr > g.highestBlockStr {
-		g.mx.RUnlock()
+	// only the blocks ahead of the head can move it forward (and change the
+	// history), the rest just update their own records (under their own
+	// locks), so that the endpoints catching up with the head don't queue up
+	// behind each other
+	if number > g.highestBlock.Load() {
 		g.mx.Lock()
-		if blockStr > g.highestBlockStr {
-			delete(g.blockTimes, g.blocks.InsertAndPop(blockStr))
-			g.highestBlock = big.NewInt(0).Set(block)
-			g.highestBlockStr = blockStr
-			g.blockTimes[blockStr] = ts
-		}
-		g.mx.Unlock()
+		defer g.mx.Unlock()
+	} else {
 		g.mx.RLock()
+		defer g.mx.RUnlock()
+	}
+
+	e := g.endpoints[ename]
+
+	// remember when the block ahead of the head was seen for the first time
+	// (unless the head moved past it while we were waiting for the lock)
+	if number > g.highestBlock.Load() {
+		if _, seen := g.history.Get(number); !seen {
+			g.history.Put(number, &blockRecord{
+				firstSeen:       ts,
+				firstSeenByKind: make(map[EndpointKind]time.Time, 2),
+				seenBy:          make(map[string]struct{}, len(g.endpoints)),
+			})
+		}
+
+		g.updateHead()
+		g.finaliseBlocks(ts)
 	}
 
-	prevTS, exists := g.blockTimes[blockStr]
+	record, exists := g.history.Get(number)
 	if !exists {
 		// we don't want to report (false) statistics on obviously late blocks
 		return Infinity
 	}
 
-	return ts.Sub(prevTS)
+	record.mx.Lock()
+	defer record.mx.Unlock()
+
+	if _, seen := record.firstSeenByKind[e.kind]; !seen {
+		record.firstSeenByKind[e.kind] = ts
+		g.updateKindsDelta(number, record)
+	}
+
+	latency := ts.Sub(record.firstSeen)
+	if _, seen := record.seenBy[ename]; !seen {
+		record.seenBy[ename] = struct{}{}
+		e.leaderboard.registerSeen(ts, latency, g.cfg.Leaderboard.Threshold)
+	}
+
+	return latency
+}
+
+// updateHead moves the group's head forward according to the head policy.
+// If none of the endpoints is eligible to vote, all of them are considered.
+func (g *ELGroup) updateHead() {
+	candidates := g.candidates[:0]
+	for _, e := range g.endpoints {
+		if e.referenceOnly {
+			continue
+		}
+		candidates = append(candidates, HeadCandidate{
+			Block:  e.highestBlockNumber(),
+			Weight: e.weight,
+		})
+	}
+	if len(candidates) == 0 {
+		for _, e := range g.endpoints {
+			candidates = append(candidates, HeadCandidate{
+				Block:  e.highestBlockNumber(),
+				Weight: e.weight,
+			})
+		}
+	}
+	g.candidates = candidates
+
+	if head := g.policy(candidates); head > g.highestBlock.Load() {
+		g.highestBlock.Store(head)
+	}
 }
 
-func (g *ELGroup) TimeSinceHighestBlock() (block int64, timeSince time.Duration) {
+// finaliseBlocks counts the blocks that are far enough behind the head as
+// missed by the endpoints that did not report them.
+func (g *ELGroup) finaliseBlocks(ts time.Time) {
+	horizon := g.cfg.Leaderboard.Horizon
+	head := g.highestBlock.Load()
+	if head <= horizon {
+		return
+	}
+	target := head - horizon
+
+	// don't walk through the blocks we have no history of anyway
+	if target > maxHistoryBlocks && g.finalisedBlock < target-maxHistoryBlocks {
+		g.finalisedBlock = target - maxHistoryBlocks
+	}
+
+	for g.finalisedBlock < target {
+		g.finalisedBlock++
+
+		record, exists := g.history.Get(g.finalisedBlock)
+		if !exists {
+			continue
+		}
+		for ename, e := range g.endpoints {
+			if _, seen := record.seenBy[ename]; !seen {
+				e.leaderboard.registerMissed(ts)
+			}
+		}
+	}
+}
+
+// updateKindsDelta remembers the difference between the first arrivals of the
+// block at internal and external endpoints (once both of them saw it).
+func (g *ELGroup) updateKindsDelta(number uint64, record *blockRecord) {
+	internal, seenInternal := record.firstSeenByKind[EndpointKindInternal]
+	external, seenExternal := record.firstSeenByKind[EndpointKindExternal]
+	if !seenInternal || !seenExternal {
+		return
+	}
+
+	g.kindsMx.Lock()
+	defer g.kindsMx.Unlock()
+
+	if g.kindsDeltaBlock > number {
+		return
+	}
+
+	g.kindsDeltaBlock = number
+	g.kindsDelta = internal.Sub(external)
+}
+
+// FirstSeenByKind returns the time when the block was first received by any
+// of the group's endpoints of the given kind.
+func (g *ELGroup) FirstSeenByKind(block *big.Int, kind EndpointKind) (time.Time, bool) {
+	if !block.IsUint64() {
+		return time.Time{}, false
+	}
+
 	g.mx.RLock()
 	defer g.mx.RUnlock()
 
-	b := g.highestBlock.Int64()
-	t := time.Since(g.blockTimes[g.highestBlockStr])
+	record, exists := g.history.Get(block.Uint64())
+	if !exists {
+		return time.Time{}, false
+	}
+
+	record.mx.Lock()
+	defer record.mx.Unlock()
+
+	ts, seen := record.firstSeenByKind[kind]
+
+	return ts, seen
+}
+
+// InternalExternalDelta returns by how much the fastest internal endpoint was
+// late (positive) or early (negative) compared to the fastest external one on
+// the most recent block that was seen by both.
+func (g *ELGroup) InternalExternalDelta() (block int64, delta time.Duration, ok bool) {
+	g.kindsMx.Lock()
+	defer g.kindsMx.Unlock()
+
+	if g.kindsDeltaBlock == 0 {
+		return 0, 0, false
+	}
+
+	return int64(g.kindsDeltaBlock), g.kindsDelta, true
+}
+
+func (g *ELGroup) TimeSinceHighestBlock(now time.Time) (block int64, timeSince time.Duration) {
+	g.mx.RLock()
+	defer g.mx.RUnlock()
+
+	highest := g.highestBlock.Load()
+
+	var firstSeen time.Time
+	if record, exists := g.history.Get(highest); exists {
+		firstSeen = record.firstSeen
+	}
+
+	b := int64(highest)
+	t := now.Sub(firstSeen)
 
 	return b, t
 }
 
+// IterateEndpointsRO calls do for each of the group's endpoints.  The group's
+// lock is not held during the calls (the endpoints are safe for concurrent
+// use), so that slow readers don't hold the subscribers back.
 func (g *ELGroup) IterateEndpointsRO(
 	do func(name string, e *ELEndpoint),
 ) {
 	g.mx.RLock()
-	defer g.mx.RUnlock()
+	endpoints := maps.Clone(g.endpoints)
+	g.mx.RUnlock()
 
-	for name, e := range g.endpoints {
+	for name, e := range endpoints {
 		do(name, e)
 	}
 }
@@ -0,0 +1,123 @@
+package state_test
+
+import (
+	"fmt"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/flashbots/node-monitor/config"
+	"github.com/flashbots/node-monitor/state"
+	"gotest.tools/assert"
+)
+
+func newTestState(tb testing.TB, endpoints int) *state.State {
+	s, err := state.New(&config.Config{
+		Eth: config.Eth{
+			GroupHeadPolicy: state.HeadPolicyMax,
+		},
+		Leaderboard: config.Leaderboard{
+			Horizon:   8,
+			Threshold: 50 * time.Millisecond,
+			Window:    time.Hour,
+		},
+		Stats: config.Stats{
+			Windows: []time.Duration{time.Minute},
+		},
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for i := 0; i < endpoints; i++ {
+		err := s.RegisterExecutionEndpoint("bench", fmt.Sprintf("e%d", i), state.ELEndpointOptions{
+			Kind:   state.EndpointKindInternal,
+			Weight: 1,
+		})
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestRegisterBlockAndGetLatency(t *testing.T) {
+	s := newTestState(t, 2)
+	g := s.ExecutionGroup("bench")
+	start := time.Unix(1700000000, 0)
+
+	register := func(ename string, block int64, ts time.Time) time.Duration {
+		g.Endpoint(ename).RegisterBlock(big.NewInt(block), ts)
+		return g.RegisterBlockAndGetLatency(ename, big.NewInt(block), ts)
+	}
+
+	assert.Equal(t, time.Duration(0), register("e0", 100, start))
+	assert.Equal(t, 10*time.Millisecond, register("e1", 100, start.Add(10*time.Millisecond)))
+	assert.Equal(t, uint64(100), g.HighestBlock().Uint64())
+
+	// the blocks that fell out of the history are not measured
+	assert.Equal(t, time.Duration(0), register("e0", 5000, start.Add(time.Second)))
+	assert.Equal(t, state.Infinity, register("e1", 101, start.Add(time.Second)))
+	assert.Equal(t, uint64(4899), g.Endpoint("e0").SkippedBlocks())
+}
+
+// BenchmarkRegisterBlockAndGetLatency measures the group's bookkeeping per
+// header against the baseline of the endpoint's own one (that every header
+// goes through anyway).
+func BenchmarkRegisterBlockAndGetLatency(b *testing.B) {
+	for _, endpoints := range []int{10, 100, 500} {
+		for _, baseline := range []bool{true, false} {
+			name := fmt.Sprintf("endpoints=%d/group", endpoints)
+			if baseline {
+				name = fmt.Sprintf("endpoints=%d/baseline", endpoints)
+			}
+			b.Run(name, func(b *testing.B) {
+				s := newTestState(b, endpoints)
+				g := s.ExecutionGroup("bench")
+				names := make([]string, endpoints)
+				for i := range names {
+					names[i] = fmt.Sprintf("e%d", i)
+				}
+
+				start := time.Unix(1700000000, 0)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					// every endpoint reports every block, with a little jitter
+					block := big.NewInt(int64(1_000_000 + i/endpoints))
+					ename := names[i%endpoints]
+					ts := start.Add(time.Duration(i) * time.Millisecond)
+					g.Endpoint(ename).RegisterBlock(block, ts)
+					if !baseline {
+						g.RegisterBlockAndGetLatency(ename, block, ts)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkRegisterBlockAndGetLatencyParallel has the endpoints report the
+// blocks concurrently (the way the subscribers do), so that most of them
+// report the block the group has already moved its head to.
+func BenchmarkRegisterBlockAndGetLatencyParallel(b *testing.B) {
+	const endpoints = 100
+
+	s := newTestState(b, endpoints)
+	g := s.ExecutionGroup("bench")
+	start := time.Unix(1700000000, 0)
+
+	var next atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		ename := fmt.Sprintf("e%d", next.Add(1)%endpoints)
+		e := g.Endpoint(ename)
+		for i := 0; pb.Next(); i++ {
+			block := big.NewInt(int64(1_000_000 + i/8))
+			ts := start.Add(time.Duration(i) * time.Millisecond)
+			e.RegisterBlock(block, ts)
+			g.RegisterBlockAndGetLatency(ename, block, ts)
+		}
+	})
+}
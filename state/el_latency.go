@@ -0,0 +1,54 @@
+package state
+
+import (
+	"time"
+
+	"github.com/flashbots/node-monitor/utils"
+)
+
+// LatencyQuantiles are the estimated quantiles of the endpoint's new block
+// latency over a sliding window.
+type LatencyQuantiles struct {
+	Window    time.Duration
+	Count     uint64
+	Quantiles []float64
+}
+
+func (e *ELEndpoint) RecordLatency(ts time.Time, latency time.Duration) {
+	e.lastLatency.Store(&latency)
+	for _, w := range e.latencies {
+		w.Add(ts, latency.Seconds())
+	}
+}
+
+// LastLatency returns the latency of the most recently recorded block.
+func (e *ELEndpoint) LastLatency() (time.Duration, bool) {
+	latency := e.lastLatency.Load()
+	if latency == nil {
+		return 0, false
+	}
+	return *latency, true
+}
+
+// LatencyQuantiles returns the estimates of the requested quantiles for each
+// of the configured windows that end at the given moment.
+func (e *ELEndpoint) LatencyQuantiles(now time.Time, qs ...float64) []LatencyQuantiles {
+	res := make([]LatencyQuantiles, 0, len(e.latencies))
+	for _, w := range e.latencies {
+		quantiles, count := w.Quantiles(now, qs...)
+		res = append(res, LatencyQuantiles{
+			Window:    w.Window(),
+			Count:     count,
+			Quantiles: quantiles,
+		})
+	}
+	return res
+}
+
+func newLatencySketches(windows []time.Duration) []*utils.WindowedQuantileSketch {
+	res := make([]*utils.WindowedQuantileSketch, 0, len(windows))
+	for _, window := range windows {
+		res = append(res, utils.NewWindowedQuantileSketch(window))
+	}
+	return res
+}
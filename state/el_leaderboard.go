@@ -0,0 +1,78 @@
+package state
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/flashbots/node-monitor/utils"
+)
+
+// LeaderboardStats tell how often the endpoint was the first to see a block,
+// how often it saw one shortly after the first, and how often it missed one.
+type LeaderboardStats struct {
+	FirstSeen uint64
+	Missed    uint64
+	Seen      uint64
+	Within    uint64
+}
+
+type leaderboard struct {
+	firstSeen *utils.WindowedCounter
+	missed    *utils.WindowedCounter
+	seen      *utils.WindowedCounter
+	within    *utils.WindowedCounter
+
+	totalFirstSeen atomic.Uint64
+	totalMissed    atomic.Uint64
+	totalSeen      atomic.Uint64
+	totalWithin    atomic.Uint64
+}
+
+func newLeaderboard(window time.Duration) *leaderboard {
+	return &leaderboard{
+		firstSeen: utils.NewWindowedCounter(window),
+		missed:    utils.NewWindowedCounter(window),
+		seen:      utils.NewWindowedCounter(window),
+		within:    utils.NewWindowedCounter(window),
+	}
+}
+
+func (l *leaderboard) registerSeen(ts time.Time, latency, threshold time.Duration) {
+	l.seen.Add(ts, 1)
+	l.totalSeen.Add(1)
+
+	if latency == 0 {
+		l.firstSeen.Add(ts, 1)
+		l.totalFirstSeen.Add(1)
+	}
+	if latency <= threshold {
+		l.within.Add(ts, 1)
+		l.totalWithin.Add(1)
+	}
+}
+
+func (l *leaderboard) registerMissed(ts time.Time) {
+	l.missed.Add(ts, 1)
+	l.totalMissed.Add(1)
+}
+
+// LeaderboardStats returns the endpoint's stats over the leaderboard window
+// that ends at the given moment.
+func (e *ELEndpoint) LeaderboardStats(now time.Time) LeaderboardStats {
+	return LeaderboardStats{
+		FirstSeen: e.leaderboard.firstSeen.Sum(now),
+		Missed:    e.leaderboard.missed.Sum(now),
+		Seen:      e.leaderboard.seen.Sum(now),
+		Within:    e.leaderboard.within.Sum(now),
+	}
+}
+
+// LeaderboardTotals returns the endpoint's stats since the monitor started.
+func (e *ELEndpoint) LeaderboardTotals() LeaderboardStats {
+	return LeaderboardStats{
+		FirstSeen: e.leaderboard.totalFirstSeen.Load(),
+		Missed:    e.leaderboard.totalMissed.Load(),
+		Seen:      e.leaderboard.totalSeen.Load(),
+		Within:    e.leaderboard.totalWithin.Load(),
+	}
+}
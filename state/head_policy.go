@@ -0,0 +1,116 @@
+package state
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+const (
+	HeadPolicyMax      = "max"
+	HeadPolicyQuorum   = "quorum"
+	HeadPolicyWeighted = "weighted"
+)
+
+var (
+	ErrHeadPolicyUnknown          = errors.New("unknown group head policy")
+	ErrHeadPolicyInvalidQuorum    = errors.New("group head quorum must be positive")
+	ErrHeadPolicyInvalidThreshold = errors.New("group head weight threshold must be within (0, 1]")
+)
+
+// HeadCandidate is the highest block reported by one of the group's endpoints.
+type HeadCandidate struct {
+	Block  uint64
+	Weight float64
+}
+
+// HeadPolicy picks the group's canonical head out of the highest blocks that
+// its (non reference-only) endpoints have reported.  It may reorder the
+// candidates.
+type HeadPolicy func(candidates []HeadCandidate) uint64
+
+// NewHeadPolicy returns the policy by its name:
+//
+//   - max: the numerically highest block of any endpoint;
+//   - quorum: the highest block that at least `quorum` endpoints reached;
+//   - weighted: the highest block that the endpoints with at least
+//     `threshold` share of the total weight reached.
+func NewHeadPolicy(name string, quorum int, threshold float64) (HeadPolicy, error) {
+	switch name {
+	case HeadPolicyMax:
+		return func(candidates []HeadCandidate) uint64 {
+			var head uint64
+			for _, c := range candidates {
+				head = max(head, c.Block)
+			}
+			return head
+		}, nil
+
+	case HeadPolicyQuorum:
+		if quorum <= 0 {
+			return nil, fmt.Errorf("%w: %d",
+				ErrHeadPolicyInvalidQuorum, quorum,
+			)
+		}
+		return func(candidates []HeadCandidate) uint64 {
+			// can't require more than the group has
+			required := float64(min(quorum, len(candidates)))
+			return headWithSupport(candidates, countEndpoint, required)
+		}, nil
+
+	case HeadPolicyWeighted:
+		if threshold <= 0 || threshold > 1 || math.IsNaN(threshold) {
+			return nil, fmt.Errorf("%w: %f",
+				ErrHeadPolicyInvalidThreshold, threshold,
+			)
+		}
+		return func(candidates []HeadCandidate) uint64 {
+			var total float64
+			for _, c := range candidates {
+				total += c.Weight
+			}
+			return headWithSupport(candidates, countWeight, threshold*total)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s",
+		ErrHeadPolicyUnknown, name,
+	)
+}
+
+func countEndpoint(_ HeadCandidate) float64 {
+	return 1
+}
+
+func countWeight(c HeadCandidate) float64 {
+	return c.Weight
+}
+
+// headWithSupport returns the highest block that is reached by the endpoints
+// whose cumulative support is not less than the required one.  It sorts the
+// candidates in place.
+func headWithSupport(
+	candidates []HeadCandidate,
+	support func(c HeadCandidate) float64,
+	required float64,
+) uint64 {
+	if len(candidates) == 0 {
+		return 0
+	}
+
+	slices.SortFunc(candidates, func(a, b HeadCandidate) int {
+		return cmp.Compare(b.Block, a.Block) // descending
+	})
+
+	var total float64
+	for _, c := range candidates {
+		total += support(c)
+		if total >= required {
+			return c.Block
+		}
+	}
+
+	return candidates[len(candidates)-1].Block
+}
@@ -0,0 +1,39 @@
+package state_test
+
+import (
+	"testing"
+
+	"github.com/flashbots/node-monitor/state"
+	"gotest.tools/assert"
+)
+
+func TestHeadPolicy(t *testing.T) {
+	candidates := []state.HeadCandidate{
+		{Block: 101, Weight: 1},
+		{Block: 103, Weight: 1},
+		{Block: 100, Weight: 3},
+		{Block: 102, Weight: 1},
+	}
+
+	for _, tc := range []struct {
+		name      string
+		quorum    int
+		threshold float64
+		head      uint64
+	}{
+		{name: state.HeadPolicyMax, head: 103},
+		{name: state.HeadPolicyQuorum, quorum: 2, head: 102},
+		{name: state.HeadPolicyQuorum, quorum: 10, head: 100},
+		{name: state.HeadPolicyWeighted, threshold: 0.3, head: 102},
+		{name: state.HeadPolicyWeighted, threshold: 0.5, head: 101},
+		{name: state.HeadPolicyWeighted, threshold: 0.8, head: 100},
+	} {
+		policy, err := state.NewHeadPolicy(tc.name, tc.quorum, tc.threshold)
+		assert.NilError(t, err)
+
+		// the policies may reorder the candidates, but not lose any of them
+		copied := append([]state.HeadCandidate{}, candidates...)
+		assert.Equal(t, tc.head, policy(copied), "%s %d %f", tc.name, tc.quorum, tc.threshold)
+		assert.Equal(t, len(candidates), len(copied))
+	}
+}
@@ -3,10 +3,16 @@ package state
 import (
 	"errors"
 	"sync"
+
+	"github.com/flashbots/node-monitor/config"
 )
 
 type State struct {
+	cfg *config.Config
+
+	clockOffsets    map[string]*ClockOffset // ntp server -> latest offset
 	executionGroups map[string]*ELGroup
+	headPolicy      HeadPolicy
 
 	mx sync.RWMutex
 }
@@ -15,21 +21,34 @@ var (
 	ErrExecutionEndpointDuplicateID = errors.New("duplicate execution endpoint id")
 )
 
-func New() *State {
+func New(cfg *config.Config) (*State, error) {
+	headPolicy, err := NewHeadPolicy(
+		cfg.Eth.GroupHeadPolicy,
+		cfg.Eth.GroupHeadQuorum,
+		cfg.Eth.GroupHeadWeightThreshold,
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	return &State{
+		cfg: cfg,
+
+		clockOffsets:    make(map[string]*ClockOffset),
 		executionGroups: make(map[string]*ELGroup),
-	}
+		headPolicy:      headPolicy,
+	}, nil
 }
 
-func (s *State) RegisterExecutionEndpoint(group, name string) error {
+func (s *State) RegisterExecutionEndpoint(group, name string, opts ELEndpointOptions) error {
 	s.mx.Lock()
 	defer s.mx.Unlock()
 
 	if _, exists := s.executionGroups[group]; !exists {
-		s.executionGroups[group] = newELGroup(group)
+		s.executionGroups[group] = newELGroup(s.cfg, group, s.headPolicy)
 	}
 
-	if err := s.executionGroups[group].registerEndpoint(name); err != nil {
+	if err := s.executionGroups[group].registerEndpoint(name, opts); err != nil {
 		return err
 	}
 
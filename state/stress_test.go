@@ -0,0 +1,110 @@
+package state_test
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flashbots/node-monitor/state"
+	"gotest.tools/assert"
+)
+
+// The stress tests are meant to be run with `go test -race`.
+
+func TestConcurrentSubscribers(t *testing.T) {
+	const (
+		endpoints = 64
+		blocks    = 200
+	)
+
+	s := newTestState(t, endpoints)
+	g := s.ExecutionGroup("bench")
+
+	done := make(chan struct{})
+	readers := &sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				now := time.Now()
+				g.HighestBlock()
+				g.TimeSinceHighestBlock(now)
+				g.InternalExternalDelta()
+				g.IterateEndpointsRO(func(_ string, e *state.ELEndpoint) {
+					e.HighestBlock()
+					e.TimeSinceHighestBlock(now)
+					e.SkippedBlocks()
+					e.EngineStatus()
+					e.LatencyQuantiles(now, 0.5, 0.99)
+					e.LeaderboardStats(now)
+					e.LeaderboardTotals()
+				})
+			}
+		}()
+	}
+
+	writers := &sync.WaitGroup{}
+	for i := 0; i < endpoints; i++ {
+		writers.Add(1)
+		go func(i int) {
+			defer writers.Done()
+			ename := fmt.Sprintf("e%d", i)
+			e := g.Endpoint(ename)
+			for b := 1; b <= blocks; b++ {
+				// every odd endpoint reports only every other block
+				if i%2 == 1 && b%2 == 0 && b != blocks {
+					continue
+				}
+				block := big.NewInt(int64(b))
+				ts := time.Now()
+				e.RegisterBlock(block, ts)
+				if latency := g.RegisterBlockAndGetLatency(ename, block, ts); latency >= 0 {
+					e.RecordLatency(ts, latency)
+				}
+			}
+		}(i)
+	}
+
+	writers.Wait()
+	close(done)
+	readers.Wait()
+
+	assert.Equal(t, uint64(blocks), g.HighestBlock().Uint64())
+	g.IterateEndpointsRO(func(ename string, e *state.ELEndpoint) {
+		assert.Equal(t, uint64(blocks), e.HighestBlock().Uint64(), ename)
+		assert.Assert(t, e.LeaderboardTotals().Seen <= blocks, ename)
+	})
+}
+
+func TestConcurrentRegisterBlock(t *testing.T) {
+	const (
+		writers = 32
+		blocks  = 1000
+	)
+
+	s := newTestState(t, 1)
+	e := s.ExecutionGroup("bench").Endpoint("e0")
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for b := 1 + i; b <= blocks; b += writers {
+				e.RegisterBlock(big.NewInt(int64(b)), time.Now())
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, uint64(blocks), e.HighestBlock().Uint64())
+	assert.Assert(t, e.SkippedBlocks() < blocks)
+}
@@ -3,15 +3,24 @@ package subscriber
 import (
 	"context"
 	"errors"
+	"math/big"
 	"math/rand"
 	"net/url"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/flashbots/node-monitor/config"
 	"github.com/flashbots/node-monitor/logutils"
+	"github.com/flashbots/node-monitor/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -22,18 +31,34 @@ type ELEndpoint struct {
 	resubInterval time.Duration
 	uri           string
 
+	probe    *probe
+	recorder HeaderRecorder
+
 	client       *ethclient.Client
 	subscription ethereum.Subscription
+	subscribed   atomic.Bool // mirrors the subscription for the readers
+
+	mx sync.RWMutex
 
-	done    chan struct{}
+	done    chan struct{} // closed once unsubscribed
+	stop    sync.Once
 	headers chan *ethtypes.Header
 
 	handler func(ctx context.Context, gname, ename string, ts time.Time, header *ethtypes.Header)
+	watcher func(ctx context.Context, gname, ename string, subscribed bool, err error)
+	watched *bool // the state last reported to the watcher
 	ticker  *time.Ticker
 }
 
+// HeaderRecorder persists the received headers.  It must not block, as it is
+// called on the subscriber's hot path.
+type HeaderRecorder interface {
+	RecordHeader(group, name string, ts time.Time, header *ethtypes.Header) error
+}
+
 var (
 	ErrAlreadySubscribed = errors.New("already subscribed")
+	ErrNotConnected      = errors.New("not connected")
 )
 
 func NewELEndpoint(cfg *config.Config, group, name, uri string) (
@@ -44,6 +69,11 @@ func NewELEndpoint(cfg *config.Config, group, name, uri string) (
 		return nil, err
 	}
 
+	probe, err := newProbe(&cfg.Probe)
+	if err != nil {
+		return nil, err
+	}
+
 	return &ELEndpoint{
 		group: group,
 		name:  name,
@@ -51,6 +81,8 @@ func NewELEndpoint(cfg *config.Config, group, name, uri string) (
 		resubInterval: cfg.Eth.ResubscribeInterval,
 		uri:           parsed.String(),
 
+		probe: probe,
+
 		done:    make(chan struct{}),
 		headers: make(chan *ethtypes.Header),
 	}, nil
@@ -68,8 +100,107 @@ func (e *ELEndpoint) URI() string {
 	return e.uri
 }
 
+// IsSubscribed tells whether the endpoint currently streams the new headers.
+// It is safe to call concurrently with the subscription loop.
 func (e *ELEndpoint) IsSubscribed() bool {
-	return e.client != nil && e.subscription != nil
+	return e.subscribed.Load()
+}
+
+// FetchBlock retrieves the block and its receipts by hash.  It is safe to call
+// concurrently with the subscription loop.
+func (e *ELEndpoint) FetchBlock(ctx context.Context, hash common.Hash) (
+	*ethtypes.Block, ethtypes.Receipts, error,
+) {
+	client := e.getClient()
+	if client == nil {
+		return nil, nil, ErrNotConnected
+	}
+
+	block, err := client.BlockByHash(ctx, hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	receipts, err := client.BlockReceipts(ctx, rpc.BlockNumberOrHashWithHash(hash, false))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return block, receipts, nil
+}
+
+// HeaderByNumber retrieves the canonical header by its number.  It is safe to
+// call concurrently with the subscription loop.
+func (e *ELEndpoint) HeaderByNumber(ctx context.Context, number *big.Int) (
+	*ethtypes.Header, error,
+) {
+	client := e.getClient()
+	if client == nil {
+		return nil, ErrNotConnected
+	}
+
+	return client.HeaderByNumber(ctx, number)
+}
+
+// ChainID retrieves the id of the chain that the endpoint is on.  It is safe
+// to call concurrently with the subscription loop.
+func (e *ELEndpoint) ChainID(ctx context.Context) (*big.Int, error) {
+	client := e.getClient()
+	if client == nil {
+		return nil, ErrNotConnected
+	}
+
+	return client.ChainID(ctx)
+}
+
+// CallContext forwards the json-rpc call over the endpoint's connection.  It
+// is safe to call concurrently with the subscription loop.
+func (e *ELEndpoint) CallContext(
+	ctx context.Context,
+	result interface{},
+	method string,
+	args ...interface{},
+) error {
+	client := e.getClient()
+	if client == nil {
+		return ErrNotConnected
+	}
+
+	return client.Client().CallContext(ctx, result, method, args...)
+}
+
+// BatchCallContext forwards the batch of json-rpc calls over the endpoint's
+// connection.  It is safe to call concurrently with the subscription loop.
+func (e *ELEndpoint) BatchCallContext(ctx context.Context, batch []rpc.BatchElem) error {
+	client := e.getClient()
+	if client == nil {
+		return ErrNotConnected
+	}
+
+	return client.Client().BatchCallContext(ctx, batch)
+}
+
+// RecordTo makes the endpoint record all the headers it receives.  It must be
+// called before subscribing.
+func (e *ELEndpoint) RecordTo(recorder HeaderRecorder) {
+	e.recorder = recorder
+}
+
+// WatchSubscription makes the endpoint report when it (re-)subscribes to the
+// new headers, and when the subscription breaks or the attempt to subscribe
+// fails (only the changes of the state are reported).  It must be called
+// before subscribing.
+func (e *ELEndpoint) WatchSubscription(
+	watcher func(ctx context.Context, group, name string, subscribed bool, err error),
+) {
+	e.watcher = watcher
+}
+
+func (e *ELEndpoint) getClient() *ethclient.Client {
+	e.mx.RLock()
+	defer e.mx.RUnlock()
+
+	return e.client
 }
 
 func (e *ELEndpoint) Subscribe(
@@ -84,8 +215,14 @@ func (e *ELEndpoint) Subscribe(
 	go e.run(ctx)
 }
 
+// Unsubscribe stops both the subscriber and the prober.  It doesn't wait for
+// them to exit (e.g. for the in-flight probes to complete), and it's safe to
+// call it more than once.
 func (e *ELEndpoint) Unsubscribe() {
-	e.done <- struct{}{}
+	e.stop.Do(func() {
+		close(e.probe.done)
+		close(e.done)
+	})
 }
 
 func (e *ELEndpoint) subscribe(ctx context.Context) (success bool) {
@@ -93,45 +230,79 @@ func (e *ELEndpoint) subscribe(ctx context.Context) (success bool) {
 		panic("must never happen: double subscription attempt")
 	}
 
-	l := logutils.LoggerFromContext(ctx)
+	ctx, span := tracing.Tracer().Start(ctx, "subscriber.ELEndpoint.subscribe",
+		trace.WithAttributes(
+			attribute.String("endpoint_group", e.group),
+			attribute.String("endpoint_name", e.name),
+		),
+	)
+	defer span.End()
 
-	if e.client == nil {
-		client, err := ethclient.Dial(e.uri)
+	l := logutils.LoggerWithSpan(logutils.LoggerFromContext(ctx), span)
+
+	if e.getClient() == nil {
+		ctx, span := tracing.Tracer().Start(ctx, "subscriber.ELEndpoint.dial")
+		client, err := ethclient.DialContext(ctx, e.uri)
 		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "dial failed")
+			span.End()
 			l.Error("Failed to connect to execution endpoint websocket",
 				zap.String("endpoint_group", e.group),
 				zap.String("endpoint_name", e.name),
 				zap.Error(err),
 			)
+			e.watch(ctx, false, err)
 			return false
 		}
+		span.End()
 		l.Debug("Connected to execution endpoint websocket",
 			zap.String("endpoint_group", e.group),
 			zap.String("endpoint_name", e.name),
 		)
+		e.mx.Lock()
 		e.client = client
+		e.mx.Unlock()
 	}
 
 	if e.subscription == nil {
+		ctx, span := tracing.Tracer().Start(ctx, "subscriber.ELEndpoint.subscribeNewHead")
 		subscription, err := e.client.SubscribeNewHead(ctx, e.headers)
 		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "subscription failed")
+			span.End()
 			l.Error("Failed to subscribe to new headers",
 				zap.String("endpoint_group", e.group),
 				zap.String("endpoint_name", e.name),
 				zap.Error(err),
 			)
+			e.watch(ctx, false, err)
 			return false
 		}
+		span.End()
 		l.Info("Subscribed to execution endpoint's new headers",
 			zap.String("endpoint_group", e.group),
 			zap.String("endpoint_name", e.name),
 		)
 		e.subscription = subscription
+		e.subscribed.Store(true)
+		e.watch(ctx, true, nil)
 	}
 
 	return true
 }
 
+// watch reports the state of the subscription to the watcher, unless it's
+// the same as the one reported last time.
+func (e *ELEndpoint) watch(ctx context.Context, subscribed bool, err error) {
+	if e.watcher == nil || (e.watched != nil && *e.watched == subscribed) {
+		return
+	}
+	e.watched = &subscribed
+	e.watcher(ctx, e.group, e.name, subscribed, err)
+}
+
 func (e *ELEndpoint) run(ctx context.Context) {
 	l := logutils.LoggerFromContext(ctx)
 
@@ -178,12 +349,22 @@ func (e *ELEndpoint) run(ctx context.Context) {
 		for {
 			select {
 			case header := <-e.headers:
+				ts := time.Now()
 				l.Debug("Got header",
 					zap.Any("header", header),
 					zap.String("endpoint_group", e.group),
 					zap.String("endpoint_name", e.name),
 				)
-				e.handler(ctx, e.group, e.name, time.Now(), header)
+				if e.recorder != nil {
+					if err := e.recorder.RecordHeader(e.group, e.name, ts, header); err != nil {
+						l.Error("Failed to record the header",
+							zap.String("endpoint_group", e.group),
+							zap.String("endpoint_name", e.name),
+							zap.Error(err),
+						)
+					}
+				}
+				e.handler(ctx, e.group, e.name, ts, header)
 
 			case err := <-e.subscription.Err():
 				l.Warn("Execution endpoint subscription error",
@@ -191,8 +372,10 @@ func (e *ELEndpoint) run(ctx context.Context) {
 					zap.String("endpoint_name", e.name),
 					zap.Error(err),
 				)
+				e.subscribed.Store(false)
 				e.subscription.Unsubscribe()
 				e.subscription = nil
+				e.watch(ctx, false, err)
 				break loopEvent
 
 			case <-e.done:
@@ -200,6 +383,7 @@ func (e *ELEndpoint) run(ctx context.Context) {
 					zap.String("endpoint_group", e.group),
 					zap.String("endpoint_name", e.name),
 				)
+				e.subscribed.Store(false)
 				e.subscription.Unsubscribe()
 				return
 			}
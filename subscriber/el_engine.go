@@ -0,0 +1,258 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/flashbots/node-monitor/config"
+	"github.com/flashbots/node-monitor/logutils"
+	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	jwtSecretLength = 32
+)
+
+var (
+	ErrEngineInvalidJWTSecret  = errors.New("invalid engine api jwt secret (must be 32 hex-encoded bytes)")
+	ErrEngineMissingCapability = errors.New("engine api lacks required capability")
+)
+
+var (
+	// engineCapabilities are the methods the monitor announces to the endpoint
+	// when exchanging capabilities (same as a post-cancun consensus client).
+	engineCapabilities = []string{
+		"engine_exchangeCapabilities",
+		"engine_forkchoiceUpdatedV1",
+		"engine_forkchoiceUpdatedV2",
+		"engine_forkchoiceUpdatedV3",
+		"engine_getPayloadBodiesByHashV1",
+		"engine_getPayloadBodiesByRangeV1",
+		"engine_getPayloadV1",
+		"engine_getPayloadV2",
+		"engine_getPayloadV3",
+		"engine_newPayloadV1",
+		"engine_newPayloadV2",
+		"engine_newPayloadV3",
+	}
+)
+
+// EngineProbeResult is the outcome of a single engine api probe.
+type EngineProbeResult struct {
+	Reachable     bool
+	Authenticated bool
+	Capable       bool
+	Syncing       bool
+
+	Capabilities []string
+	Duration     time.Duration
+	Err          error
+}
+
+// ELEngineEndpoint periodically probes the authenticated engine api (authrpc)
+// of an execution client.
+type ELEngineEndpoint struct {
+	group string
+	name  string
+
+	interval             time.Duration
+	requiredCapabilities []string
+	secret               [jwtSecretLength]byte
+	uri                  string
+
+	done    chan struct{} // closed once stopped
+	stop    sync.Once
+	handler func(ctx context.Context, gname, ename string, ts time.Time, result *EngineProbeResult)
+}
+
+func NewELEngineEndpoint(cfg *config.Config, group, name, uri, secretPath string) (
+	*ELEngineEndpoint, error,
+) {
+	parsed, err := url.ParseRequestURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := readJWTSecret(secretPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ELEngineEndpoint{
+		group: group,
+		name:  name,
+
+		interval:             cfg.Eth.EngineProbeInterval,
+		requiredCapabilities: cfg.Eth.EngineRequiredCapabilities,
+		secret:               secret,
+		uri:                  parsed.String(),
+
+		done: make(chan struct{}),
+	}, nil
+}
+
+func readJWTSecret(path string) ([jwtSecretLength]byte, error) {
+	var secret [jwtSecretLength]byte
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return secret, err
+	}
+
+	raw := strings.TrimSpace(string(data))
+	decoded := common.FromHex(raw)
+	if len(decoded) != jwtSecretLength {
+		return secret, fmt.Errorf("%w: %s",
+			ErrEngineInvalidJWTSecret, path,
+		)
+	}
+	copy(secret[:], decoded)
+
+	return secret, nil
+}
+
+// newJWTAuth returns rpc authentication provider that signs every request
+// with a fresh HS256 token (as required by the engine api spec).
+//
+// It does the same as go-ethereum's node.NewJWTAuth, without pulling in the
+// whole node package (and its dependencies) just for that.
+func newJWTAuth(secret [jwtSecretLength]byte) rpc.HTTPAuth {
+	return func(h http.Header) error {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iat": &jwt.NumericDate{Time: time.Now()},
+		}).SignedString(secret[:])
+		if err != nil {
+			return err
+		}
+		h.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
+
+func (e *ELEngineEndpoint) Probe(
+	ctx context.Context,
+	handler func(ctx context.Context, gname, ename string, ts time.Time, result *EngineProbeResult),
+) {
+	if e.handler != nil {
+		panic("must never happen: double probing attempt")
+	}
+	e.handler = handler
+
+	go e.run(ctx)
+}
+
+// Stop stops the prober without waiting for the in-flight probe to complete.
+// It's safe to call it more than once.
+func (e *ELEngineEndpoint) Stop() {
+	e.stop.Do(func() {
+		close(e.done)
+	})
+}
+
+func (e *ELEngineEndpoint) run(ctx context.Context) {
+	l := logutils.LoggerFromContext(ctx)
+
+	// +/- 10% jitter
+	intInterval := int64(e.interval)
+	interval := time.Duration(
+		intInterval + rand.Int63n(intInterval/5+1) - intInterval/10,
+	).Round(time.Millisecond)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ts := time.Now()
+		result := e.probe(ctx)
+		if result.Err != nil {
+			l.Warn("Execution endpoint engine api probe failed",
+				zap.Bool("authenticated", result.Authenticated),
+				zap.Bool("reachable", result.Reachable),
+				zap.String("endpoint_group", e.group),
+				zap.String("endpoint_name", e.name),
+				zap.Error(result.Err),
+			)
+		}
+		e.handler(ctx, e.group, e.name, ts, result)
+
+		select {
+		case <-ticker.C:
+			// continue
+
+		case <-e.done:
+			l.Debug("Stopping execution endpoint engine api prober",
+				zap.String("endpoint_group", e.group),
+				zap.String("endpoint_name", e.name),
+			)
+			return
+		}
+	}
+}
+
+func (e *ELEngineEndpoint) probe(ctx context.Context) *EngineProbeResult {
+	ctx, cancel := context.WithTimeout(ctx, e.interval)
+	defer cancel()
+
+	start := time.Now()
+	result := &EngineProbeResult{}
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+
+	client, err := rpc.DialOptions(ctx, e.uri,
+		rpc.WithHTTPAuth(newJWTAuth(e.secret)),
+	)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer client.Close()
+
+	var capabilities []string
+	if err := client.CallContext(ctx, &capabilities, "engine_exchangeCapabilities", engineCapabilities); err != nil {
+		result.Err = err
+		var httpErr rpc.HTTPError
+		if errors.As(err, &httpErr) {
+			// the endpoint did answer, but either rejected our token or is not
+			// the engine api at all (e.g. the wrong port, or the proxy in front
+			// of it failing), so the token can't be deemed accepted
+			result.Reachable = true
+		}
+		return result
+	}
+	result.Reachable = true
+	result.Authenticated = true
+	result.Capabilities = capabilities
+
+	result.Capable = true
+	for _, required := range e.requiredCapabilities {
+		if !slices.Contains(capabilities, required) {
+			result.Capable = false
+			result.Err = fmt.Errorf("%w: %s",
+				ErrEngineMissingCapability, required,
+			)
+			break
+		}
+	}
+
+	progress, err := ethclient.NewClient(client).SyncProgress(ctx)
+	if err != nil {
+		result.Err = errors.Join(result.Err, err)
+		return result
+	}
+	result.Syncing = progress != nil
+
+	return result
+}
@@ -0,0 +1,194 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/flashbots/node-monitor/config"
+	"github.com/flashbots/node-monitor/logutils"
+	"go.uber.org/zap"
+)
+
+const (
+	ProbeMethodBlockNumber = "eth_blockNumber"
+	ProbeMethodCall        = "eth_call"
+	ProbeMethodEstimateGas = "eth_estimateGas"
+	ProbeMethodGetBalance  = "eth_getBalance"
+)
+
+var (
+	ErrProbeCallTargetNotConfigured = errors.New("eth_call probe requires a contract address to call")
+	ErrProbeInvalidAddress          = errors.New("invalid probe address")
+	ErrProbeMethodUnsupported       = errors.New("unsupported probe method")
+)
+
+var (
+	probeMethods = []string{
+		ProbeMethodBlockNumber,
+		ProbeMethodCall,
+		ProbeMethodEstimateGas,
+		ProbeMethodGetBalance,
+	}
+)
+
+type probe struct {
+	interval time.Duration
+	methods  []string
+
+	address  common.Address
+	callData []byte
+	callTo   *common.Address
+
+	done    chan struct{} // closed once the endpoint is unsubscribed
+	handler func(ctx context.Context, gname, ename, method string, duration time.Duration, err error)
+}
+
+func newProbe(cfg *config.Probe) (*probe, error) {
+	for _, addr := range []string{cfg.Address, cfg.CallTo} {
+		if addr != "" && !common.IsHexAddress(addr) {
+			return nil, fmt.Errorf("%w: %s",
+				ErrProbeInvalidAddress, addr,
+			)
+		}
+	}
+
+	p := &probe{
+		interval: cfg.Interval,
+		methods:  cfg.Methods,
+
+		address: common.HexToAddress(cfg.Address),
+
+		done: make(chan struct{}),
+	}
+
+	for _, method := range cfg.Methods {
+		if !slices.Contains(probeMethods, method) {
+			return nil, fmt.Errorf("%w: %s",
+				ErrProbeMethodUnsupported, method,
+			)
+		}
+	}
+
+	if cfg.CallTo != "" {
+		callTo := common.HexToAddress(cfg.CallTo)
+		p.callTo = &callTo
+	}
+	if cfg.CallData != "" {
+		callData, err := hexutil.Decode(cfg.CallData)
+		if err != nil {
+			return nil, err
+		}
+		p.callData = callData
+	}
+
+	if slices.Contains(p.methods, ProbeMethodCall) && p.callTo == nil {
+		return nil, ErrProbeCallTargetNotConfigured
+	}
+
+	return p, nil
+}
+
+func (p *probe) isEnabled() bool {
+	return p.interval > 0 && len(p.methods) > 0
+}
+
+// Probe starts the loop that periodically invokes the configured rpc methods
+// on the endpoint and reports their response times to the handler.
+func (e *ELEndpoint) Probe(
+	ctx context.Context,
+	handler func(ctx context.Context, gname, ename, method string, duration time.Duration, err error),
+) {
+	if !e.probe.isEnabled() {
+		return
+	}
+	if e.probe.handler != nil {
+		panic("must never happen: double probing attempt")
+	}
+	e.probe.handler = handler
+
+	go e.runProbes(ctx)
+}
+
+func (e *ELEndpoint) runProbes(ctx context.Context) {
+	l := logutils.LoggerFromContext(ctx)
+
+	// +/- 10% jitter to spread the probes of different endpoints in time
+	intInterval := int64(e.probe.interval)
+	interval := time.Duration(
+		intInterval + rand.Int63n(intInterval/5+1) - intInterval/10,
+	).Round(time.Millisecond)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			client := e.getClient()
+			if client == nil {
+				l.Debug("Skipping rpc probes on disconnected execution endpoint",
+					zap.String("endpoint_group", e.group),
+					zap.String("endpoint_name", e.name),
+				)
+				continue
+			}
+			for _, method := range e.probe.methods {
+				duration, err := e.probeMethod(ctx, client, method)
+				e.probe.handler(ctx, e.group, e.name, method, duration, err)
+			}
+
+		case <-e.probe.done:
+			l.Debug("Stopping execution endpoint rpc prober",
+				zap.String("endpoint_group", e.group),
+				zap.String("endpoint_name", e.name),
+			)
+			return
+		}
+	}
+}
+
+func (e *ELEndpoint) probeMethod(
+	ctx context.Context,
+	client *ethclient.Client,
+	method string,
+) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.probe.interval)
+	defer cancel()
+
+	var err error
+	start := time.Now()
+
+	switch method {
+	case ProbeMethodBlockNumber:
+		_, err = client.BlockNumber(ctx)
+
+	case ProbeMethodCall:
+		_, err = client.CallContract(ctx, ethereum.CallMsg{
+			To:   e.probe.callTo,
+			Data: e.probe.callData,
+		}, nil)
+
+	case ProbeMethodEstimateGas:
+		to := e.probe.callTo
+		if to == nil {
+			to = &e.probe.address
+		}
+		_, err = client.EstimateGas(ctx, ethereum.CallMsg{
+			From: e.probe.address,
+			To:   to,
+			Data: e.probe.callData,
+		})
+
+	case ProbeMethodGetBalance:
+		_, err = client.BalanceAt(ctx, e.probe.address, nil)
+	}
+
+	return time.Since(start), err
+}
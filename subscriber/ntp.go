@@ -0,0 +1,103 @@
+package subscriber
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/flashbots/node-monitor/config"
+	"github.com/flashbots/node-monitor/logutils"
+	"github.com/flashbots/node-monitor/sntp"
+	"go.uber.org/zap"
+)
+
+const (
+	ntpProbeTimeout = 5 * time.Second
+)
+
+// NTPServer periodically asks the (s)ntp server for its time to learn the
+// offset of the local clock.
+type NTPServer struct {
+	address  string
+	interval time.Duration
+
+	done    chan struct{} // closed once stopped
+	stop    sync.Once
+	handler func(ctx context.Context, server string, ts time.Time, res *sntp.Response, err error)
+}
+
+func NewNTPServer(cfg *config.Config, address string) *NTPServer {
+	return &NTPServer{
+		address:  address,
+		interval: cfg.Clock.NTPInterval,
+
+		done: make(chan struct{}),
+	}
+}
+
+func (n *NTPServer) Address() string {
+	return n.address
+}
+
+func (n *NTPServer) Probe(
+	ctx context.Context,
+	handler func(ctx context.Context, server string, ts time.Time, res *sntp.Response, err error),
+) {
+	if n.handler != nil {
+		panic("must never happen: double probing attempt")
+	}
+	n.handler = handler
+
+	go n.run(ctx)
+}
+
+// Stop stops the prober without waiting for the in-flight probe to complete.
+// It's safe to call it more than once.
+func (n *NTPServer) Stop() {
+	n.stop.Do(func() {
+		close(n.done)
+	})
+}
+
+func (n *NTPServer) run(ctx context.Context) {
+	l := logutils.LoggerFromContext(ctx)
+
+	// +/- 10% jitter
+	intInterval := int64(n.interval)
+	interval := time.Duration(
+		intInterval + rand.Int63n(intInterval/5+1) - intInterval/10,
+	).Round(time.Millisecond)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ts := time.Now()
+		res, err := n.probe(ctx)
+		if err != nil {
+			l.Warn("NTP probe failed",
+				zap.String("ntp_server", n.address),
+				zap.Error(err),
+			)
+		}
+		n.handler(ctx, n.address, ts, res, err)
+
+		select {
+		case <-ticker.C:
+			// continue
+
+		case <-n.done:
+			l.Debug("Stopping ntp prober",
+				zap.String("ntp_server", n.address),
+			)
+			return
+		}
+	}
+}
+
+func (n *NTPServer) probe(ctx context.Context) (*sntp.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, min(n.interval, ntpProbeTimeout))
+	defer cancel()
+
+	return sntp.Query(ctx, n.address)
+}
@@ -0,0 +1,16 @@
+package tracing
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/flashbots/node-monitor"
+)
+
+// Tracer returns the tracer of the globally registered provider (which is a
+// no-op one unless tracing was enabled).
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/flashbots/node-monitor/config"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+func NewTracerProvider(ctx context.Context, name string, cfg *config.Tracing) (
+	*trace.TracerProvider, error,
+) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(name)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(cfg.Endpoint),
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := trace.NewTracerProvider(
+		trace.WithBatcher(exporter),
+		trace.WithResource(res),
+		trace.WithSampler(trace.ParentBased(
+			trace.TraceIDRatioBased(cfg.SampleRatio),
+		)),
+	)
+
+	return provider, nil
+}
@@ -0,0 +1,209 @@
+// Package tui implements the interactive terminal dashboard that follows the
+// status of a running monitor (via its json api).
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/flashbots/node-monitor/config"
+	"golang.org/x/term"
+)
+
+const (
+	ansiClear      = "\x1b[H\x1b[2J"
+	ansiHideCursor = "\x1b[?25l"
+	ansiShowCursor = "\x1b[?25h"
+
+	keyBackspace = 0x7f
+	keyCtrlC     = 0x03
+	keyCtrlH     = 0x08
+	keyEnter     = '\r'
+	keyEscape    = 0x1b
+	keyNewline   = '\n'
+)
+
+var (
+	ErrUnexpectedSortKey = errors.New("unexpected sort key")
+)
+
+type Dashboard struct {
+	cfg    *config.Top
+	client *http.Client
+
+	view   *view
+	status *status
+	err    error
+	ts     time.Time
+}
+
+func New(cfg *config.Top) (*Dashboard, error) {
+	if !slices.Contains(SortKeys, cfg.Sort) {
+		return nil, fmt.Errorf("%w: %s", ErrUnexpectedSortKey, cfg.Sort)
+	}
+
+	return &Dashboard{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Interval},
+
+		view: &view{
+			sort:   cfg.Sort,
+			filter: cfg.Filter,
+			window: cfg.Window,
+		},
+	}, nil
+}
+
+// Run refreshes the dashboard until the context is done or the user quits.
+// The keys are read from the input only if it's a terminal.
+func (d *Dashboard) Run(ctx context.Context, in *os.File, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	raw := false
+	keys := make(chan byte)
+	if fd := int(in.Fd()); term.IsTerminal(fd) {
+		// raw mode makes the keys read as they are pressed
+		if state, err := term.MakeRaw(fd); err == nil {
+			defer term.Restore(fd, state) //nolint:errcheck
+			raw = true
+			go readKeys(ctx, in, keys)
+		}
+	}
+
+	fmt.Fprint(out, ansiHideCursor)
+	defer fmt.Fprint(out, ansiShowCursor)
+
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+
+	d.refresh(ctx)
+	d.draw(out, raw)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-ticker.C:
+			d.refresh(ctx)
+
+		case key := <-keys:
+			if quit := d.handleKey(key); quit {
+				return nil
+			}
+		}
+		d.draw(out, raw)
+	}
+}
+
+func (d *Dashboard) refresh(ctx context.Context) {
+	s, err := fetchStatus(ctx, d.client, d.cfg.URL)
+	d.err = err
+	if err == nil {
+		d.status = s
+		d.ts = time.Now()
+	}
+}
+
+// handleKey updates the view according to the pressed key and tells whether
+// the user wants to quit.
+func (d *Dashboard) handleKey(key byte) (quit bool) {
+	v := d.view
+
+	if v.editing {
+		switch key {
+		case keyEnter, keyNewline:
+			v.editing = false
+		case keyEscape:
+			v.editing = false
+			v.filter = ""
+		case keyBackspace, keyCtrlH:
+			if len(v.filter) > 0 {
+				v.filter = v.filter[:len(v.filter)-1]
+			}
+		case keyCtrlC:
+			return true
+		default:
+			if key >= 0x20 && key < 0x7f {
+				v.filter += string(key)
+			}
+		}
+		return false
+	}
+
+	switch key {
+	case 'q', keyCtrlC:
+		return true
+	case 's':
+		idx := slices.Index(SortKeys, v.sort)
+		v.sort = SortKeys[(idx+1)%len(SortKeys)]
+	case 'r':
+		v.reverse = !v.reverse
+	case '/':
+		v.editing = true
+	}
+	return false
+}
+
+func (d *Dashboard) draw(out io.Writer, raw bool) {
+	b := &strings.Builder{}
+
+	b.WriteString(ansiClear)
+	fmt.Fprintf(b, "%snode-monitor top%s  %s  sort: %s", ansiBold, ansiReset, d.cfg.URL, d.view.sort)
+	if d.view.reverse {
+		b.WriteString(" (reversed)")
+	}
+	if d.view.filter != "" || d.view.editing {
+		fmt.Fprintf(b, "  filter: %s", d.view.filter)
+		if d.view.editing {
+			b.WriteString("_")
+		}
+	}
+	b.WriteString("\n")
+	if d.err != nil {
+		fmt.Fprintf(b, "%serror: %s%s\n", ansiRed, d.err, ansiReset)
+	}
+	if !d.ts.IsZero() {
+		fmt.Fprintf(b, "updated at %s\n", d.ts.Format(time.TimeOnly))
+	}
+	b.WriteString("\n")
+
+	if d.status != nil {
+		b.WriteString(render(d.status, d.view))
+		b.WriteString("\n")
+	}
+	if raw {
+		b.WriteString("q quit  s sort  r reverse  / filter (enter to apply, esc to clear)\n")
+	}
+
+	frame := b.String()
+	if raw {
+		// the raw terminal does not return the carriage on new line
+		frame = strings.ReplaceAll(frame, "\n", "\r\n")
+	}
+	fmt.Fprint(out, frame)
+}
+
+func readKeys(ctx context.Context, in io.Reader, keys chan<- byte) {
+	buf := make([]byte, 16)
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, key := range buf[:n] {
+			select {
+			case keys <- key:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package tui_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flashbots/node-monitor/config"
+	"github.com/flashbots/node-monitor/tui"
+	"gotest.tools/assert"
+)
+
+const testStatus = `{
+  "groups": {
+    "mainnet": {
+      "highest_block": 102,
+      "time_since_last_block_s": 1.5,
+      "endpoints": {
+        "fast": {
+          "id": "mainnet:fast", "kind": "internal",
+          "highest_block": 102, "highest_block_lag": 0, "time_since_last_block_s": 1.5,
+          "subscribed": true, "last_latency_s": 0,
+          "latency_quantiles": {
+            "5m": {"count": 2, "quantiles_s": {"0.5": 0, "0.99": 0.001}},
+            "1h": {"count": 2, "quantiles_s": {"0.5": 0, "0.99": 0.001}}
+          }
+        },
+        "slow": {
+          "id": "mainnet:slow", "kind": "external",
+          "highest_block": 101, "highest_block_lag": 1, "time_since_last_block_s": 13.5,
+          "subscribed": false, "last_latency_s": 0.25,
+          "latency_quantiles": {
+            "5m": {"count": 1, "quantiles_s": {"0.5": 0.25, "0.99": 0.25}}
+          }
+        },
+        "other": {
+          "id": "mainnet:other", "kind": "internal",
+          "highest_block": 0, "highest_block_lag": 0, "time_since_last_block_s": 0
+        }
+      }
+    }
+  }
+}`
+
+func runDashboard(t *testing.T, cfg *config.Top) string {
+	t.Helper()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/status", r.URL.Path)
+		_, _ = w.Write([]byte(testStatus))
+	}))
+	defer api.Close()
+	cfg.URL = api.URL
+	cfg.Interval = 50 * time.Millisecond
+
+	// the pipe is not a terminal, so the dashboard does not read the keys
+	in, w, err := os.Pipe()
+	assert.NilError(t, err)
+	defer in.Close()
+	defer w.Close()
+
+	d, err := tui.New(cfg)
+	assert.NilError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Millisecond)
+	defer cancel()
+	out := &bytes.Buffer{}
+	assert.NilError(t, d.Run(ctx, in, out))
+
+	// the last frame
+	frames := strings.Split(out.String(), "\x1b[H\x1b[2J")
+	return frames[len(frames)-1]
+}
+
+func TestDashboard(t *testing.T) {
+	frame := runDashboard(t, &config.Top{Sort: tui.SortLag})
+
+	// the worst lag goes first, the endpoint without blocks has none
+	slow := strings.Index(frame, "mainnet:slow")
+	fast := strings.Index(frame, "mainnet:fast")
+	other := strings.Index(frame, "mainnet:other")
+	assert.Assert(t, slow >= 0 && fast >= 0 && other >= 0, frame)
+	assert.Assert(t, slow < fast && fast < other, frame)
+
+	// the shortest window's quantiles are shown
+	assert.Assert(t, strings.Contains(frame, "P50(5m)"), frame)
+	assert.Assert(t, strings.Contains(frame, "P99(5m)"), frame)
+	assert.Assert(t, strings.Contains(frame, "250ms"), frame)
+	assert.Assert(t, strings.Contains(frame, "down"), frame)
+
+	frame = runDashboard(t, &config.Top{Sort: tui.SortName, Filter: "FAST", Window: "1h"})
+	assert.Assert(t, strings.Contains(frame, "mainnet:fast"), frame)
+	assert.Assert(t, !strings.Contains(frame, "mainnet:slow"), frame)
+	assert.Assert(t, strings.Contains(frame, "P99(1h)"), frame)
+}
+
+func TestDashboardUnexpectedSortKey(t *testing.T) {
+	_, err := tui.New(&config.Top{Sort: "nope"})
+	assert.ErrorContains(t, err, "unexpected sort key")
+}
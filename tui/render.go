@@ -0,0 +1,319 @@
+package tui
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SortName      = "name"
+	SortHead      = "head"
+	SortLag       = "lag"
+	SortLatency   = "latency"
+	SortStaleness = "staleness"
+)
+
+// SortKeys are the columns the endpoints can be sorted by (in the order the
+// dashboard cycles through them).
+var SortKeys = []string{SortName, SortHead, SortLag, SortLatency, SortStaleness}
+
+const (
+	ansiBold   = "\x1b[1m"
+	ansiGreen  = "\x1b[32m"
+	ansiRed    = "\x1b[31m"
+	ansiReset  = "\x1b[0m"
+	ansiYellow = "\x1b[33m"
+)
+
+// view is what (and how) the dashboard shows.
+type view struct {
+	sort    string
+	reverse bool
+	filter  string
+	window  string
+
+	editing bool // the filter is being typed in
+}
+
+type cell struct {
+	text  string
+	color string
+}
+
+// render draws the status as the plain text with ansi colors.
+func render(s *status, v *view) string {
+	b := &strings.Builder{}
+
+	endpoints := make(map[string][]*endpointStatus, len(s.Groups))
+	for gname, g := range s.Groups {
+		for _, e := range g.Endpoints {
+			if v.filter == "" || strings.Contains(strings.ToLower(e.ID), strings.ToLower(v.filter)) {
+				endpoints[gname] = append(endpoints[gname], e)
+			}
+		}
+	}
+
+	groups := make([]string, 0, len(endpoints))
+	for gname := range endpoints {
+		groups = append(groups, gname)
+	}
+	slices.Sort(groups)
+
+	rows := [][]cell{{
+		{text: "GROUP"}, {text: "HEAD"}, {text: "LAST BLOCK"}, {text: "INT/EXT DELTA"},
+	}}
+	for _, gname := range groups {
+		g := s.Groups[gname]
+		delta := "-"
+		if g.InternalExternalLatencyDelta != nil {
+			delta = formatSeconds(*g.InternalExternalLatencyDelta)
+		}
+		rows = append(rows, []cell{
+			{text: gname},
+			{text: formatBlock(g.HighestBlock)},
+			{text: formatSince(g.HighestBlock, g.TimeSinceLastBlock)},
+			{text: delta},
+		})
+	}
+	writeTable(b, rows)
+	b.WriteString("\n")
+
+	window := v.window
+	if window == "" {
+		window = shortestWindow(s)
+	}
+	quantiles := quantileKeys(s, window)
+
+	header := []cell{
+		{text: "ENDPOINT"}, {text: "KIND"}, {text: "CONN"}, {text: "HEAD"},
+		{text: "LAG"}, {text: "LAST BLOCK"}, {text: "LATENCY"},
+	}
+	for _, q := range quantiles {
+		header = append(header, cell{text: fmt.Sprintf("P%s(%s)", formatQuantile(q), window)})
+	}
+	rows = [][]cell{header}
+
+	for _, gname := range groups {
+		es := endpoints[gname]
+		sortEndpoints(es, v.sort, v.reverse)
+		for _, e := range es {
+			row := []cell{
+				{text: e.ID},
+				{text: e.Kind},
+				connCell(e.Subscribed),
+				{text: formatBlock(e.HighestBlock)},
+				lagCell(e),
+				{text: formatSince(e.HighestBlock, e.TimeSinceLastBlock)},
+				latencyCell(e.LastLatency),
+			}
+			lq := e.LatencyQuantiles[window]
+			for _, q := range quantiles {
+				text := "-"
+				if lq != nil {
+					if value, ok := lq.Quantiles[q]; ok {
+						text = formatSeconds(value)
+					}
+				}
+				row = append(row, cell{text: text})
+			}
+			rows = append(rows, row)
+		}
+	}
+	writeTable(b, rows)
+
+	return b.String()
+}
+
+func writeTable(b *strings.Builder, rows [][]cell) {
+	widths := make([]int, 0)
+	for _, row := range rows {
+		for idx, c := range row {
+			if idx >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[idx] = max(widths[idx], len(c.text))
+		}
+	}
+
+	for ridx, row := range rows {
+		for idx, c := range row {
+			text := c.text
+			if idx < len(row)-1 {
+				text += strings.Repeat(" ", widths[idx]-len(c.text)+2)
+			}
+			switch {
+			case ridx == 0:
+				b.WriteString(ansiBold + text + ansiReset)
+			case c.color != "":
+				b.WriteString(c.color + text + ansiReset)
+			default:
+				b.WriteString(text)
+			}
+		}
+		b.WriteString("\n")
+	}
+}
+
+func sortEndpoints(es []*endpointStatus, key string, reverse bool) {
+	// the worst endpoints go first unless sorted by name
+	var compare func(a, b *endpointStatus) int
+	switch key {
+	case SortHead:
+		compare = func(a, b *endpointStatus) int {
+			return cmp.Compare(a.HighestBlock, b.HighestBlock)
+		}
+	case SortLag:
+		compare = func(a, b *endpointStatus) int {
+			return cmp.Compare(b.HighestBlockLag, a.HighestBlockLag)
+		}
+	case SortLatency:
+		compare = func(a, b *endpointStatus) int {
+			return cmp.Compare(latencyOf(b), latencyOf(a))
+		}
+	case SortStaleness:
+		compare = func(a, b *endpointStatus) int {
+			return cmp.Compare(stalenessOf(b), stalenessOf(a))
+		}
+	default:
+		compare = func(_, _ *endpointStatus) int { return 0 }
+	}
+
+	slices.SortStableFunc(es, func(a, b *endpointStatus) int {
+		res := compare(a, b)
+		if res == 0 {
+			res = cmp.Compare(a.ID, b.ID)
+		}
+		if reverse {
+			return -res
+		}
+		return res
+	})
+}
+
+func latencyOf(e *endpointStatus) float64 {
+	if e.LastLatency == nil {
+		return math.Inf(1)
+	}
+	return *e.LastLatency
+}
+
+func stalenessOf(e *endpointStatus) float64 {
+	if e.HighestBlock == 0 {
+		return math.Inf(1)
+	}
+	return e.TimeSinceLastBlock
+}
+
+func connCell(subscribed *bool) cell {
+	switch {
+	case subscribed == nil:
+		return cell{text: "-"}
+	case *subscribed:
+		return cell{text: "up", color: ansiGreen}
+	default:
+		return cell{text: "down", color: ansiRed}
+	}
+}
+
+func lagCell(e *endpointStatus) cell {
+	if e.HighestBlock == 0 {
+		return cell{text: "-"}
+	}
+	c := cell{text: strconv.FormatInt(e.HighestBlockLag, 10)}
+	if e.HighestBlockLag > 0 {
+		c.color = ansiYellow
+	}
+	return c
+}
+
+func latencyCell(latency *float64) cell {
+	if latency == nil {
+		return cell{text: "-"}
+	}
+	return cell{text: formatSeconds(*latency)}
+}
+
+func formatBlock(block int64) string {
+	if block == 0 {
+		return "-"
+	}
+	return strconv.FormatInt(block, 10)
+}
+
+func formatSince(block int64, seconds float64) string {
+	if block == 0 {
+		return "-"
+	}
+	return formatSeconds(seconds) + " ago"
+}
+
+func formatSeconds(seconds float64) string {
+	d := time.Duration(seconds * float64(time.Second))
+	if d >= time.Second || d <= -time.Second {
+		return d.Round(100 * time.Millisecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// formatQuantile renders the quantile as the percentile (e.g. `0.99` as `99`).
+func formatQuantile(q string) string {
+	value, err := strconv.ParseFloat(q, 64)
+	if err != nil {
+		return q
+	}
+	return strconv.FormatFloat(value*100, 'f', -1, 64)
+}
+
+func shortestWindow(s *status) string {
+	var (
+		shortest string
+		duration time.Duration
+	)
+	for _, g := range s.Groups {
+		for _, e := range g.Endpoints {
+			for window := range e.LatencyQuantiles {
+				d, err := time.ParseDuration(window)
+				if err != nil {
+					continue
+				}
+				if shortest == "" || d < duration {
+					shortest, duration = window, d
+				}
+			}
+		}
+	}
+	return shortest
+}
+
+// quantileKeys returns all the quantiles reported for the window (ordered
+// numerically).
+func quantileKeys(s *status, window string) []string {
+	keys := make(map[string]float64)
+	for _, g := range s.Groups {
+		for _, e := range g.Endpoints {
+			lq := e.LatencyQuantiles[window]
+			if lq == nil {
+				continue
+			}
+			for q := range lq.Quantiles {
+				if value, err := strconv.ParseFloat(q, 64); err == nil {
+					keys[q] = value
+				}
+			}
+		}
+	}
+
+	res := make([]string, 0, len(keys))
+	for q := range keys {
+		res = append(res, q)
+	}
+	slices.SortFunc(res, func(a, b string) int {
+		return cmp.Compare(keys[a], keys[b])
+	})
+	return res
+}
@@ -0,0 +1,72 @@
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrUnexpectedStatusCode = errors.New("unexpected status code")
+)
+
+// status mirrors the response of the monitor's /api/v1/status.
+type status struct {
+	Groups map[string]*groupStatus `json:"groups"`
+}
+
+type groupStatus struct {
+	HighestBlock       int64   `json:"highest_block"`
+	TimeSinceLastBlock float64 `json:"time_since_last_block_s"`
+
+	InternalExternalLatencyDelta *float64 `json:"internal_external_latency_delta_s"`
+
+	Endpoints map[string]*endpointStatus `json:"endpoints"`
+}
+
+type endpointStatus struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+
+	HighestBlock       int64   `json:"highest_block"`
+	HighestBlockLag    int64   `json:"highest_block_lag"`
+	TimeSinceLastBlock float64 `json:"time_since_last_block_s"`
+
+	Subscribed  *bool    `json:"subscribed"`
+	LastLatency *float64 `json:"last_latency_s"`
+
+	LatencyQuantiles map[string]*latencyQuantiles `json:"latency_quantiles"`
+}
+
+type latencyQuantiles struct {
+	Count     uint64             `json:"count"`
+	Quantiles map[string]float64 `json:"quantiles_s"`
+}
+
+func fetchStatus(ctx context.Context, client *http.Client, url string) (*status, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(url, "/")+"/api/v1/status", nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %d", ErrUnexpectedStatusCode, res.StatusCode)
+	}
+
+	s := &status{}
+	if err := json.NewDecoder(res.Body).Decode(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
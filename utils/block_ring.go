@@ -0,0 +1,56 @@
+package utils
+
+// BlockRing keeps the values associated with the most recent block numbers.
+// The slots are indexed by the block number modulo the ring's size, so both
+// lookups and insertions are O(1) and the older blocks are evicted implicitly.
+type BlockRing[T any] struct {
+	slots []blockRingSlot[T]
+}
+
+type blockRingSlot[T any] struct {
+	number uint64
+	used   bool
+	value  T
+}
+
+func NewBlockRing[T any](size int) *BlockRing[T] {
+	return &BlockRing[T]{
+		slots: make([]blockRingSlot[T], size),
+	}
+}
+
+// Put stores the value of the block unless its slot is already taken by the
+// same or a more recent block.  It returns false if the value wasn't stored.
+func (r *BlockRing[T]) Put(number uint64, value T) bool {
+	slot := &r.slots[number%uint64(len(r.slots))]
+	if slot.used && slot.number >= number {
+		return false
+	}
+
+	slot.number = number
+	slot.used = true
+	slot.value = value
+
+	return true
+}
+
+// Get returns the value of the block if it's still in the ring.
+func (r *BlockRing[T]) Get(number uint64) (T, bool) {
+	slot := &r.slots[number%uint64(len(r.slots))]
+	if !slot.used || slot.number != number {
+		var zero T
+		return zero, false
+	}
+
+	return slot.value, true
+}
+
+// Range calls the function for each of the blocks in the ring (in no
+// particular order).
+func (r *BlockRing[T]) Range(do func(number uint64, value T)) {
+	for idx := range r.slots {
+		if slot := &r.slots[idx]; slot.used {
+			do(slot.number, slot.value)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/flashbots/node-monitor/utils"
+	"gotest.tools/assert"
+)
+
+func TestBlockRing(t *testing.T) {
+	r := utils.NewBlockRing[string](4)
+	assert.Equal(t, true, r.Put(4, "4"))
+	assert.Equal(t, true, r.Put(3, "3"))
+	assert.Equal(t, true, r.Put(5, "5"))
+	assert.Equal(t, false, r.Put(5, "five"))
+
+	v, ok := r.Get(5)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "5", v)
+
+	// 8 evicts 4, but 0 can't evict it back
+	assert.Equal(t, true, r.Put(8, "8"))
+	assert.Equal(t, false, r.Put(0, "0"))
+	_, ok = r.Get(4)
+	assert.Equal(t, false, ok)
+	_, ok = r.Get(0)
+	assert.Equal(t, false, ok)
+
+	v, ok = r.Get(8)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "8", v)
+
+	values := make(map[uint64]string)
+	r.Range(func(number uint64, value string) {
+		values[number] = value
+	})
+	assert.DeepEqual(t, map[uint64]string{3: "3", 5: "5", 8: "8"}, values)
+}
+
+func BenchmarkBlockRing(b *testing.B) {
+	r := utils.NewBlockRing[int](1024)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Put(uint64(i), i)
+		r.Get(uint64(i / 2))
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var (
@@ -51,3 +52,16 @@ func MakeELEndpointID(group, name string) string {
 	}
 	return group + ":" + name
 }
+
+// FormatDuration renders the duration without the trailing zero units (e.g.
+// `1h` instead of `1h0m0s`), which is handy for the labels.
+func FormatDuration(d time.Duration) string {
+	str := d.String()
+	if strings.HasSuffix(str, "m0s") {
+		str = str[:len(str)-2]
+	}
+	if strings.HasSuffix(str, "h0m") {
+		str = str[:len(str)-2]
+	}
+	return str
+}
@@ -0,0 +1,17 @@
+package utils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/flashbots/node-monitor/utils"
+	"gotest.tools/assert"
+)
+
+func TestFormatDuration(t *testing.T) {
+	assert.Equal(t, "5m", utils.FormatDuration(5*time.Minute))
+	assert.Equal(t, "1h", utils.FormatDuration(time.Hour))
+	assert.Equal(t, "24h", utils.FormatDuration(24*time.Hour))
+	assert.Equal(t, "1h30m", utils.FormatDuration(90*time.Minute))
+	assert.Equal(t, "1m30s", utils.FormatDuration(90*time.Second))
+}
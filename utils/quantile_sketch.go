@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	sketchRelativeAccuracy = 0.01
+	sketchMinValue         = 1e-6
+	sketchSlots            = 60
+)
+
+var (
+	sketchGamma    = (1 + sketchRelativeAccuracy) / (1 - sketchRelativeAccuracy)
+	sketchLogGamma = math.Log(sketchGamma)
+)
+
+// sketch is a log-bucketed histogram that estimates quantiles of non-negative
+// values with a bounded relative error (similar to DDSketch).
+type sketch struct {
+	buckets map[int]uint64
+	zeros   uint64
+	count   uint64
+}
+
+func newSketch() *sketch {
+	return &sketch{
+		buckets: make(map[int]uint64),
+	}
+}
+
+func (s *sketch) add(value float64) {
+	s.count++
+	if value < sketchMinValue {
+		s.zeros++
+		return
+	}
+	s.buckets[int(math.Ceil(math.Log(value)/sketchLogGamma))]++
+}
+
+func (s *sketch) merge(other *sketch) {
+	s.count += other.count
+	s.zeros += other.zeros
+	for idx, cnt := range other.buckets {
+		s.buckets[idx] += cnt
+	}
+}
+
+func (s *sketch) quantile(q float64) float64 {
+	if s.count == 0 {
+		return math.NaN()
+	}
+
+	rank := uint64(math.Ceil(q * float64(s.count)))
+	if rank == 0 {
+		rank = 1
+	}
+	if rank <= s.zeros {
+		return 0
+	}
+
+	idxs := make([]int, 0, len(s.buckets))
+	for idx := range s.buckets {
+		idxs = append(idxs, idx)
+	}
+	slices.Sort(idxs)
+
+	cumulative := s.zeros
+	for _, idx := range idxs {
+		cumulative += s.buckets[idx]
+		if cumulative >= rank {
+			return 2 * math.Pow(sketchGamma, float64(idx)) / (sketchGamma + 1)
+		}
+	}
+
+	return 2 * math.Pow(sketchGamma, float64(idxs[len(idxs)-1])) / (sketchGamma + 1)
+}
+
+// WindowedQuantileSketch estimates quantiles of the values recorded over the
+// sliding time window.  The window is split into a fixed number of slots that
+// expire one by one, so the effective window length fluctuates by a slot.
+//
+// Time is always supplied by the caller, which makes the sketch usable with
+// the virtual time (e.g. when replaying recorded events).
+type WindowedQuantileSketch struct {
+	window   time.Duration
+	slotSize time.Duration
+
+	slots   []*sketch
+	slotIDs []int64
+
+	mx sync.Mutex
+}
+
+func NewWindowedQuantileSketch(window time.Duration) *WindowedQuantileSketch {
+	slotSize := window / sketchSlots
+	if slotSize <= 0 {
+		slotSize = 1
+	}
+
+	return &WindowedQuantileSketch{
+		window:   window,
+		slotSize: slotSize,
+
+		slots:   make([]*sketch, sketchSlots),
+		slotIDs: make([]int64, sketchSlots),
+	}
+}
+
+func (w *WindowedQuantileSketch) Window() time.Duration {
+	return w.window
+}
+
+func (w *WindowedQuantileSketch) Add(ts time.Time, value float64) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	slotID := ts.UnixNano() / int64(w.slotSize)
+	pos := int(slotID % sketchSlots)
+	if w.slots[pos] == nil || w.slotIDs[pos] != slotID {
+		w.slots[pos] = newSketch()
+		w.slotIDs[pos] = slotID
+	}
+	w.slots[pos].add(value)
+}
+
+// Quantiles returns the estimates of the requested quantiles over the window
+// that ends at the given moment, together with the count of the values.
+// Quantiles of an empty window are NaN.
+func (w *WindowedQuantileSketch) Quantiles(now time.Time, qs ...float64) (
+	quantiles []float64, count uint64,
+) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	current := now.UnixNano() / int64(w.slotSize)
+	merged := newSketch()
+	for pos, s := range w.slots {
+		if s == nil {
+			continue
+		}
+		if age := current - w.slotIDs[pos]; age < 0 || age >= sketchSlots {
+			continue
+		}
+		merged.merge(s)
+	}
+
+	quantiles = make([]float64, len(qs))
+	for idx, q := range qs {
+		quantiles[idx] = merged.quantile(q)
+	}
+
+	return quantiles, merged.count
+}
@@ -0,0 +1,36 @@
+package utils_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/flashbots/node-monitor/utils"
+	"gotest.tools/assert"
+)
+
+func TestWindowedQuantileSketch(t *testing.T) {
+	w := utils.NewWindowedQuantileSketch(time.Minute)
+	start := time.Unix(1700000000, 0)
+
+	qs, count := w.Quantiles(start, 0.5)
+	assert.Equal(t, uint64(0), count)
+	assert.Assert(t, math.IsNaN(qs[0]))
+
+	for i := 1; i <= 100; i++ {
+		w.Add(start.Add(time.Duration(i)*100*time.Millisecond), float64(i)/100)
+	}
+
+	now := start.Add(10 * time.Second)
+	qs, count = w.Quantiles(now, 0.5, 0.95, 1)
+	assert.Equal(t, uint64(100), count)
+	assert.Assert(t, math.Abs(qs[0]-0.5) <= 0.5*0.01, qs[0])
+	assert.Assert(t, math.Abs(qs[1]-0.95) <= 0.95*0.01, qs[1])
+	assert.Assert(t, math.Abs(qs[2]-1) <= 0.01, qs[2])
+
+	// the values expire as the window slides
+	_, count = w.Quantiles(start.Add(time.Minute+5*time.Second), 0.5)
+	assert.Assert(t, count < 100)
+	_, count = w.Quantiles(start.Add(2*time.Minute), 0.5)
+	assert.Equal(t, uint64(0), count)
+}
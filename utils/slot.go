@@ -0,0 +1,13 @@
+package utils
+
+import "time"
+
+// SlotStart returns the beginning of the slot that the timestamp belongs to.
+// Timestamps preceding the genesis are attributed to the genesis slot.
+func SlotStart(genesis time.Time, slotDuration time.Duration, ts time.Time) time.Time {
+	if slotDuration <= 0 || ts.Before(genesis) {
+		return genesis
+	}
+	slot := ts.Sub(genesis) / slotDuration
+	return genesis.Add(slot * slotDuration)
+}
@@ -0,0 +1,20 @@
+package utils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/flashbots/node-monitor/utils"
+	"gotest.tools/assert"
+)
+
+func TestSlotStart(t *testing.T) {
+	genesis := time.Unix(1606824023, 0)
+	slot := 12 * time.Second
+
+	assert.Equal(t, genesis, utils.SlotStart(genesis, slot, genesis))
+	assert.Equal(t, genesis, utils.SlotStart(genesis, slot, genesis.Add(-time.Hour)))
+	assert.Equal(t, genesis, utils.SlotStart(genesis, slot, genesis.Add(11*time.Second)))
+	assert.Equal(t, genesis.Add(slot), utils.SlotStart(genesis, slot, genesis.Add(12*time.Second)))
+	assert.Equal(t, genesis.Add(10*slot), utils.SlotStart(genesis, slot, genesis.Add(10*slot+time.Second)))
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	counterSlots = 60
+)
+
+// WindowedCounter sums up the values added over the sliding time window.  Same
+// as with WindowedQuantileSketch, the window expires slot by slot and the time
+// is supplied by the caller.
+type WindowedCounter struct {
+	window   time.Duration
+	slotSize time.Duration
+
+	slots   []uint64
+	slotIDs []int64
+
+	mx sync.Mutex
+}
+
+func NewWindowedCounter(window time.Duration) *WindowedCounter {
+	slotSize := window / counterSlots
+	if slotSize <= 0 {
+		slotSize = 1
+	}
+
+	return &WindowedCounter{
+		window:   window,
+		slotSize: slotSize,
+
+		slots:   make([]uint64, counterSlots),
+		slotIDs: make([]int64, counterSlots),
+	}
+}
+
+func (c *WindowedCounter) Add(ts time.Time, n uint64) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	slotID := ts.UnixNano() / int64(c.slotSize)
+	pos := int(slotID % counterSlots)
+	if c.slotIDs[pos] != slotID {
+		c.slots[pos] = 0
+		c.slotIDs[pos] = slotID
+	}
+	c.slots[pos] += n
+}
+
+// Sum returns the total of the values added within the window that ends at
+// the given moment.
+func (c *WindowedCounter) Sum(now time.Time) uint64 {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	current := now.UnixNano() / int64(c.slotSize)
+	var sum uint64
+	for pos, n := range c.slots {
+		if age := current - c.slotIDs[pos]; age < 0 || age >= counterSlots {
+			continue
+		}
+		sum += n
+	}
+
+	return sum
+}
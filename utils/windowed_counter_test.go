@@ -0,0 +1,24 @@
+package utils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/flashbots/node-monitor/utils"
+	"gotest.tools/assert"
+)
+
+func TestWindowedCounter(t *testing.T) {
+	c := utils.NewWindowedCounter(time.Minute)
+	start := time.Unix(1700000000, 0)
+
+	c.Add(start, 1)
+	c.Add(start.Add(30*time.Second), 2)
+	assert.Equal(t, uint64(3), c.Sum(start.Add(30*time.Second)))
+	assert.Equal(t, uint64(2), c.Sum(start.Add(time.Minute+time.Second)))
+	assert.Equal(t, uint64(0), c.Sum(start.Add(2*time.Minute)))
+
+	// the expired slot is reused
+	c.Add(start.Add(time.Minute), 5)
+	assert.Equal(t, uint64(7), c.Sum(start.Add(time.Minute)))
+}